@@ -0,0 +1,112 @@
+import $ from 'jquery';
+
+import * as common from './app-common.js';
+import * as routes from './routes.js';
+import { encode } from './html.js';
+import { queryParam } from './utils.js';
+
+$(document).ready(function () {
+    common.updateHeader();
+
+    let sim = queryParam('sim');
+    let suite = queryParam('suite');
+    let test = queryParam('test');
+    if (!suite || !test) {
+        $('#history-info').text('No test selected.');
+        return;
+    }
+    $('#history-test-name').text(test);
+    $('#history-suite-name').text(sim ? suite + ' (' + sim + ')' : suite);
+    document.title = test + ' - history - hive';
+
+    let params = new URLSearchParams({'suite': suite, 'test': test});
+    if (sim) {
+        params.set('sim', sim);
+    }
+    $.ajax({
+        type: 'GET',
+        url: 'history.json?' + params.toString(),
+        dataType: 'json',
+        cache: false,
+        success: showHistory,
+        error: function(xhr, status, error) {
+            $('#history-info').text('Error loading history: ' + error + '. Test history is only available with hiveview -serve.');
+        },
+    });
+});
+
+function showHistory(history) {
+    if (history.runs.length === 0) {
+        $('#history-info').text('No runs of this test were found.');
+        return;
+    }
+    $('#history-info').text(history.runs.length + ' runs. A run is counted as flaky when it passed only after retries, or when its result differs from the previous run.');
+
+    // Summary table with one row per client.
+    let table = $('<table class="table table-sm w-auto">');
+    table.append('<thead><tr><th>Client</th><th>Runs</th><th>Passes</th><th>Fails</th><th>Pass rate</th><th>Flake rate</th><th>Results (oldest first)</th></tr></thead>');
+    let body = $('<tbody>');
+    for (let stats of history.clients) {
+        let runs = history.runs.filter(r => r.client === stats.client).reverse();
+        let row = $('<tr>');
+        row.append('<td>' + encode(stats.client || 'none') + '</td>');
+        row.append('<td>' + stats.runs + '</td>');
+        row.append('<td>' + stats.passes + '</td>');
+        row.append('<td>' + stats.fails + '</td>');
+        row.append('<td>' + formatPercent(stats.passRate) + '</td>');
+        row.append('<td>' + formatPercent(stats.flakeRate) + '</td>');
+        row.append($('<td>').append(resultStrip(history, runs)));
+        body.append(row);
+    }
+    table.append(body);
+    $('#history-clients').append(table);
+
+    // List of all runs, newest first.
+    let list = $('<table class="table table-sm w-auto">');
+    list.append('<thead><tr><th>Date</th><th>Client</th><th>Result</th></tr></thead>');
+    let listBody = $('<tbody>');
+    for (let run of history.runs) {
+        let date = encode(new Date(run.start).toLocaleString());
+        if (run.available) {
+            date = '<a href="' + runURL(history, run) + '">' + date + '</a>';
+        }
+        listBody.append('<tr><td>' + date + '</td><td>' + encode(run.client || 'none') + '</td><td>' + formatResult(run) + '</td></tr>');
+    }
+    list.append(listBody);
+    $('#history-runs').append('<h4>Runs</h4>', list);
+}
+
+// resultStrip renders the runs as a row of colored boxes.
+function resultStrip(history, runs) {
+    let strip = $('<div class="history-strip">');
+    for (let run of runs) {
+        let cls = run.pass ? (run.flaky ? 'history-flaky' : 'history-pass') : 'history-fail';
+        let title = encode(new Date(run.start).toLocaleString() + ': ' + resultText(run));
+        if (run.available) {
+            strip.append(`<a class="${cls}" title="${title}" href="${runURL(history, run)}"></a>`);
+        } else {
+            strip.append(`<span class="${cls}" title="${title}"></span>`);
+        }
+    }
+    return strip;
+}
+
+function runURL(history, run) {
+    return routes.testInSuite(run.file, history.suite, run.testID);
+}
+
+function resultText(run) {
+    if (run.pass) {
+        return run.flaky ? 'passed after retries' : 'passed';
+    }
+    return run.timeout ? 'timeout' : 'failed';
+}
+
+function formatResult(run) {
+    let cls = run.pass ? (run.flaky ? 'text-warning' : 'text-success') : 'text-danger';
+    return '<span class="' + cls + '">' + resultText(run) + '</span>';
+}
+
+function formatPercent(rate) {
+    return (rate * 100).toFixed(1) + '%';
+}
@@ -7,7 +7,7 @@ import * as bootstrap from 'bootstrap';
 
 import * as common from './app-common.js';
 import * as routes from './routes.js';
-import { makeButton } from './html.js';
+import { encode, makeButton } from './html.js';
 import { formatBytes, escapeRegExp } from './utils.js';
 
 function timeSince(date) {
@@ -393,10 +393,16 @@ $(document).ready(function () {
                 width: '5.5em',
                 className: 'suite-status-column',
                 render: function(data, type, row) {
+                    if (data.buildFailed) {
+                        return `<span class="badge bg-danger" title="${encode(data.buildError)}">Build failed</span>`;
+                    }
                     if (data.fails > 0) {
                         let prefix = data.timeout ? 'Timeout' : 'Fail';
                         return `<span><span class="pass-count">✓ ${data.passes}</span> <span class="fail-count">✗ ${data.fails}</span> <span class="badge bg-danger ms-1">${prefix}</span></span>`;
                     }
+                    if (data.flaky > 0) {
+                        return `<span class="pass-count">✓ ${data.passes} <span class="badge bg-warning ms-1" title="${data.flaky} tests passed on retry">Flaky</span></span>`;
+                    }
                     return `<span class="pass-count">✓ ${data.passes} <span class="badge bg-success ms-1">Pass</span></span>`;
                 },
             },
@@ -408,7 +414,11 @@ $(document).ready(function () {
                 orderable: false,
                 render: function(data, type, row) {
                     // Find previous run with same suite and clients
+                    if (data.buildFailed) {
+                        return '';
+                    }
                     const prevRun = suites.find(s =>
+                        !s.buildFailed &&
                         s.name === data.name &&
                         s.clients.join(',') === data.clients.join(',') &&
                         new Date(s.start) < new Date(data.start)
@@ -431,6 +441,12 @@ $(document).ready(function () {
                 className: 'action-buttons-column',
                 orderable: false,
                 render: function(data) {
+                    if (data.buildFailed && !data.buildLog) {
+                        return '<span class="text-danger">Build failed</span>';
+                    }
+                    if (data.buildFailed) {
+                        return makeButton(routes.buildLog(data.buildLog), 'Build log', 'btn-danger btn-sm w-100').outerHTML;
+                    }
                     let url = routes.suite(data.fileName, data.name);
                     let loadText = 'Load (' + formatBytes(data.size) + ')';
                     const clientKey = data.clients.join(',');
@@ -438,6 +454,7 @@ $(document).ready(function () {
                     return `<div class="btn-group w-100">
                         ${makeButton('#', '<i class="bi bi-funnel"></i>', `btn-outline-secondary btn-sm`, `onclick="filterSuiteAndClient('${data.name}', '${clientKey}'); return false;" title="Filter by this suite and client"`).outerHTML}
                         ${makeButton(url, loadText, "btn-secondary btn-sm flex-grow-1").outerHTML}
+                        ${data.metrics ? makeButton(routes.metrics(data.name), '<i class="bi bi-graph-up"></i>', 'btn-outline-secondary btn-sm', 'title="Show metrics of this suite across runs"').outerHTML : ''}
                     </div>`;
                 },
             },
@@ -464,6 +481,9 @@ function showFileListing(data) {
     // First, collect all runs for each suite+client combination
     lines.forEach(line => {
         const entry = JSON.parse(line);
+        if (entry.buildFailed) {
+            return;
+        }
         if (!suiteGroups.has(entry.name)) {
             suiteGroups.set(entry.name, new Map());
         }
@@ -702,10 +722,16 @@ function showFileListing(data) {
                 width: '5.5em',
                 className: 'suite-status-column',
                 render: function(data, type, row) {
+                    if (data.buildFailed) {
+                        return `<span class="badge bg-danger" title="${encode(data.buildError)}">Build failed</span>`;
+                    }
                     if (data.fails > 0) {
                         let prefix = data.timeout ? 'Timeout' : 'Fail';
                         return `<span><span class="pass-count">✓ ${data.passes}</span> <span class="fail-count">✗ ${data.fails}</span> <span class="badge bg-danger ms-1">${prefix}</span></span>`;
                     }
+                    if (data.flaky > 0) {
+                        return `<span class="pass-count">✓ ${data.passes} <span class="badge bg-warning ms-1" title="${data.flaky} tests passed on retry">Flaky</span></span>`;
+                    }
                     return `<span class="pass-count">✓ ${data.passes} <span class="badge bg-success ms-1">Pass</span></span>`;
                 },
             },
@@ -717,7 +743,11 @@ function showFileListing(data) {
                 orderable: false,
                 render: function(data, type, row) {
                     // Find previous run with same suite and clients
+                    if (data.buildFailed) {
+                        return '';
+                    }
                     const prevRun = suites.find(s =>
+                        !s.buildFailed &&
                         s.name === data.name &&
                         s.clients.join(',') === data.clients.join(',') &&
                         new Date(s.start) < new Date(data.start)
@@ -740,6 +770,12 @@ function showFileListing(data) {
                 className: 'action-buttons-column',
                 orderable: false,
                 render: function(data) {
+                    if (data.buildFailed && !data.buildLog) {
+                        return '<span class="text-danger">Build failed</span>';
+                    }
+                    if (data.buildFailed) {
+                        return makeButton(routes.buildLog(data.buildLog), 'Build log', 'btn-danger btn-sm w-100').outerHTML;
+                    }
                     let url = routes.suite(data.fileName, data.name);
                     let loadText = 'Load (' + formatBytes(data.size) + ')';
                     const clientKey = data.clients.join(',');
@@ -747,6 +783,7 @@ function showFileListing(data) {
                     return `<div class="btn-group w-100">
                         ${makeButton('#', '<i class="bi bi-funnel"></i>', `btn-outline-secondary btn-sm`, `onclick="filterSuiteAndClient('${data.name}', '${clientKey}'); return false;" title="Filter by this suite and client"`).outerHTML}
                         ${makeButton(url, loadText, "btn-secondary btn-sm flex-grow-1").outerHTML}
+                        ${data.metrics ? makeButton(routes.metrics(data.name), '<i class="bi bi-graph-up"></i>', 'btn-outline-secondary btn-sm', 'title="Show metrics of this suite across runs"').outerHTML : ''}
                     </div>`;
                 },
             },
@@ -1115,6 +1152,9 @@ function processClientGroups(suites) {
     const clientGroups = new Map();
 
     suites.forEach(entry => {
+        if (entry.buildFailed) {
+            return;
+        }
         entry.clients.forEach(client => {
             if (!clientGroups.has(client)) {
                 clientGroups.set(client, new Map());
@@ -0,0 +1,72 @@
+import $ from 'jquery';
+
+import * as common from './app-common.js';
+import * as routes from './routes.js';
+import { encode } from './html.js';
+
+$(document).ready(function () {
+    common.updateHeader();
+    document.title = 'Client compatibility - hive';
+
+    $.ajax({
+        type: 'GET',
+        url: 'matrix.json',
+        dataType: 'json',
+        cache: false,
+        success: showMatrix,
+        error: function(xhr, status, error) {
+            $('#matrix-info').text('Error loading matrix: ' + error + '. The compatibility matrix is only available with hiveview -serve.');
+        },
+    });
+});
+
+function showMatrix(matrix) {
+    if (matrix.rows.length === 0) {
+        $('#matrix-info').text('No results available.');
+        return;
+    }
+    $('#matrix-info').text('Pass rate of the latest run of each suite against each client. Click a cell to open the run.');
+
+    let table = $('<table class="table table-sm w-auto matrix-table">');
+    let head = '<tr><th>Simulator</th><th>Suite</th>';
+    for (let client of matrix.clients) {
+        head += '<th>' + encode(client) + '</th>';
+    }
+    table.append('<thead>' + head + '</tr></thead>');
+
+    let body = $('<tbody>');
+    for (let row of matrix.rows) {
+        let tr = '<tr><td>' + encode(row.simulator || '') + '</td><td>' + encode(row.suite) + '</td>';
+        for (let client of matrix.clients) {
+            tr += formatCell(row, client, row.cells[client]);
+        }
+        body.append(tr + '</tr>');
+    }
+    table.append(body);
+    $('#matrix-table').append(table);
+}
+
+function formatCell(row, client, cell) {
+    if (!cell) {
+        return '<td></td>';
+    }
+    let pct = (cell.passRate * 100).toFixed(1) + '%';
+    let title = cell.passes + '/' + cell.tests + ' passing';
+    if (cell.version) {
+        title += ', version ' + cell.version;
+    }
+    title += ', ' + new Date(cell.start).toLocaleString();
+    let badgeURL = new URL(routes.badge(row.simulator, row.suite, client), window.location.href);
+    title += '\nBadge: ' + badgeURL.href;
+    let url = routes.suite(cell.file, row.suite);
+    return `<td class="${rateClass(cell.passRate)}"><a href="${url}" title="${encode(title)}">${pct}</a></td>`;
+}
+
+function rateClass(rate) {
+    if (rate === 1) {
+        return 'table-success';
+    } else if (rate >= 0.5) {
+        return 'table-warning';
+    }
+    return 'table-danger';
+}
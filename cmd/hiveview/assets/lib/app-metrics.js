@@ -0,0 +1,150 @@
+import $ from 'jquery';
+
+import * as common from './app-common.js';
+import { encode } from './html.js';
+
+$(document).ready(function () {
+    common.updateHeader();
+
+    let name = new URLSearchParams(window.location.search).get('suitename');
+    if (!name) {
+        $('#metrics-info').text('No suite selected.');
+        return;
+    }
+    $('#metrics-suite-name').text(name);
+
+    $.ajax({
+        type: 'GET',
+        url: 'listing.jsonl',
+        cache: false,
+        success: function(data) {
+            showMetrics(name, parseListing(data));
+        },
+        error: function(xhr, status, error) {
+            $('#metrics-info').text('Error loading listing: ' + error);
+        },
+    });
+});
+
+// parseListing decodes the JSON lines of listing.jsonl.
+function parseListing(data) {
+    let entries = [];
+    for (let line of data.split('\n')) {
+        line = line.trim();
+        if (line) {
+            entries.push(JSON.parse(line));
+        }
+    }
+    return entries;
+}
+
+// collectSeries groups the metrics of all runs of the named suite into one chart
+// per metric name and one series per client. When a client reports the same metric
+// multiple times in a run, the values are averaged.
+function collectSeries(suiteName, entries) {
+    let charts = new Map();
+    for (let entry of entries) {
+        if (entry.name !== suiteName || !entry.metrics) {
+            continue;
+        }
+        let time = new Date(entry.start).getTime();
+        let sums = new Map();
+        for (let m of entry.metrics) {
+            let key = m.name + '\0' + (m.client || '');
+            let s = sums.get(key) || { metric: m.name, client: m.client || 'mixed', unit: m.unit, sum: 0, count: 0 };
+            s.sum += m.value;
+            s.count++;
+            sums.set(key, s);
+        }
+        for (let s of sums.values()) {
+            if (!charts.has(s.metric)) {
+                charts.set(s.metric, { unit: s.unit, series: new Map() });
+            }
+            let series = charts.get(s.metric).series;
+            if (!series.has(s.client)) {
+                series.set(s.client, []);
+            }
+            series.get(s.client).push({ time: time, value: s.sum / s.count });
+        }
+    }
+    for (let chart of charts.values()) {
+        for (let points of chart.series.values()) {
+            points.sort((a, b) => a.time - b.time);
+        }
+    }
+    return charts;
+}
+
+function showMetrics(suiteName, entries) {
+    let charts = collectSeries(suiteName, entries);
+    if (charts.size === 0) {
+        $('#metrics-info').text('No metrics were reported by this suite.');
+        return;
+    }
+    let names = Array.from(charts.keys()).sort();
+    for (let name of names) {
+        let chart = charts.get(name);
+        let title = encode(name) + (chart.unit ? ' (' + encode(chart.unit) + ')' : '');
+        let section = $('<div class="metrics-chart">');
+        section.append('<h4>' + title + '</h4>');
+        section.append(lineChart(chart.series));
+        $('#metrics-charts').append(section);
+    }
+}
+
+const chartWidth = 800;
+const chartHeight = 300;
+const chartPadding = { top: 10, right: 150, bottom: 30, left: 70 };
+const seriesColors = ['#1f77b4', '#ff7f0e', '#2ca02c', '#d62728', '#9467bd', '#8c564b', '#e377c2', '#7f7f7f', '#bcbd22', '#17becf'];
+
+// lineChart renders the given series as an SVG line chart.
+function lineChart(series) {
+    let all = Array.from(series.values()).flat();
+    let minT = Math.min(...all.map(p => p.time));
+    let maxT = Math.max(...all.map(p => p.time));
+    let minV = Math.min(0, ...all.map(p => p.value));
+    let maxV = Math.max(...all.map(p => p.value));
+    if (maxT === minT) {
+        maxT = minT + 1;
+    }
+    if (maxV === minV) {
+        maxV = minV + 1;
+    }
+    let plotW = chartWidth - chartPadding.left - chartPadding.right;
+    let plotH = chartHeight - chartPadding.top - chartPadding.bottom;
+    let x = (t) => chartPadding.left + (t - minT) / (maxT - minT) * plotW;
+    let y = (v) => chartPadding.top + plotH - (v - minV) / (maxV - minV) * plotH;
+
+    let svg = `<svg class="metrics-svg" viewBox="0 0 ${chartWidth} ${chartHeight}" width="100%" style="max-width: ${chartWidth}px">`;
+    // Axes and labels.
+    let bottom = chartPadding.top + plotH;
+    svg += `<line x1="${chartPadding.left}" y1="${bottom}" x2="${chartPadding.left + plotW}" y2="${bottom}" stroke="currentColor"/>`;
+    svg += `<line x1="${chartPadding.left}" y1="${chartPadding.top}" x2="${chartPadding.left}" y2="${bottom}" stroke="currentColor"/>`;
+    svg += `<text x="${chartPadding.left - 5}" y="${chartPadding.top + 10}" text-anchor="end" font-size="12" fill="currentColor">${formatValue(maxV)}</text>`;
+    svg += `<text x="${chartPadding.left - 5}" y="${bottom}" text-anchor="end" font-size="12" fill="currentColor">${formatValue(minV)}</text>`;
+    svg += `<text x="${chartPadding.left}" y="${chartHeight - 8}" font-size="12" fill="currentColor">${encode(new Date(minT).toLocaleDateString())}</text>`;
+    svg += `<text x="${chartPadding.left + plotW}" y="${chartHeight - 8}" text-anchor="end" font-size="12" fill="currentColor">${encode(new Date(maxT).toLocaleDateString())}</text>`;
+
+    // Series.
+    let clients = Array.from(series.keys()).sort();
+    clients.forEach(function (client, i) {
+        let color = seriesColors[i % seriesColors.length];
+        let points = series.get(client);
+        let path = points.map(p => `${x(p.time).toFixed(1)},${y(p.value).toFixed(1)}`).join(' ');
+        svg += `<polyline fill="none" stroke="${color}" stroke-width="2" points="${path}"/>`;
+        for (let p of points) {
+            let tip = encode(client + ': ' + formatValue(p.value) + ' at ' + new Date(p.time).toLocaleString());
+            svg += `<circle cx="${x(p.time).toFixed(1)}" cy="${y(p.value).toFixed(1)}" r="3" fill="${color}"><title>${tip}</title></circle>`;
+        }
+        let ly = chartPadding.top + 15 + i * 18;
+        let lx = chartPadding.left + plotW + 15;
+        svg += `<rect x="${lx}" y="${ly - 10}" width="10" height="10" fill="${color}"/>`;
+        svg += `<text x="${lx + 15}" y="${ly}" font-size="12" fill="currentColor">${encode(client)}</text>`;
+    });
+    svg += '</svg>';
+    return svg;
+}
+
+function formatValue(v) {
+    return Number.isInteger(v) ? String(v) : v.toPrecision(4);
+}
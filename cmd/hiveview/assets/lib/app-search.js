@@ -0,0 +1,82 @@
+import $ from 'jquery';
+
+import * as common from './app-common.js';
+import * as routes from './routes.js';
+import { encode } from './html.js';
+import { queryParam } from './utils.js';
+
+$(document).ready(function () {
+    common.updateHeader();
+
+    let query = queryParam('q');
+    if (!query) {
+        return;
+    }
+    $('#search-query').val(query);
+    document.title = query + ' - search - hive';
+    $('#search-info').text('Searching...');
+
+    $.ajax({
+        type: 'GET',
+        url: 'search.json?' + new URLSearchParams({'q': query}).toString(),
+        dataType: 'json',
+        cache: false,
+        success: showResults,
+        error: function(xhr, status, error) {
+            let msg = xhr.responseText || error;
+            $('#search-info').text('Search failed: ' + msg + '. Search is only available with hiveview -serve.');
+        },
+    });
+});
+
+const fieldNames = {
+    'name': 'Test name',
+    'description': 'Description',
+    'details': 'Test output',
+    'client': 'Client log',
+};
+
+function showResults(data) {
+    let indexing = data.indexing ? ' The search index is still being built, so results may be incomplete.' : '';
+    if (data.results.length === 0) {
+        $('#search-info').text('No matches.' + indexing);
+        return;
+    }
+    let info = data.results.length + ' matches';
+    if (data.truncated) {
+        info += ' (more results omitted)';
+    }
+    $('#search-info').text(info + ', newest runs first.' + indexing);
+
+    let table = $('<table class="table table-sm">');
+    table.append('<thead><tr><th>Suite</th><th>Test</th><th>Found in</th><th>Match</th></tr></thead>');
+    let body = $('<tbody>');
+    for (let r of data.results) {
+        let testURL = routes.testInSuite(r.file, r.suite, r.testID);
+        let suiteLink = '<a href="' + routes.suite(r.file, r.suite) + '">' + encode(r.suite) + '</a>';
+        let testLink = '<a href="' + testURL + '">' + encode(r.test) + '</a>';
+        let where = fieldNames[r.field] || r.field;
+        let matchURL = resultURL(r, testURL);
+        if (r.client) {
+            where += ' (' + encode(r.client) + ')';
+        }
+        if (r.line) {
+            where = '<a href="' + matchURL + '">' + where + ', line ' + r.line + '</a>';
+        }
+        body.append('<tr><td>' + suiteLink + '</td><td>' + testLink + '</td><td>' + where + '</td><td><code>' + encode(r.text) + '</code></td></tr>');
+    }
+    table.append(body);
+    $('#search-results').append(table);
+}
+
+// resultURL returns the link to the matching line of a result.
+function resultURL(r, testURL) {
+    switch (r.field) {
+    case 'details':
+        return routes.testLog(r.file, r.suite, r.testID) + '#L' + r.line;
+    case 'client':
+        return routes.clientLog(r.file, r.suite, r.testID, routes.resultsRoot + r.logFile) + '#L' + r.line;
+    default:
+        return testURL;
+    }
+}
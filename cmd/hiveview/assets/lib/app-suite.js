@@ -70,9 +70,11 @@ function showSuiteData(data, suiteID) {
         "description": "This suite of tests checks...",
         "simLog": "1674486996-simulator-0ee…eb2e3f04a893bff1017.log",
         "clientVersions": { "parity_latest": "..." },
+        "randomSeed": 1234,
         "testCases": {
             "1": {
                 "id": 1,
+                "parent": 0,
                 "name": "SpoofSanityCheck(v4013)",
                 "description": "A sanity check to make sure that the network setup works for spoofing",
                 "start": "2020-04-22T17:12:13.018490141Z",
@@ -120,6 +122,17 @@ function showSuiteData(data, suiteID) {
     }
     console.log('got ' + cases.length + ' testcases');
 
+    // Link subtests to their parents. If there are any, the table
+    // is displayed as a tree instead of a sortable list.
+    let isTree = buildTestTree(cases, data.testCases);
+    if (isTree) {
+        cases = flattenTestTree(cases);
+        $.fn.dataTable.ext.search.push(function (settings, searchData, index, rowData) {
+            // Show everything while searching, otherwise hide collapsed subtests.
+            return settings.oPreviousSearch.sSearch !== '' || testTreeVisible(rowData);
+        });
+    }
+
     // Fill info box.
     let suiteTimes = testSuiteTimes(cases);
     const stats = calculateTestStats(cases);
@@ -131,10 +144,12 @@ function showSuiteData(data, suiteID) {
             <span class="text-success">✓ ${stats.passed}</span> /
             <span class="text-danger">✗ ${stats.failed}</span>
             ${stats.timeouts > 0 ? `/ <span class="text-warning">${stats.timeouts} timeouts</span>` : ''}
+            ${stats.flaky > 0 ? `/ <span class="text-warning">${stats.flaky} flaky</span>` : ''}
             ${stats.failed > 0
                 ? '<span class="badge bg-danger ms-1">Fail</span>'
                 : '<span class="badge bg-success ms-1">Pass</span>'}
         </li>
+        ${data.randomSeed ? `<li class="list-group-item" title="random seed">🎲 ${data.randomSeed}</li>` : ''}
         <li class="list-group-item"><a id="sim-log-link"></a></li>
     `);
 
@@ -157,7 +172,8 @@ function showSuiteData(data, suiteID) {
                 display: function (row, update, render) {},
             },
         },
-        order: [[1, 'desc']],
+        order: isTree ? [] : [[1, 'desc']],
+        ordering: !isTree,
         columns: [
             {
                 title: 'Test',
@@ -166,6 +182,12 @@ function showSuiteData(data, suiteID) {
                 className: 'test-name-column',
                 width: '65%',
                 responsivePriority: 0,
+                render: function (name, type, row) {
+                    if (type !== 'display' || !isTree) {
+                        return name;
+                    }
+                    return formatTreeName(row);
+                },
             },
             // Status: pass or not.
             {
@@ -175,7 +197,9 @@ function showSuiteData(data, suiteID) {
                 name: 'status',
                 width: '4em',
                 responsivePriority: 0,
-                render: formatTestStatus,
+                render: function (summaryResult, type, row) {
+                    return formatTestStatus(summaryResult) + formatSubtestCounts(row);
+                },
             },
             // Test duration.
             {
@@ -214,12 +238,104 @@ function showSuiteData(data, suiteID) {
 
     // This sets up the expanded info on click.
     // https://www.datatables.net/examples/api/row_details.html
-    $('#execresults tbody').on('click', 'td.test-name-column', function() {
+    $('#execresults tbody').on('click', 'td.test-name-column', function(event) {
         let tr = $(this).closest('tr');
+        if ($(event.target).hasClass('subtest-toggle')) {
+            let test = table.row(tr).data();
+            test.expanded = !test.expanded;
+            table.draw(false);
+            return;
+        }
         toggleTestDetails(data, table, tr);
     });
 }
 
+// buildTestTree links test cases to their parent tests. It sets the 'subtests' and
+// 'parentTest' fields of each case, and the 'depth' and 'rollup' counts of all
+// subtests. Returns true if any test has subtests.
+function buildTestTree(cases, testCases) {
+    let nested = false;
+    for (let tc of cases) {
+        tc.subtests = [];
+        tc.expanded = false;
+    }
+    for (let tc of cases) {
+        let parent = tc.parent ? testCases[tc.parent] : null;
+        tc.parentTest = parent || null;
+        if (parent) {
+            parent.subtests.push(tc);
+            nested = true;
+        }
+    }
+    for (let tc of cases) {
+        if (!tc.parentTest) {
+            computeRollup(tc, 0);
+        }
+    }
+    return nested;
+}
+
+// computeRollup sets the depth of a test and counts the results of all its subtests.
+function computeRollup(tc, depth) {
+    tc.depth = depth;
+    tc.rollup = { passed: 0, failed: 0 };
+    tc.subtests.sort((a, b) => parseInt(a.testIndex) - parseInt(b.testIndex));
+    for (let sub of tc.subtests) {
+        computeRollup(sub, depth + 1);
+        if (sub.summaryResult.pass) {
+            tc.rollup.passed++;
+        } else {
+            tc.rollup.failed++;
+        }
+        tc.rollup.passed += sub.rollup.passed;
+        tc.rollup.failed += sub.rollup.failed;
+    }
+}
+
+// flattenTestTree returns the test cases in tree order, i.e. every test
+// is followed by its subtests.
+function flattenTestTree(cases) {
+    let result = [];
+    let visit = function (tc) {
+        result.push(tc);
+        tc.subtests.forEach(visit);
+    };
+    cases.filter((tc) => !tc.parentTest)
+        .sort((a, b) => parseInt(a.testIndex) - parseInt(b.testIndex))
+        .forEach(visit);
+    return result;
+}
+
+// testTreeVisible reports whether all parents of a test are expanded.
+function testTreeVisible(tc) {
+    for (let p = tc.parentTest; p; p = p.parentTest) {
+        if (!p.expanded) {
+            return false;
+        }
+    }
+    return true;
+}
+
+// formatTreeName renders the test name with indentation and subtest toggle.
+function formatTreeName(tc) {
+    let indent = '<span class="subtest-indent" style="width: ' + (tc.depth * 1.5) + 'em"></span>';
+    let toggle = '';
+    if (tc.subtests.length > 0) {
+        toggle = '<span class="subtest-toggle" title="show subtests">' + (tc.expanded ? '▾' : '▸') + '</span>';
+    }
+    return indent + toggle + html.encode(tc.name);
+}
+
+// formatSubtestCounts renders the rolled-up results of all subtests.
+function formatSubtestCounts(tc) {
+    if (!tc.subtests || tc.subtests.length == 0) {
+        return '';
+    }
+    let r = tc.rollup;
+    return ` <span class="subtest-counts" title="subtest results">(<span class="text-success">✓ ${r.passed}</span>` +
+        (r.failed > 0 ? ` / <span class="text-danger">✗ ${r.failed}</span>` : '') + ')</span>';
+}
+
 // testSuiteTimes computes start/end/duration of a test suite.
 // The duration is returned in milliseconds.
 function testSuiteTimes(cases) {
@@ -253,6 +369,13 @@ function testCaseDuration(test) {
 function scrollToTest(suiteData, testIndex) {
     let table = $('#execresults').dataTable().api();
     let row = findRowByTestIndex(table, testIndex);
+    if (row) {
+        // Expand the parent tests, so the row is displayed.
+        for (let p = row.data().parentTest; p; p = p.parentTest) {
+            p.expanded = true;
+        }
+        table.draw(false);
+    }
     if (!row) {
         console.error('invalid row in scrollToTest:', testIndex);
         return;
@@ -336,6 +459,9 @@ function formatClientLogsList(suiteData, testIndex, clientInfo) {
 }
 
 function formatTestStatus(summaryResult) {
+    if (summaryResult.pass && summaryResult.flaky) {
+        return '<span class="text-warning">&#x2713; <b>Flaky</b></span>';
+    }
     if (summaryResult.pass) {
         return '<span class="text-success">&#x2713;</span>';
     }
@@ -365,6 +491,12 @@ function formatTestDetails(suiteData, row) {
         p.innerHTML = '<b>Clients:</b> ' + formatClientLogsList(suiteData, d.testIndex, d.clientInfo);
         container.appendChild(p);
     }
+    if (d.simLog) {
+        let p = document.createElement('p');
+        let url = routes.simulatorLog(suiteData.suiteID, suiteData.name, routes.resultsRoot + d.simLog);
+        p.innerHTML = '<b>Simulator log:</b> <a href="' + url + '">' + html.encode(d.simLog) + '</a>';
+        container.appendChild(p);
+    }
     if (!row.column('duration:name').responsiveHidden()) {
         let p = document.createElement('p');
         p.innerHTML = '<b>Duration:</b> ' + formatDuration(d.duration);
@@ -402,6 +534,30 @@ function formatTestDetails(suiteData, row) {
         container.appendChild(p);
     }
 
+    if (d.metrics && d.metrics.length > 0) {
+        let p = document.createElement('p');
+        let items = d.metrics.map(function (m) {
+            let unit = m.unit ? ' ' + html.encode(m.unit) : '';
+            return '<li>' + html.encode(m.name) + ': ' + m.value + unit + '</li>';
+        });
+        p.innerHTML = '<b>Metrics:</b><ul>' + items.join('') + '</ul>';
+        container.appendChild(p);
+    }
+
+    if (d.attempts && d.attempts.length > 0) {
+        formatTestAttempts(suiteData, d, container);
+    }
+
+    let history = document.createElement('p');
+    let historyURL = routes.testHistory(suiteData.simulator, suiteData.name, d.name);
+    history.appendChild(html.makeLink(historyURL, 'Show history of this test'));
+    if (testHasClients(d)) {
+        let timelineURL = routes.testTimeline(suiteData.suiteID, suiteData.name, d.testIndex);
+        history.append(' | ');
+        history.appendChild(html.makeLink(timelineURL, 'Show client timeline'));
+    }
+    container.appendChild(history);
+
     if (d.summaryResult.details) {
         // Test output is contained directly in the test, so it can just be displayed.
         // In order to avoid freezing the browser with lots of output, we limit the display to
@@ -437,6 +593,52 @@ function formatTestDetails(suiteData, row) {
     return container;
 }
 
+// formatTestAttempts adds the failed attempts of a retried test to the details box.
+// The output of each attempt is loaded from the test details log.
+function formatTestAttempts(suiteData, d, container) {
+    let p = document.createElement('p');
+    p.innerHTML = '<b>Attempts:</b>';
+    container.appendChild(p);
+
+    let list = document.createElement('ol');
+    list.classList.add('test-attempts');
+    d.attempts.forEach(function (attempt) {
+        let item = document.createElement('li');
+        let duration = new Date(attempt.end) - new Date(attempt.start);
+        let txt = formatTestStatus(attempt.summaryResult) + ' in ' + formatDuration(duration);
+        if (attempt.clientInfo && Object.keys(attempt.clientInfo).length > 0) {
+            txt += ', clients: ' + formatClientLogsList(suiteData, d.testIndex, attempt.clientInfo);
+        }
+        if (attempt.subtests) {
+            let subtests = Object.values(attempt.subtests);
+            let failed = subtests.filter(function (t) { return !t.summaryResult.pass; }).length;
+            txt += ', subtests: ' + subtests.length + ' (' + failed + ' failed)';
+        }
+        item.innerHTML = txt;
+
+        let output = document.createElement('code');
+        output.classList.add('output-prefix', 'output-suffix');
+        if (attempt.summaryResult.details) {
+            let log = testlog.splitHeadTail(attempt.summaryResult.details, 10);
+            output.innerHTML = formatTestDetailLines(log.head.concat(log.tail));
+        } else if (attempt.summaryResult.log) {
+            let url = routes.resultsRoot + suiteData.testDetailsLog;
+            let loader = new testlog.Loader(url, attempt.summaryResult.log);
+            loader.headAndTailLines(10, 1048576).then(function (log) {
+                output.innerHTML = formatTestDetailLines(log.head.concat(log.tail));
+            }).catch(function (error) {
+                console.error(error);
+            });
+        }
+        let box = document.createElement('div');
+        box.classList.add('test-output');
+        box.appendChild(output);
+        item.appendChild(box);
+        list.appendChild(item);
+    });
+    container.appendChild(list);
+}
+
 // formatTestLog formats the test output.
 // logData is an object like { head: "...", tail: "...", hiddenLines: 10 }.
 function formatTestLog(suiteData, testIndex, logData, container) {
@@ -500,6 +702,9 @@ function calculateTestStats(cases) {
     return cases.reduce((stats, test) => {
         if (test.summaryResult.pass) {
             stats.passed++;
+            if (test.summaryResult.flaky) {
+                stats.flaky++;
+            }
         } else {
             stats.failed++;
             if (test.summaryResult.timeout) {
@@ -507,5 +712,5 @@ function calculateTestStats(cases) {
             }
         }
         return stats;
-    }, { passed: 0, failed: 0, timeouts: 0 });
+    }, { passed: 0, failed: 0, timeouts: 0, flaky: 0 });
 }
@@ -0,0 +1,261 @@
+import $ from 'jquery';
+
+import * as common from './app-common.js';
+import * as routes from './routes.js';
+import { encode } from './html.js';
+import { queryParam } from './utils.js';
+
+// Only the beginning of large client logs is loaded.
+const maxLogBytes = 4 * 1024 * 1024;
+
+$(document).ready(function () {
+    common.updateHeader();
+
+    let suiteFile = queryParam('suiteid');
+    let suiteName = queryParam('suitename');
+    let testID = queryParam('testid');
+    if (!suiteFile || !testID) {
+        $('#timeline-info').text('No test selected.');
+        return;
+    }
+    $('#timeline-suite-link').text(suiteName).attr('href', routes.suite(suiteFile, suiteName));
+
+    $.ajax({
+        type: 'GET',
+        url: routes.resultsRoot + suiteFile,
+        dataType: 'json',
+        success: function(suiteData) {
+            let test = suiteData.testCases[testID];
+            if (!test) {
+                $('#timeline-info').text('Test ' + testID + ' not found in suite.');
+                return;
+            }
+            $('#timeline-test-name').text(test.name);
+            document.title = test.name + ' - timeline - hive';
+            showTimeline(suiteFile, suiteData.name, testID, test);
+        },
+        error: function(xhr, status, error) {
+            $('#timeline-info').text('Error loading suite: ' + error);
+        },
+    });
+});
+
+// buildLanes computes the lifecycle of each client from the test events.
+function buildLanes(test) {
+    let end = new Date(test.end).getTime();
+    let events = (test.events || []).map(function (ev) {
+        return Object.assign({}, ev, { t: new Date(ev.time).getTime() });
+    });
+    let clients = Object.entries(test.clientInfo || {}).map(function ([id, info]) {
+        return { id: id, info: info, start: new Date(info.instantiatedAt).getTime() };
+    });
+    clients.sort((a, b) => a.start - b.start);
+
+    return clients.map(function (c) {
+        let lane = { id: c.id, info: c.info, start: c.start, end: end, pauses: [], events: [] };
+        let pausedAt = null;
+        for (let ev of events) {
+            if (ev.client !== c.id) {
+                continue;
+            }
+            lane.events.push(ev);
+            if (ev.type === 'pause') {
+                pausedAt = ev.t;
+            } else if (ev.type === 'unpause' && pausedAt !== null) {
+                lane.pauses.push([pausedAt, ev.t]);
+                pausedAt = null;
+            } else if (ev.type === 'stop') {
+                lane.end = Math.min(lane.end, ev.t);
+            }
+        }
+        if (pausedAt !== null) {
+            lane.pauses.push([pausedAt, lane.end]);
+        }
+        return lane;
+    });
+}
+
+const chartWidth = 1000;
+const labelWidth = 220;
+const laneHeight = 46;
+const barHeight = 16;
+const eventColors = {
+    'stop': '#d62728',
+    'pause': '#ff7f0e',
+    'unpause': '#2ca02c',
+    'connect': '#1f77b4',
+    'disconnect': '#9467bd',
+    'disk-readonly': '#8c564b',
+    'disk-writable': '#17becf',
+};
+
+function showTimeline(suiteFile, suiteName, testID, test) {
+    let lanes = buildLanes(test);
+    if (lanes.length === 0) {
+        $('#timeline-info').text('This test did not start any clients.');
+        return;
+    }
+    let minT = Math.min(new Date(test.start).getTime(), ...lanes.map(l => l.start));
+    let maxT = Math.max(new Date(test.end).getTime(), ...lanes.map(l => l.end));
+    if (maxT <= minT) {
+        maxT = minT + 1;
+    }
+    let plotW = chartWidth - labelWidth - 20;
+    let x = (t) => labelWidth + (t - minT) / (maxT - minT) * plotW;
+    let height = (lanes.length + 1) * laneHeight + 30;
+
+    let svg = `<svg id="timeline-svg" viewBox="0 0 ${chartWidth} ${height}" width="100%" style="max-width: ${chartWidth}px">`;
+    // Test lane.
+    svg += `<text x="5" y="${laneHeight / 2 + 4}" font-size="12" fill="currentColor">test</text>`;
+    svg += `<rect x="${x(new Date(test.start).getTime())}" y="${(laneHeight - barHeight) / 2}" width="${x(new Date(test.end).getTime()) - x(new Date(test.start).getTime())}" height="${barHeight}" fill="#7f7f7f"/>`;
+    // Client lanes.
+    lanes.forEach(function (lane, i) {
+        let y = (i + 1) * laneHeight;
+        let barY = y + 4;
+        let label = lane.info.name + ' ' + lane.id.substring(0, 8);
+        svg += `<text x="5" y="${barY + barHeight - 4}" font-size="12" fill="currentColor">${encode(label)}</text>`;
+        svg += `<rect x="${x(lane.start)}" y="${barY}" width="${Math.max(1, x(lane.end) - x(lane.start))}" height="${barHeight}" fill="#1f77b4" opacity="0.7"><title>${encode(label)}: ${formatTime(lane.start)} - ${formatTime(lane.end)}</title></rect>`;
+        for (let [from, to] of lane.pauses) {
+            svg += `<rect x="${x(from)}" y="${barY}" width="${Math.max(1, x(to) - x(from))}" height="${barHeight}" fill="#ff7f0e" opacity="0.8"><title>paused ${formatTime(from)} - ${formatTime(to)}</title></rect>`;
+        }
+        for (let ev of lane.events) {
+            let cx = x(ev.t);
+            let color = eventColors[ev.type] || 'currentColor';
+            svg += `<path d="M${cx - 5},${barY - 4} L${cx + 5},${barY - 4} L${cx},${barY + 3} Z" fill="${color}"><title>${encode(eventText(ev))}</title></path>`;
+        }
+        // Placeholder group for log line ticks.
+        svg += `<g class="timeline-log" data-lane="${i}" transform="translate(0 ${barY + barHeight + 3})"></g>`;
+    });
+    // Time axis.
+    let axisY = height - 10;
+    svg += `<text x="${labelWidth}" y="${axisY}" font-size="12" fill="currentColor">${encode(formatTime(minT))}</text>`;
+    svg += `<text x="${labelWidth + plotW}" y="${axisY}" text-anchor="end" font-size="12" fill="currentColor">${encode(formatTime(maxT))} (+${((maxT - minT) / 1000).toFixed(1)}s)</text>`;
+    svg += '</svg>';
+    $('#timeline-chart').html(svg);
+    $('#timeline-info').text('Bars show client lifetimes, orange sections are pauses and triangles are events. ' +
+        'Ticks below each bar are client log lines with a timestamp, red for errors and yellow for warnings. Click a tick to open the log line.');
+
+    showEventTable(lanes);
+
+    // Load client logs and add their lines to the chart.
+    let year = new Date(minT).getUTCFullYear();
+    lanes.forEach(function (lane, i) {
+        if (!lane.info.logFile) {
+            return;
+        }
+        loadLog(routes.resultsRoot + lane.info.logFile).then(function (text) {
+            let url = routes.clientLog(suiteFile, suiteName, testID, routes.resultsRoot + lane.info.logFile);
+            let ticks = logTicks(text, year, minT, maxT, x, url);
+            document.querySelector(`#timeline-svg g[data-lane="${i}"]`).innerHTML = ticks;
+        }).catch(function (error) {
+            console.error('can\'t load client log:', error);
+        });
+    });
+}
+
+function showEventTable(lanes) {
+    let rows = [];
+    for (let lane of lanes) {
+        rows.push({ t: lane.start, client: lane.info.name + ' ' + lane.id.substring(0, 8), text: 'start' });
+        for (let ev of lane.events) {
+            rows.push({ t: ev.t, client: lane.info.name + ' ' + lane.id.substring(0, 8), text: eventText(ev) });
+        }
+    }
+    rows.sort((a, b) => a.t - b.t);
+    let table = $('<table class="table table-sm w-auto">');
+    table.append('<thead><tr><th>Time</th><th>Client</th><th>Event</th></tr></thead>');
+    let body = $('<tbody>');
+    for (let r of rows) {
+        body.append('<tr><td>' + encode(formatTime(r.t)) + '</td><td>' + encode(r.client) + '</td><td>' + encode(r.text) + '</td></tr>');
+    }
+    table.append(body);
+    $('#timeline-events').append('<h4>Events</h4>', table);
+}
+
+async function loadLog(url) {
+    let response = await fetch(url, { headers: { 'Range': 'bytes=0-' + (maxLogBytes - 1) } });
+    if (!response.ok) {
+        throw new Error('HTTP ' + response.status);
+    }
+    return await response.text();
+}
+
+// logTicks renders a tick for each log line with a timestamp in the test's time range.
+// Only the most severe line is kept for each pixel column.
+function logTicks(text, year, minT, maxT, x, url) {
+    let columns = new Map();
+    let lines = text.split('\n');
+    for (let i = 0; i < lines.length; i++) {
+        let t = parseLogTime(lines[i], year);
+        if (t === null || t < minT || t > maxT) {
+            continue;
+        }
+        let col = Math.round(x(t));
+        let severity = lineSeverity(lines[i]);
+        let prev = columns.get(col);
+        if (!prev || severity > prev.severity) {
+            columns.set(col, { line: i + 1, text: lines[i], severity: severity });
+        }
+    }
+    let colors = ['#7f7f7f', '#dfb317', '#d62728'];
+    let out = '';
+    for (let [col, tick] of columns) {
+        let title = encode('line ' + tick.line + ': ' + tick.text.substring(0, 300));
+        out += `<a href="${url}#L${tick.line}"><rect x="${col}" y="0" width="1.5" height="10" fill="${colors[tick.severity]}"><title>${title}</title></rect></a>`;
+    }
+    return out;
+}
+
+function lineSeverity(line) {
+    if (/\b(ERROR|ERRO|CRIT|FATAL|error)\b/.test(line)) {
+        return 2;
+    }
+    if (/\b(WARN|WARNING|warn)\b/.test(line)) {
+        return 1;
+    }
+    return 0;
+}
+
+const months = { Jan: 0, Feb: 1, Mar: 2, Apr: 3, May: 4, Jun: 5, Jul: 6, Aug: 7, Sep: 8, Oct: 9, Nov: 10, Dec: 11 };
+
+// parseLogTime extracts the timestamp of a log line. It supports ISO 8601 timestamps,
+// the go-ethereum format [MM-DD|hh:mm:ss.fff] and syslog-style 'Mon DD hh:mm:ss'.
+// Timestamps without a time zone are assumed to be UTC, which is the default in
+// client containers. Returns null if the line has no timestamp.
+function parseLogTime(line, year) {
+    let m = line.match(/(\d{4})-(\d{2})-(\d{2})[T ](\d{2}):(\d{2}):(\d{2})(\.\d+)?(Z|[+-]\d{2}:?\d{2})?/);
+    if (m) {
+        let t = Date.UTC(+m[1], +m[2] - 1, +m[3], +m[4], +m[5], +m[6], fraction(m[7]));
+        return t - zoneOffset(m[8]);
+    }
+    m = line.match(/\[(\d{2})-(\d{2})\|(\d{2}):(\d{2}):(\d{2})(\.\d+)?\]/);
+    if (m) {
+        return Date.UTC(year, +m[1] - 1, +m[2], +m[3], +m[4], +m[5], fraction(m[6]));
+    }
+    m = line.match(/\b(Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) +(\d{1,2}) (\d{2}):(\d{2}):(\d{2})(\.\d+)?/);
+    if (m) {
+        return Date.UTC(year, months[m[1]], +m[2], +m[3], +m[4], +m[5], fraction(m[6]));
+    }
+    return null;
+}
+
+function fraction(s) {
+    return s ? Math.round(parseFloat(s) * 1000) : 0;
+}
+
+function zoneOffset(zone) {
+    if (!zone || zone === 'Z') {
+        return 0;
+    }
+    let sign = zone[0] === '-' ? -1 : 1;
+    let digits = zone.substring(1).replace(':', '');
+    return sign * (parseInt(digits.substring(0, 2)) * 60 + parseInt(digits.substring(2, 4))) * 60000;
+}
+
+function eventText(ev) {
+    return ev.network ? ev.type + ' ' + ev.network : ev.type;
+}
+
+function formatTime(t) {
+    return new Date(t).toISOString().replace('T', ' ').replace('Z', '');
+}
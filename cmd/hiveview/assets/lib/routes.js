@@ -30,6 +30,11 @@ export function clientLog(suiteID, suiteName, testIndex, file) {
     return 'viewer.html?' + params.toString();
 }
 
+export function buildLog(file) {
+    let params = new URLSearchParams({'file': resultsRoot + file});
+    return 'viewer.html?' + params.toString();
+}
+
 export function suite(suiteID, suiteName) {
     let params = new URLSearchParams({'suiteid': suiteID, 'suitename': suiteName});
     return 'suite.html?' + params.toString();
@@ -38,3 +43,33 @@ export function suite(suiteID, suiteName) {
 export function testInSuite(suiteID, suiteName, testIndex) {
     return suite(suiteID, suiteName) + '#test-' + escape(testIndex);
 }
+
+export function metrics(suiteName) {
+    let params = new URLSearchParams({'suitename': suiteName});
+    return 'metrics.html?' + params.toString();
+}
+
+export function testHistory(simulator, suiteName, testName) {
+    let params = new URLSearchParams({'suite': suiteName, 'test': testName});
+    if (simulator) {
+        params.set('sim', simulator);
+    }
+    return 'history.html?' + params.toString();
+}
+
+export function badge(simulator, suiteName, client) {
+    let params = new URLSearchParams({'suite': suiteName, 'client': client});
+    if (simulator) {
+        params.set('sim', simulator);
+    }
+    return 'badge.svg?' + params.toString();
+}
+
+export function testTimeline(suiteID, suiteName, testIndex) {
+    let params = new URLSearchParams({
+        'suiteid': suiteID,
+        'suitename': suiteName,
+        'testid': testIndex,
+    });
+    return 'timeline.html?' + params.toString();
+}
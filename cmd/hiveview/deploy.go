@@ -19,6 +19,11 @@ func hiveviewBundler(fsys fs.FS) *bundler {
 		"lib/app-index.js",
 		"lib/app-suite.js",
 		"lib/app-viewer.js",
+		"lib/app-metrics.js",
+		"lib/app-history.js",
+		"lib/app-matrix.js",
+		"lib/app-search.js",
+		"lib/app-timeline.js",
 		"lib/app.css",
 		"lib/viewer.css",
 	}
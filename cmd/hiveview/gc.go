@@ -1,48 +1,147 @@
 package main
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ethereum/hive/internal/libhive"
 )
 
-func logdirGC(dir string, cutoff time.Time, keepMin int) error {
+// gcConfig configures logdirGC.
+type gcConfig struct {
+	now     time.Time
+	keep    retention            // default retention policy
+	simKeep map[string]retention // retention policy by simulator name
+
+	// When compressAfter is non-zero, the logs of suites older than this are
+	// compressed instead of being kept as plain text.
+	compressAfter time.Duration
+	dryRun        bool
+
+	// Index entries of deleted suites older than historyKeep are removed from the
+	// results index. When zero, the history is kept forever.
+	historyKeep time.Duration
+}
+
+// retention says which suites are kept: suites newer than keep, and at least
+// the keepMin latest suites. A negative keepMin means the default minimum applies.
+type retention struct {
+	keep    time.Duration
+	keepMin int
+}
+
+func (cfg *gcConfig) retention(sim string) retention {
+	r, ok := cfg.simKeep[sim]
+	if !ok {
+		return cfg.keep
+	}
+	if r.keepMin < 0 {
+		r.keepMin = cfg.keep.keepMin
+	}
+	return r
+}
+
+// longestKeep returns the longest retention period of all policies.
+func (cfg *gcConfig) longestKeep() time.Duration {
+	keep := cfg.keep.keep
+	for _, r := range cfg.simKeep {
+		keep = max(keep, r.keep)
+	}
+	return keep
+}
+
+// verb returns the verb of the summary lines.
+func (cfg *gcConfig) verb(action string) string {
+	if cfg.dryRun {
+		return "would have " + action
+	}
+	return action
+}
+
+func logdirGC(dir string, cfg gcConfig) error {
 	var (
 		fsys       = os.DirFS(dir)
 		usedFiles  = make(map[string]struct{})
-		keptSuites = 0
+		keptSuites = make(map[string]int) // by simulator
 		oldest     time.Time
+		// compressible holds the start time of the newest suite using each log file.
+		compressible = make(map[string]time.Time)
 	)
+	useLog := func(file string, start time.Time) {
+		usedFiles[file] = struct{}{}
+		if start.After(compressible[file]) {
+			compressible[file] = start
+		}
+	}
 
-	// Avoid deleting the status/version file.
+	// Avoid deleting the status/version file and the results index.
 	usedFiles["hive.json"] = struct{}{}
+	usedFiles[indexFile] = struct{}{}
 
 	// Walk all suite files and pouplate the usedFiles set.
 	err := walkSummaryFiles(fsys, ".", func(suite *libhive.TestSuite, fi fs.FileInfo) error {
 		// Skip when too old and when above the minimum.
 		// Note we rely on getting called in descending time order here.
-		if suiteStart(suite).Before(cutoff) && keptSuites >= keepMin {
+		start := suiteStart(suite)
+		policy := cfg.retention(suite.Simulator)
+		if start.Before(cfg.now.Add(-policy.keep)) && keptSuites[suite.Simulator] >= policy.keepMin {
 			return nil
 		}
-		if oldest.IsZero() || suiteStart(suite).Before(oldest) {
-			oldest = suiteStart(suite)
+		if oldest.IsZero() || start.Before(oldest) {
+			oldest = start
 		}
 
 		// Add suite files and client logs.
-		keptSuites++
+		keptSuites[suite.Simulator]++
 		usedFiles[fi.Name()] = struct{}{}
-		usedFiles[suite.SimulatorLog] = struct{}{}
+		useLog(suite.SimulatorLog, start)
 		if suite.TestDetailsLog != "" {
-			usedFiles[suite.TestDetailsLog] = struct{}{}
+			useLog(suite.TestDetailsLog, start)
+		}
+		if suite.APIRecording != "" {
+			useLog(suite.APIRecording, start)
 		}
 		for _, test := range suite.TestCases {
+			if test.SimulatorLog != "" {
+				useLog(test.SimulatorLog, start)
+			}
 			for _, client := range test.ClientInfo {
-				usedFiles[client.LogFile] = struct{}{}
+				useLog(client.LogFile, start)
+			}
+			for _, attempt := range test.Attempts {
+				for _, client := range attempt.ClientInfo {
+					useLog(client.LogFile, start)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keep build logs of runs which are newer than the oldest kept suite, or within the
+	// retention period. Simulator builds use the retention of their simulator, client
+	// builds the longest retention of any simulator.
+	err = walkBuildSummaries(fsys, ".", func(summary *libhive.BuildSummary, start time.Time) error {
+		for _, b := range summary.Images {
+			keep := cfg.longestKeep()
+			if b.Kind == "simulator" {
+				keep = cfg.retention(b.Name).keep
 			}
+			if b.LogFile == "" || (start.Before(cfg.now.Add(-keep)) && start.Before(oldest)) {
+				continue
+			}
+			usedFiles[b.LogFile] = struct{}{}
+			usedFiles[path.Join(path.Dir(b.LogFile), "summary.json")] = struct{}{}
 		}
 		return nil
 	})
@@ -50,27 +149,126 @@ func logdirGC(dir string, cutoff time.Time, keepMin int) error {
 		return err
 	}
 
-	fmt.Printf("keeping %d suites (%d files)\n", keptSuites, len(usedFiles))
+	var total int
+	for _, n := range keptSuites {
+		total += n
+	}
+	fmt.Printf("keeping %d suites (%d files)\n", total, len(usedFiles))
 	fmt.Println("oldest suite date:", oldest)
 
 	// Delete all files which aren't in usedFiles.
-	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+	var deleted, deletedSize int64
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil // Ignore scan errors.
 		}
 		if d.IsDir() {
 			return nil // Don't delete directories.
 		}
-		if _, used := usedFiles[path]; !used {
-			file := filepath.Join(dir, filepath.FromSlash(path))
-			// fmt.Println("rm", file)
-			err := os.Remove(file)
-			if err != nil {
-				fmt.Println("error:", err)
-			}
+		if _, used := usedFiles[strings.TrimSuffix(path, ".gz")]; used {
+			return nil
+		}
+		deleted++
+		if info, err := d.Info(); err == nil {
+			deletedSize += info.Size()
+		}
+		if cfg.dryRun {
+			fmt.Println("would delete", path)
+			return nil
+		}
+		file := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.Remove(file); err != nil {
+			fmt.Println("error:", err)
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %d files (%s)\n", cfg.verb("deleted"), deleted, formatSize(deletedSize))
+
+	// Remove old history from the results index.
+	if !cfg.dryRun {
+		var cutoff time.Time
+		if cfg.historyKeep > 0 {
+			cutoff = cfg.now.Add(-cfg.historyKeep)
+		}
+		removed, err := compactIndex(dir, cutoff)
+		if err != nil {
+			fmt.Println("error: can't compact results index:", err)
+		} else if removed > 0 {
+			fmt.Printf("removed %d records from the results index\n", removed)
+		}
+	}
+
+	// Compress logs of old suites.
+	if cfg.compressAfter == 0 {
+		return nil
+	}
+	var compressed, compressedSize int64
+	compressCutoff := cfg.now.Add(-cfg.compressAfter)
+	for file, newest := range compressible {
+		if file == "" || !newest.Before(compressCutoff) {
+			continue
+		}
+		info, err := fs.Stat(fsys, file)
+		if err != nil {
+			continue // already compressed or missing
+		}
+		if info.Size() > maxCompressSize {
+			fmt.Println("not compressing", file, "because it is too large")
+			continue
+		}
+		compressed++
+		compressedSize += info.Size()
+		if cfg.dryRun {
+			fmt.Println("would compress", file)
+			continue
+		}
+		if err := compressFile(filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+			fmt.Println("error:", err)
+		}
+	}
+	fmt.Printf("%s %d files (%s)\n", cfg.verb("compressed"), compressed, formatSize(compressedSize))
+	return nil
+}
+
+// maxCompressSize is the size limit of compressed files. The uncompressed size is stored
+// modulo 2^32 in the gzip trailer, and serveLogs relies on it.
+const maxCompressSize = 1<<32 - 1
+
+// compressFile replaces file by file.gz.
+func compressFile(file string) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if info, err := in.Stat(); err != nil {
+		return err
+	} else if info.Size() > maxCompressSize {
+		return fmt.Errorf("%s is too large to compress", file)
+	}
+	out, err := os.Create(file + ".gz.tmp")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file+".gz.tmp", file+".gz")
+	}
+	if err != nil {
+		os.Remove(file + ".gz.tmp")
+		return err
+	}
+	return os.Remove(file)
 }
 
 func suiteStart(suite *libhive.TestSuite) time.Time {
@@ -79,3 +277,51 @@ func suiteStart(suite *libhive.TestSuite) time.Time {
 	}
 	return time.Time{}
 }
+
+func formatSize(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/1e6)
+}
+
+// retentionFlag is the -keep-sim flag. It is given as SIMULATOR=DURATION[:KEEPMIN].
+// Durations can also be given in days, e.g. 30d.
+type retentionFlag map[string]retention
+
+func (f retentionFlag) String() string {
+	var rules []string
+	for sim, r := range f {
+		rules = append(rules, fmt.Sprintf("%s=%v:%d", sim, r.keep, r.keepMin))
+	}
+	return strings.Join(rules, ",")
+}
+
+func (f retentionFlag) Set(value string) error {
+	sim, rule, ok := strings.Cut(value, "=")
+	if !ok || sim == "" {
+		return fmt.Errorf("invalid retention rule %q, want SIMULATOR=DURATION[:KEEPMIN]", value)
+	}
+	durationStr, keepMinStr, hasMin := strings.Cut(rule, ":")
+	keep, err := parseDays(durationStr)
+	if err != nil {
+		return err
+	}
+	r := retention{keep: keep, keepMin: -1}
+	if hasMin {
+		if r.keepMin, err = strconv.Atoi(keepMinStr); err != nil {
+			return fmt.Errorf("invalid minimum suite count %q", keepMinStr)
+		}
+	}
+	f[sim] = r
+	return nil
+}
+
+// parseDays parses a duration, which may also be given as a number of days.
+func parseDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * durationDays, nil
+	}
+	return time.ParseDuration(s)
+}
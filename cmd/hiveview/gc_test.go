@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// This test checks per-simulator retention and log compression of -gc, and that
+// compressed logs are served with byte ranges.
+func TestLogdirGC(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	writeSuite := func(n int, sim string, age time.Duration) {
+		start := now.Add(-age).Format(time.RFC3339)
+		files := map[string]string{
+			fmt.Sprintf("%d-suite.json", n): fmt.Sprintf(`{
+				"name": "suite", "simulator": %q, "simLog": "%d-sim.log", "testDetailsLog": "details/%d.log",
+				"testCases": {"1": {"name": "a", "start": %q, "summaryResult": {"pass": true},
+				                    "clientInfo": {"c1": {"name": "go-ethereum", "logFile": "clients/%d.log"}}}}
+			}`, sim, n, n, start, n),
+			fmt.Sprintf("%d-sim.log", n):     "simulator output",
+			fmt.Sprintf("details/%d.log", n): "0123456789abcdef",
+			fmt.Sprintf("clients/%d.log", n): "client output",
+		}
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			os.MkdirAll(filepath.Dir(path), 0755)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		return err == nil
+	}
+
+	writeSuite(1, "a", 40*durationDays)
+	writeSuite(2, "b", 40*durationDays)
+	writeSuite(3, "b", 10*durationDays)
+	writeSuite(4, "b", time.Hour)
+
+	// Build logs are kept according to the retention of their simulator. Client builds
+	// use the longest retention.
+	buildStart := now.Add(-45 * durationDays).Format(time.RFC3339)
+	buildFiles := map[string]string{
+		"builds/1/summary.json": fmt.Sprintf(`{"images": [
+			{"name": "a", "kind": "simulator", "start": %[1]q, "logFile": "builds/1/sim-a.log"},
+			{"name": "b", "kind": "simulator", "start": %[1]q, "logFile": "builds/1/sim-b.log"},
+			{"name": "go-ethereum", "kind": "client", "start": %[1]q, "logFile": "builds/1/client.log"}
+		]}`, buildStart),
+		"builds/1/sim-a.log":  "build output",
+		"builds/1/sim-b.log":  "build output",
+		"builds/1/client.log": "build output",
+	}
+	for name, content := range buildFiles {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := gcConfig{
+		now:           now,
+		keep:          retention{keep: 5 * durationDays, keepMin: 0},
+		simKeep:       map[string]retention{"a": {keep: 60 * durationDays, keepMin: -1}},
+		compressAfter: 7 * durationDays,
+		dryRun:        true,
+	}
+
+	// Dry run doesn't change anything.
+	if err := logdirGC(dir, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !exists("2-suite.json") || !exists("3-sim.log") {
+		t.Fatal("dry run deleted files")
+	}
+
+	cfg.dryRun = false
+	if err := logdirGC(dir, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"2-suite.json", "2-sim.log", "details/2.log", "clients/2.log", "3-suite.json", "clients/3.log", "builds/1/sim-b.log"} {
+		if exists(name) {
+			t.Errorf("%s not deleted", name)
+		}
+	}
+	for _, name := range []string{"1-suite.json", "1-sim.log.gz", "details/1.log.gz", "clients/1.log.gz", "4-suite.json", "4-sim.log", "clients/4.log", "builds/1/summary.json", "builds/1/sim-a.log", "builds/1/client.log"} {
+		if !exists(name) {
+			t.Errorf("%s missing", name)
+		}
+	}
+	if exists("1-sim.log") {
+		t.Error("1-sim.log not compressed")
+	}
+
+	// Compressed logs are served transparently.
+	srv := httptest.NewServer(serveLogs{fsys: os.DirFS(dir)})
+	defer srv.Close()
+	req, _ := http.NewRequest("GET", srv.URL+"/details/1.log", nil)
+	req.Header.Set("Range", "bytes=10-13")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "abcd" {
+		t.Fatalf("wrong range response: %d %q", resp.StatusCode, body)
+	}
+	resp, err = http.Get(srv.URL + "/clients/1.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "client output" {
+		t.Fatalf("wrong content: %q", body)
+	}
+}
+
+// This test checks that files too large for the gzip size field are not compressed.
+func TestCompressFileTooLarge(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "large.log")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(maxCompressSize + 1); err != nil {
+		t.Skip("can't create large file:", err)
+	}
+	f.Close()
+	if err := compressFile(file); err == nil {
+		t.Fatal("no error for large file")
+	}
+	if _, err := os.Stat(file + ".gz"); err == nil {
+		t.Error("compressed file was created")
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Error("original file removed:", err)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// serveLogs serves the log directory. Files compressed by -gc are served as if they
+// were still plain text, including byte ranges.
+type serveLogs struct{ fsys fs.FS }
+
+func (h serveLogs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name != "" {
+		if _, err := fs.Stat(h.fsys, name); errors.Is(err, fs.ErrNotExist) {
+			if info, err := fs.Stat(h.fsys, name+".gz"); err == nil && !info.IsDir() {
+				h.serveCompressed(w, r, name, info)
+				return
+			}
+		}
+	}
+	http.FileServer(http.FS(h.fsys)).ServeHTTP(w, r)
+}
+
+func (h serveLogs) serveCompressed(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo) {
+	rs, err := newGzipReadSeeker(h.fsys, name+".gz")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rs.Close()
+	http.ServeContent(w, r, name, info.ModTime(), rs)
+}
+
+// gzipReadSeeker provides seekable access to the content of a gzip file. The size is
+// taken from the gzip trailer, so this only works for files written by compressFile,
+// which doesn't compress files of 4GB or more. Seeking backwards restarts decompression.
+type gzipReadSeeker struct {
+	fsys fs.FS
+	name string
+	size int64
+
+	file   fs.File
+	zr     *gzip.Reader
+	pos    int64 // position in decompressed stream
+	target int64 // position requested by Seek
+}
+
+func newGzipReadSeeker(fsys fs.FS, name string) (*gzipReadSeeker, error) {
+	size, err := gzipSize(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return &gzipReadSeeker{fsys: fsys, name: name, size: size}, nil
+}
+
+// gzipSize reads the uncompressed size from the gzip trailer.
+func gzipSize(fsys fs.FS, name string) (int64, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		return 0, errors.New("file is not seekable")
+	}
+	if _, err := rs.Seek(-4, io.SeekEnd); err != nil {
+		return 0, err
+	}
+	var trailer [4]byte
+	if _, err := io.ReadFull(rs, trailer[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint32(trailer[:])), nil
+}
+
+func (g *gzipReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += g.target
+	case io.SeekEnd:
+		offset += g.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	g.target = offset
+	return offset, nil
+}
+
+func (g *gzipReadSeeker) Read(p []byte) (int, error) {
+	if g.zr == nil || g.target < g.pos {
+		if err := g.reopen(); err != nil {
+			return 0, err
+		}
+	}
+	if g.target > g.pos {
+		n, err := io.CopyN(io.Discard, g.zr, g.target-g.pos)
+		g.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := g.zr.Read(p)
+	g.pos += int64(n)
+	g.target = g.pos
+	return n, err
+}
+
+func (g *gzipReadSeeker) reopen() error {
+	g.Close()
+	f, err := g.fsys.Open(g.name)
+	if err != nil {
+		return err
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	g.file, g.zr, g.pos = f, zr, 0
+	return nil
+}
+
+func (g *gzipReadSeeker) Close() error {
+	if g.file == nil {
+		return nil
+	}
+	err := g.file.Close()
+	g.file, g.zr = nil, nil
+	return err
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+// indexFile is the name of the results index in the log directory. The index is a JSON
+// lines file with one indexEntry per suite file. New suites are appended to it, and
+// entries of suite files deleted by -gc remain available for the test history until
+// they are older than -keep-history. The file is rewritten by compactIndex.
+const indexFile = "results-index.jsonl"
+
+// indexEntry is the index record of a suite file.
+type indexEntry struct {
+	File      string        `json:"file"`
+	Simulator string        `json:"simulator,omitempty"`
+	Listing   listingEntry  `json:"listing"`
+	Tests     []indexedTest `json:"tests"`
+}
+
+// indexedTest is the outcome of a test case.
+type indexedTest struct {
+	ID      libhive.TestID `json:"id"`
+	Name    string         `json:"name"`
+	Clients []string       `json:"clients,omitempty"`
+	Start   time.Time      `json:"start"`
+	Pass    bool           `json:"pass"`
+	Flaky   bool           `json:"flaky,omitempty"`
+	Timeout bool           `json:"timeout,omitempty"`
+}
+
+func newIndexEntry(suite *libhive.TestSuite, fi fs.FileInfo) *indexEntry {
+	e := &indexEntry{
+		File:      fi.Name(),
+		Simulator: suite.Simulator,
+		Listing:   suiteToEntry(suite, fi),
+		Tests:     make([]indexedTest, 0, len(suite.TestCases)),
+	}
+	for id, test := range suite.TestCases {
+		t := indexedTest{
+			ID:      id,
+			Name:    test.Name,
+			Start:   test.Start,
+			Pass:    test.SummaryResult.Pass,
+			Flaky:   test.SummaryResult.Flaky,
+			Timeout: test.SummaryResult.Timeout,
+		}
+		for _, client := range test.ClientInfo {
+			if !slices.Contains(t.Clients, client.Name) {
+				t.Clients = append(t.Clients, client.Name)
+			}
+		}
+		sort.Strings(t.Clients)
+		e.Tests = append(e.Tests, t)
+	}
+	sort.Slice(e.Tests, func(i, j int) bool { return e.Tests[i].ID < e.Tests[j].ID })
+	return e
+}
+
+// resultsIndex is the in-memory form of the index file.
+type resultsIndex struct {
+	entries []*indexEntry
+	byFile  map[string]*indexEntry
+	stale   int // duplicate or invalid records in the file
+}
+
+func (idx *resultsIndex) add(e *indexEntry) bool {
+	if _, ok := idx.byFile[e.File]; ok {
+		return false
+	}
+	idx.entries = append(idx.entries, e)
+	idx.byFile[e.File] = e
+	return true
+}
+
+// readIndex loads the results index in dir. If there is no index, it returns an empty
+// index. A truncated last record, e.g. from an interrupted update, is ignored.
+func readIndex(fsys fs.FS, dir string) (*resultsIndex, error) {
+	idx := &resultsIndex{byFile: make(map[string]*indexEntry)}
+	f, err := fsys.Open(path.Join(dir, indexFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return idx, nil
+	} else if err != nil {
+		return idx, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		e := new(indexEntry)
+		err := dec.Decode(e)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			log.Printf("Ignoring invalid results index record: %v", err)
+			idx.stale++
+			break
+		}
+		if !idx.add(e) {
+			idx.stale++
+		}
+	}
+	return idx, nil
+}
+
+// updateIndex adds all suite files in dir which are not in the index yet.
+// It returns the number of added suites.
+func updateIndex(dir string) (int, error) {
+	fsys := os.DirFS(dir)
+	idx, err := readIndex(fsys, ".")
+	if err != nil {
+		return 0, err
+	}
+	names, err := summaryFileNames(fsys, ".")
+	if err != nil {
+		return 0, err
+	}
+
+	// Add files oldest-first, so the index is in chronological order.
+	var added []*indexEntry
+	for i := len(names) - 1; i >= 0; i-- {
+		if idx.byFile[names[i]] != nil {
+			continue
+		}
+		if suite, fi := parseSuite(fsys, names[i]); suite != nil {
+			added = append(added, newIndexEntry(suite, fi))
+		}
+	}
+	if idx.stale > 0 {
+		return len(added), writeIndex(dir, append(idx.entries, added...))
+	}
+	return len(added), appendIndex(dir, added)
+}
+
+// appendIndex adds entries to the index file in dir.
+func appendIndex(dir string, entries []*indexEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(filepath.Join(dir, indexFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// writeIndex replaces the index file in dir.
+func writeIndex(dir string, entries []*indexEntry) error {
+	tmp := filepath.Join(dir, indexFile+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		if err = enc.Encode(e); err != nil {
+			break
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(dir, indexFile))
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// compactIndex rewrites the index file in dir without duplicate and invalid records.
+// Entries of deleted suite files which started before cutoff are dropped. It returns
+// the number of removed records.
+func compactIndex(dir string, cutoff time.Time) (int, error) {
+	fsys := os.DirFS(dir)
+	idx, err := readIndex(fsys, ".")
+	if err != nil {
+		return 0, err
+	}
+	names, err := summaryFileNames(fsys, ".")
+	if err != nil {
+		return 0, err
+	}
+	exists := make(map[string]bool, len(names))
+	for _, name := range names {
+		exists[name] = true
+	}
+	kept := slices.DeleteFunc(slices.Clone(idx.entries), func(e *indexEntry) bool {
+		return !exists[e.File] && e.Listing.Start.Before(cutoff)
+	})
+	removed := idx.stale + len(idx.entries) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, writeIndex(dir, kept)
+}
+
+// resultsStore keeps the results index in memory for the server. The index is loaded
+// once, and refresh adds new suite files to it. When the store has a log directory,
+// new entries are also appended to the index file.
+type resultsStore struct {
+	fsys fs.FS
+	dir  string // log directory for index updates, empty for read-only use
+	id   int64  // distinguishes snapshot versions of different server processes
+
+	mu      sync.Mutex             // serializes refresh
+	invalid map[string]fs.FileInfo // suite files which can't be parsed
+	current atomic.Pointer[resultsSnapshot]
+}
+
+// resultsSnapshot is an immutable state of the results store.
+type resultsSnapshot struct {
+	version int
+	idx     *resultsIndex
+	names   []string        // indexed suite files which exist, newest first
+	exists  map[string]bool // same as names
+
+	matrixOnce  sync.Once
+	matrixCache *compatMatrix
+}
+
+func newResultsStore(fsys fs.FS, dir string) *resultsStore {
+	return &resultsStore{
+		fsys:    fsys,
+		dir:     dir,
+		id:      time.Now().UnixNano(),
+		invalid: make(map[string]fs.FileInfo),
+	}
+}
+
+// snapshot returns the current state. It must not be called before the first refresh.
+func (s *resultsStore) snapshot() *resultsSnapshot {
+	return s.current.Load()
+}
+
+// etag returns the HTTP entity tag of responses computed from snap.
+func (s *resultsStore) etag(snap *resultsSnapshot) string {
+	return fmt.Sprintf(`"%x-%d"`, s.id, snap.version)
+}
+
+// notModified sets the ETag of a response computed from snap. It returns true, and
+// responds with status 304, when the client has the current response already.
+func (s *resultsStore) notModified(w http.ResponseWriter, r *http.Request, snap *resultsSnapshot) bool {
+	etag := s.etag(snap)
+	w.Header().Set("etag", etag)
+	if r.Header.Get("if-none-match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// refresh indexes new suite files and updates the set of existing files.
+func (s *resultsStore) refresh() (*resultsSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := s.current.Load()
+	first := prev == nil
+	if first {
+		idx, err := readIndex(s.fsys, ".")
+		if err != nil {
+			return nil, err
+		}
+		if idx.stale > 0 && s.dir != "" {
+			if err := writeIndex(s.dir, idx.entries); err != nil {
+				log.Printf("Can't compact results index: %v", err)
+			}
+		}
+		prev = &resultsSnapshot{idx: idx}
+	}
+	names, err := summaryFileNames(s.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse new files oldest-first, so the index stays in chronological order.
+	var added []*indexEntry
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		if prev.idx.byFile[name] != nil || !s.changed(name) {
+			continue
+		}
+		suite, fi := parseSuite(s.fsys, name)
+		if suite == nil {
+			if info, err := fs.Stat(s.fsys, name); err == nil {
+				s.invalid[name] = info
+			}
+			continue
+		}
+		delete(s.invalid, name)
+		added = append(added, newIndexEntry(suite, fi))
+	}
+
+	idx := prev.idx
+	if len(added) > 0 {
+		if s.dir != "" {
+			if err := appendIndex(s.dir, added); err != nil {
+				log.Printf("Can't update results index: %v", err)
+			}
+		}
+		idx = &resultsIndex{
+			entries: append(slices.Clip(prev.idx.entries), added...),
+			byFile:  maps.Clone(prev.idx.byFile),
+		}
+		for _, e := range added {
+			idx.byFile[e.File] = e
+		}
+	}
+	snap := &resultsSnapshot{idx: idx, exists: make(map[string]bool, len(names))}
+	for _, name := range names {
+		if idx.byFile[name] != nil {
+			snap.names = append(snap.names, name)
+			snap.exists[name] = true
+		}
+	}
+	if !first && len(added) == 0 && slices.Equal(snap.names, prev.names) {
+		return prev, nil
+	}
+	snap.version = prev.version + 1
+	s.current.Store(snap)
+	return snap, nil
+}
+
+// changed reports whether a file which couldn't be parsed was modified since.
+func (s *resultsStore) changed(name string) bool {
+	prev, ok := s.invalid[name]
+	if !ok {
+		return true
+	}
+	info, err := fs.Stat(s.fsys, name)
+	return err == nil && (info.Size() != prev.Size() || !info.ModTime().Equal(prev.ModTime()))
+}
+
+// run refreshes the store periodically. After each change, it sends to notify without
+// blocking, so notify should be buffered.
+func (s *resultsStore) run(interval time.Duration, notify chan<- struct{}) {
+	for range time.Tick(interval) {
+		prev := s.snapshot()
+		snap, err := s.refresh()
+		if err != nil {
+			log.Printf("Can't refresh results: %v", err)
+			continue
+		}
+		if snap != prev && notify != nil {
+			select {
+			case notify <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// testHistory is the past results of a test.
+type testHistory struct {
+	Simulator string         `json:"simulator,omitempty"`
+	Suite     string         `json:"suite"`
+	Test      string         `json:"test"`
+	Runs      []historyRun   `json:"runs"` // newest first
+	Clients   []historyStats `json:"clients"`
+}
+
+// historyRun is a single run of the test against a client. Tests running against
+// multiple clients appear once for each client.
+type historyRun struct {
+	File      string         `json:"file"`
+	Available bool           `json:"available"` // false if the suite file was deleted
+	Simulator string         `json:"simulator,omitempty"`
+	TestID    libhive.TestID `json:"testID"`
+	Client    string         `json:"client"`
+	Start     time.Time      `json:"start"`
+	Pass      bool           `json:"pass"`
+	Flaky     bool           `json:"flaky,omitempty"`
+	Timeout   bool           `json:"timeout,omitempty"`
+}
+
+// historyStats summarizes the runs of the test against one client.
+//
+// A run is counted as flaky when the test passed only after retries, or when its outcome
+// differs from the previous run against the same client.
+type historyStats struct {
+	Client    string  `json:"client"`
+	Runs      int     `json:"runs"`
+	Passes    int     `json:"passes"`
+	Fails     int     `json:"fails"`
+	Flaky     int     `json:"flaky"`
+	PassRate  float64 `json:"passRate"`
+	FlakeRate float64 `json:"flakeRate"`
+}
+
+// buildHistory collects the runs of a test from the index. When sim is empty,
+// suites of all simulators are considered.
+func buildHistory(idx *resultsIndex, exists map[string]bool, sim, suite, test string) *testHistory {
+	h := &testHistory{Simulator: sim, Suite: suite, Test: test, Runs: []historyRun{}, Clients: []historyStats{}}
+	for _, e := range idx.entries {
+		if e.Listing.Name != suite || (sim != "" && e.Simulator != sim) {
+			continue
+		}
+		for _, t := range e.Tests {
+			if t.Name != test {
+				continue
+			}
+			clients := t.Clients
+			if len(clients) == 0 {
+				clients = []string{""}
+			}
+			for _, client := range clients {
+				h.Runs = append(h.Runs, historyRun{
+					File:      e.File,
+					Available: exists[e.File],
+					Simulator: e.Simulator,
+					TestID:    t.ID,
+					Client:    client,
+					Start:     t.Start,
+					Pass:      t.Pass,
+					Flaky:     t.Flaky,
+					Timeout:   t.Timeout,
+				})
+			}
+		}
+	}
+
+	// Compute statistics in chronological order.
+	sort.SliceStable(h.Runs, func(i, j int) bool { return h.Runs[i].Start.Before(h.Runs[j].Start) })
+	stats := make(map[string]*historyStats)
+	last := make(map[string]bool)
+	for _, run := range h.Runs {
+		s := stats[run.Client]
+		if s == nil {
+			s = &historyStats{Client: run.Client}
+			stats[run.Client] = s
+		}
+		prev, seen := last[run.Client]
+		if run.Flaky || (seen && prev != run.Pass) {
+			s.Flaky++
+		}
+		last[run.Client] = run.Pass
+		s.Runs++
+		if run.Pass {
+			s.Passes++
+		} else {
+			s.Fails++
+		}
+	}
+	for _, s := range stats {
+		s.PassRate = float64(s.Passes) / float64(s.Runs)
+		s.FlakeRate = float64(s.Flaky) / float64(s.Runs)
+		h.Clients = append(h.Clients, *s)
+	}
+	sort.Slice(h.Clients, func(i, j int) bool { return h.Clients[i].Client < h.Clients[j].Client })
+	slices.Reverse(h.Runs)
+	return h
+}
+
+type serveHistory struct{ store *resultsStore }
+
+func (h serveHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	suite, test := q.Get("suite"), q.Get("test")
+	if suite == "" || test == "" {
+		http.Error(w, "suite and test parameters are required", http.StatusBadRequest)
+		return
+	}
+	snap := h.store.snapshot()
+	if h.store.notModified(w, r, snap) {
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("cache-control", "no-cache")
+	json.NewEncoder(w).Encode(buildHistory(snap.idx, snap.exists, q.Get("sim"), suite, test))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// This test checks that the results index is updated incrementally and that
+// the test history is computed from it.
+func TestIndexHistory(t *testing.T) {
+	dir := t.TempDir()
+	writeSuite := func(n int, pass bool, flaky bool) {
+		content := fmt.Sprintf(`{
+			"name": "suite", "simulator": "sim", "simLog": "%d-sim.log",
+			"testCases": {
+				"1": {"name": "a", "start": "2024-01-0%dT10:00:00Z", "summaryResult": {"pass": %t, "flaky": %t},
+				      "clientInfo": {"c1": {"name": "go-ethereum"}}},
+				"2": {"name": "b", "start": "2024-01-0%dT10:00:00Z", "summaryResult": {"pass": true}}
+			}
+		}`, n, n, pass, flaky, n)
+		name := filepath.Join(dir, fmt.Sprintf("%d-suite.json", n))
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeSuite(1, true, false)
+	writeSuite(2, false, false)
+	if added, err := updateIndex(dir); err != nil || added != 2 {
+		t.Fatalf("first update: added %d, err %v", added, err)
+	}
+	writeSuite(3, true, true)
+	if added, err := updateIndex(dir); err != nil || added != 1 {
+		t.Fatalf("second update: added %d, err %v", added, err)
+	}
+	if added, err := updateIndex(dir); err != nil || added != 0 {
+		t.Fatalf("third update: added %d, err %v", added, err)
+	}
+
+	// Indexed suites remain in the history after their file is deleted,
+	// and the listing uses the index instead of parsing suite files.
+	os.Remove(filepath.Join(dir, "1-suite.json"))
+	os.WriteFile(filepath.Join(dir, "2-suite.json"), []byte("invalid"), 0644)
+	var listing bytes.Buffer
+	if err := generateListing(os.DirFS(dir), ".", &listing, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(listing.String(), "\n"); n != 2 {
+		t.Fatalf("wrong number of listing entries: %d\n%s", n, listing.String())
+	}
+
+	snap, err := newResultsStore(os.DirFS(dir), "").refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, exists := snap.idx, snap.exists
+	h := buildHistory(idx, exists, "sim", "suite", "a")
+	if len(h.Runs) != 3 {
+		t.Fatalf("wrong number of runs: %d", len(h.Runs))
+	}
+	if h.Runs[0].File != "3-suite.json" || h.Runs[2].File != "1-suite.json" || h.Runs[2].Available {
+		t.Errorf("wrong runs: %+v", h.Runs)
+	}
+	want := historyStats{Client: "go-ethereum", Runs: 3, Passes: 2, Fails: 1, Flaky: 2, PassRate: 2.0 / 3, FlakeRate: 2.0 / 3}
+	if len(h.Clients) != 1 || h.Clients[0] != want {
+		t.Errorf("wrong stats: %+v", h.Clients)
+	}
+	if h := buildHistory(idx, exists, "othersim", "suite", "a"); len(h.Runs) != 0 {
+		t.Errorf("runs of other simulator included: %+v", h.Runs)
+	}
+}
+
+// This test checks that the results store indexes new suite files on refresh and
+// appends them to the index file.
+func TestResultsStore(t *testing.T) {
+	dir := t.TempDir()
+	writeSuite := func(n int) {
+		content := fmt.Sprintf(`{
+			"name": "suite", "simLog": "%d-sim.log",
+			"testCases": {"1": {"name": "a", "start": "2024-01-0%dT10:00:00Z", "summaryResult": {"pass": true}}}
+		}`, n, n)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d-suite.json", n)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeSuite(1)
+	os.WriteFile(filepath.Join(dir, "2-suite.json"), []byte("incomplete"), 0644)
+
+	store := newResultsStore(os.DirFS(dir), dir)
+	snap, err := store.refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(snap.names, []string{"1-suite.json"}) {
+		t.Fatalf("wrong suite files after first refresh: %v", snap.names)
+	}
+	if again, _ := store.refresh(); again != snap {
+		t.Error("refresh without changes created a new snapshot")
+	}
+
+	// The rewritten file is indexed, and the deleted one stays in the index.
+	writeSuite(2)
+	os.Remove(filepath.Join(dir, "1-suite.json"))
+	snap2, err := store.refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(snap2.names, []string{"2-suite.json"}) || len(snap2.idx.entries) != 2 {
+		t.Fatalf("wrong state after second refresh: names %v, %d entries", snap2.names, len(snap2.idx.entries))
+	}
+	if store.etag(snap) == store.etag(snap2) {
+		t.Error("etag did not change")
+	}
+	idx, err := readIndex(os.DirFS(dir), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.entries) != 2 || idx.stale != 0 {
+		t.Errorf("wrong index file: %d entries, %d stale", len(idx.entries), idx.stale)
+	}
+}
+
+// This test checks that compaction removes duplicate records and old entries of
+// deleted suites.
+func TestCompactIndex(t *testing.T) {
+	dir := t.TempDir()
+	record := func(file string, day int) string {
+		return fmt.Sprintf(`{"file":%q,"listing":{"start":"2024-01-0%dT10:00:00Z"},"tests":[]}`+"\n", file, day)
+	}
+	content := record("1-suite.json", 1) + record("2-suite.json", 2) + record("2-suite.json", 2) + record("3-suite.json", 3)
+	os.WriteFile(filepath.Join(dir, indexFile), []byte(content), 0644)
+	os.WriteFile(filepath.Join(dir, "3-suite.json"), []byte("{}"), 0644)
+
+	removed, err := compactIndex(dir, time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("removed %d records, want 3", removed)
+	}
+	idx, _ := readIndex(os.DirFS(dir), ".")
+	if len(idx.entries) != 1 || idx.entries[0].File != "3-suite.json" || idx.stale != 0 {
+		t.Errorf("wrong index after compaction: %d entries, %d stale", len(idx.entries), idx.stale)
+	}
+}
@@ -16,19 +16,53 @@ import (
 )
 
 // generateListing processes hive simulation output files and generates a listing file.
+// Suite files contained in the results index are not parsed again. A limit of zero
+// lists all suites.
 func generateListing(fsys fs.FS, dir string, output io.Writer, limit int) error {
+	idx, err := readIndex(fsys, dir)
+	if err != nil {
+		log.Printf("Can't read results index: %v", err)
+	}
+	names, err := summaryFileNames(fsys, dir)
+	if err != nil {
+		return err
+	}
+	return writeListing(fsys, dir, idx, names, output, limit)
+}
+
+// writeListing writes the listing of the given suite files, which must be sorted
+// newest-first. Files which are not in idx are parsed.
+func writeListing(fsys fs.FS, dir string, idx *resultsIndex, names []string, output io.Writer, limit int) error {
 	var (
 		stop    = errors.New("stop")
 		entries []listingEntry
+		full    = func() bool { return limit > 0 && len(entries) >= limit }
 	)
-	// The files are walked in name order high->low. So to get the latest 200 items, we
+	// The files are in name order high->low. So to get the latest 200 items, we
 	// just need to keep going until we have 200.
-	err := walkSummaryFiles(fsys, dir, func(suite *libhive.TestSuite, fi fs.FileInfo) error {
-		entry := suiteToEntry(suite, fi)
-		entries = append(entries, entry)
-		if len(entries) >= limit {
+	for _, name := range names {
+		if full() {
+			break
+		}
+		if e := idx.byFile[name]; e != nil {
+			entries = append(entries, e.Listing)
+			continue
+		}
+		if suite, fi := parseSuite(fsys, path.Join(dir, name)); suite != nil {
+			entries = append(entries, suiteToEntry(suite, fi))
+		}
+	}
+
+	// Add images which failed to build.
+	err := walkBuildSummaries(fsys, dir, func(summary *libhive.BuildSummary, start time.Time) error {
+		if full() {
 			return stop
 		}
+		for _, b := range summary.Images {
+			if b.Failed() {
+				entries = append(entries, buildToEntry(&b))
+			}
+		}
 		return nil
 	})
 	if err != nil && err != stop {
@@ -37,6 +71,9 @@ func generateListing(fsys fs.FS, dir string, output io.Writer, limit int) error
 
 	// Write listing JSON lines to output.
 	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Start.Equal(entries[j].Start) {
+			return entries[i].Start.After(entries[j].Start)
+		}
 		return entries[i].SimLog > entries[j].SimLog
 	})
 	enc := json.NewEncoder(output)
@@ -58,6 +95,7 @@ type listingEntry struct {
 	// Info about this run.
 	Passes   int               `json:"passes"`
 	Fails    int               `json:"fails"`
+	Flaky    int               `json:"flaky,omitempty"` // passing tests which needed retries
 	Timeout  bool              `json:"timeout"`
 	Clients  []string          `json:"clients"`  // client names involved in this run
 	Versions map[string]string `json:"versions"` // client versions
@@ -65,6 +103,21 @@ type listingEntry struct {
 	FileName string            `json:"fileName"` // hive output file
 	Size     int64             `json:"size"`     // size of hive output file
 	SimLog   string            `json:"simLog"`   // simulator log file
+	Metrics  []listingMetric   `json:"metrics,omitempty"`
+
+	// For images which failed to build, this has the build error and log.
+	BuildFailed bool   `json:"buildFailed,omitempty"`
+	BuildError  string `json:"buildError,omitempty"`
+	BuildLog    string `json:"buildLog,omitempty"`
+}
+
+// listingMetric is a metric reported by a test in the suite.
+type listingMetric struct {
+	Test   string  `json:"test"`
+	Client string  `json:"client,omitempty"` // set when the test ran against a single client type
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit,omitempty"`
 }
 
 func suiteToEntry(s *libhive.TestSuite, file fs.FileInfo) listingEntry {
@@ -80,6 +133,9 @@ func suiteToEntry(s *libhive.TestSuite, file fs.FileInfo) listingEntry {
 		e.NTests++
 		if test.SummaryResult.Pass {
 			e.Passes++
+			if test.SummaryResult.Flaky {
+				e.Flaky++
+			}
 		} else {
 			e.Fails++
 		}
@@ -94,27 +150,111 @@ func suiteToEntry(s *libhive.TestSuite, file fs.FileInfo) listingEntry {
 				e.Clients = append(e.Clients, client.Name)
 			}
 		}
+		for _, attempt := range test.Attempts {
+			for _, client := range attempt.ClientInfo {
+				if !slices.Contains(e.Clients, client.Name) {
+					e.Clients = append(e.Clients, client.Name)
+				}
+			}
+		}
+		if len(test.Metrics) > 0 {
+			client := testClientName(test)
+			for _, m := range test.Metrics {
+				lm := listingMetric{Test: test.Name, Client: client, Name: m.Name, Value: m.Value, Unit: m.Unit}
+				e.Metrics = append(e.Metrics, lm)
+			}
+		}
 	}
+	sort.Slice(e.Metrics, func(i, j int) bool {
+		a, b := e.Metrics[i], e.Metrics[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Test < b.Test
+	})
 	return e
 }
 
-type suiteCB func(*libhive.TestSuite, fs.FileInfo) error
+// testClientName returns the client type used by a test. If the test ran
+// against multiple client types or none, it returns the empty string.
+func testClientName(test *libhive.TestCase) string {
+	var name string
+	for _, client := range test.ClientInfo {
+		if name != "" && client.Name != name {
+			return ""
+		}
+		name = client.Name
+	}
+	return name
+}
 
-func walkSummaryFiles(fsys fs.FS, dir string, proc suiteCB) error {
-	logfiles, err := fs.ReadDir(fsys, dir)
-	if err != nil {
+// buildToEntry creates the listing entry of a failed image build. Failed client builds
+// are listed under the name "client build", simulators under their own name.
+func buildToEntry(b *libhive.ImageBuild) listingEntry {
+	e := listingEntry{
+		Name:        b.Name,
+		Clients:     make([]string, 0),
+		Start:       b.Start,
+		BuildFailed: true,
+		BuildError:  b.Error,
+		BuildLog:    b.LogFile,
+	}
+	if b.Kind == "client" {
+		e.Name = "client build"
+		e.Clients = append(e.Clients, b.Name)
+	}
+	return e
+}
+
+type buildCB func(summary *libhive.BuildSummary, start time.Time) error
+
+// walkBuildSummaries calls proc for the build summary of each hive run, newest first.
+func walkBuildSummaries(fsys fs.FS, dir string, proc buildCB) error {
+	buildDirs, err := fs.ReadDir(fsys, path.Join(dir, "builds"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
 		return err
 	}
-	// Sort by name newest-first.
-	sort.Slice(logfiles, func(i, j int) bool {
-		return logfiles[i].Name() > logfiles[j].Name()
+	sort.Slice(buildDirs, func(i, j int) bool {
+		return buildDirs[i].Name() > buildDirs[j].Name()
 	})
 
-	for _, entry := range logfiles {
-		name := entry.Name()
-		if entry.IsDir() || !strings.HasSuffix(name, ".json") || skipFile(name) {
+	for _, entry := range buildDirs {
+		if !entry.IsDir() {
+			continue
+		}
+		file := path.Join(dir, "builds", entry.Name(), "summary.json")
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
 			continue
 		}
+		var summary libhive.BuildSummary
+		if err := json.Unmarshal(content, &summary); err != nil {
+			log.Printf("Skipping invalid build summary %s: %v", file, err)
+			continue
+		}
+		var start time.Time
+		for _, b := range summary.Images {
+			if start.IsZero() || b.Start.Before(start) {
+				start = b.Start
+			}
+		}
+		if err := proc(&summary, start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type suiteCB func(*libhive.TestSuite, fs.FileInfo) error
+
+func walkSummaryFiles(fsys fs.FS, dir string, proc suiteCB) error {
+	names, err := summaryFileNames(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
 		suite, fileInfo := parseSuite(fsys, path.Join(dir, name))
 		if suite != nil {
 			if err := proc(suite, fileInfo); err != nil {
@@ -125,6 +265,25 @@ func walkSummaryFiles(fsys fs.FS, dir string, proc suiteCB) error {
 	return nil
 }
 
+// summaryFileNames returns the names of all suite files in dir, newest first.
+func summaryFileNames(fsys fs.FS, dir string) ([]string, error) {
+	logfiles, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range logfiles {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") || skipFile(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	// Sort by name newest-first.
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
 func parseSuite(fsys fs.FS, path string) (*libhive.TestSuite, fs.FileInfo) {
 	file, err := fsys.Open(path)
 	if err != nil {
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// This test checks that failed image builds are included in the listing.
+func TestListingBuildFailures(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1000-sim.json": {Data: []byte(`{
+			"name": "suite", "simLog": "1000-sim.log",
+			"testCases": {"1": {"name": "test", "start": "2024-01-01T10:00:00Z", "summaryResult": {"pass": true}}}
+		}`)},
+		"builds/1000/summary.json": {Data: []byte(`{"images": [
+			{"name": "go-ethereum", "kind": "client", "image": "hive/clients/go-ethereum:latest", "start": "2024-01-01T09:00:00Z"},
+			{"name": "erigon", "kind": "client", "image": "hive/clients/erigon:latest", "start": "2024-01-01T09:00:00Z",
+			 "error": "build failed", "logFile": "builds/1000/clients-erigon.log"}
+		]}`)},
+	}
+	var out bytes.Buffer
+	if err := generateListing(fsys, ".", &out, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []listingEntry
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var e listingEntry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("wrong number of entries: %d", len(entries))
+	}
+	if entries[0].Name != "suite" || entries[0].BuildFailed {
+		t.Errorf("wrong first entry: %+v", entries[0])
+	}
+	build := entries[1]
+	if !build.BuildFailed || build.BuildLog != "builds/1000/clients-erigon.log" || len(build.Clients) != 1 || build.Clients[0] != "erigon" {
+		t.Errorf("wrong build failure entry: %+v", build)
+	}
+}
+
+// This test checks that serving the listing records the generation time.
+func TestListingMetrics(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1000-sim.json": {Data: []byte(`{"name": "suite", "testCases": {}}`)},
+	}
+	store := newResultsStore(fsys, "")
+	if _, err := store.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	before := listingTimeCount(t)
+	rec := httptest.NewRecorder()
+	serveListing{store: store}.ServeHTTP(rec, httptest.NewRequest("GET", "/listing.jsonl", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("wrong status %d", rec.Code)
+	}
+	if n := listingTimeCount(t); n != before+1 {
+		t.Fatalf("listing time observed %d times, want %d", n, before+1)
+	}
+}
+
+// listingTimeCount returns the number of listing time observations served at /metrics.
+func listingTimeCount(t *testing.T) int {
+	rec := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, "hiveview_listing_seconds_count "); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				t.Fatalf("invalid count %q", v)
+			}
+			return n
+		}
+	}
+	t.Fatalf("listing time missing in metrics:\n%s", rec.Body.String())
+	return 0
+}
@@ -23,16 +23,25 @@ func main() {
 		listing        = flag.Bool("listing", false, "Generates listing JSON to stdout")
 		deploy         = flag.Bool("deploy", false, "Compiles the frontend to a static directory")
 		gc             = flag.Bool("gc", false, "Deletes old log files")
+		index          = flag.Bool("index", false, "Adds new suite files to the results index")
+		merge          = flag.Bool("merge", false, "Merges the result files of a sharded run (given as arguments)")
+		mergeOutput    = flag.String("out", "", "Output `file` name in the log directory (for -merge)")
 		gcKeepInterval = flag.Duration("keep", 5*durationMonth, "Time interval of past log files to keep (for -gc)")
 		gcKeepMin      = flag.Int("keep-min", 10, "Minimum number of suite outputs to keep (for -gc)")
+		gcCompress     = flag.Duration("compress", 0, "Compresses the logs of suites older than the given `duration` (for -gc)")
+		gcDryRun       = flag.Bool("dry-run", false, "Only reports what would be deleted and compressed (for -gc)")
+		gcKeepHistory  = flag.Duration("keep-history", 0, "Time interval of test history to keep in the results index, 0 keeps all (for -gc)")
+		gcKeepSim      = make(retentionFlag)
 		config         serverConfig
-		listLimit      int
 	)
-	flag.IntVar(&listLimit, "limit", 200, "Number of test runs to show in listing")
+	flag.IntVar(&config.listLimit, "limit", 200, "Number of test runs to show in listing (0 shows all)")
+	flag.Var(gcKeepSim, "keep-sim", "Retention for a simulator as `SIMULATOR=DURATION[:KEEPMIN]`, may be repeated (for -gc)")
 	flag.StringVar(&config.listenAddr, "addr", "0.0.0.0:8080", "HTTP server listen address")
 	flag.StringVar(&config.logDir, "logdir", "workspace/logs", "Path to hive simulator log directory")
 	flag.StringVar(&config.assetsDir, "assets", "", "Path to static files directory. Serves baked-in assets when not set.")
 	flag.BoolVar(&config.disableBundle, "assets.nobundle", false, "Disables JS/CSS bundling (for development).")
+	flag.BoolVar(&config.searchClients, "search.clientlogs", false, "Includes client logs in the search index.")
+	flag.DurationVar(&config.refreshInterval, "refresh", 30*time.Second, "Interval of checking for new results (for -serve)")
 	flag.Parse()
 
 	log.SetFlags(log.LstdFlags)
@@ -41,12 +50,34 @@ func main() {
 		runServer(config)
 	case *listing:
 		fsys := os.DirFS(config.logDir)
-		generateListing(fsys, ".", os.Stdout, listLimit)
+		generateListing(fsys, ".", os.Stdout, config.listLimit)
 	case *gc:
-		cutoff := time.Now().Add(-*gcKeepInterval)
-		logdirGC(config.logDir, cutoff, *gcKeepMin)
+		err := logdirGC(config.logDir, gcConfig{
+			now:           time.Now(),
+			keep:          retention{keep: *gcKeepInterval, keepMin: *gcKeepMin},
+			simKeep:       gcKeepSim,
+			compressAfter: *gcCompress,
+			dryRun:        *gcDryRun,
+			historyKeep:   *gcKeepHistory,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	case *index:
+		added, err := updateIndex(config.logDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Added %d suites to the results index", added)
 	case *deploy:
 		doDeploy(&config)
+	case *merge:
+		if *mergeOutput == "" {
+			log.Fatalf("-merge requires -out")
+		}
+		if err := mergeSuites(config.logDir, flag.Args(), *mergeOutput); err != nil {
+			log.Fatal(err)
+		}
 	default:
 		log.Fatalf("Use -serve or -listing to select mode")
 	}
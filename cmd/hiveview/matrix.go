@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// compatMatrix is the latest result of each suite against each client.
+type compatMatrix struct {
+	Clients []string    `json:"clients"`
+	Rows    []matrixRow `json:"rows"`
+}
+
+// matrixRow holds the results of a suite. The cells are keyed by client name.
+type matrixRow struct {
+	Simulator string                 `json:"simulator,omitempty"`
+	Suite     string                 `json:"suite"`
+	Cells     map[string]*matrixCell `json:"cells"`
+}
+
+// matrixCell is the result of the latest run of a suite against a client.
+type matrixCell struct {
+	File     string    `json:"file"`
+	Start    time.Time `json:"start"`
+	Version  string    `json:"version,omitempty"`
+	Tests    int       `json:"tests"`
+	Passes   int       `json:"passes"`
+	PassRate float64   `json:"passRate"`
+}
+
+// buildMatrix computes the compatibility matrix from the suite files which still exist.
+// Only tests which ran against a client are counted for it.
+func buildMatrix(idx *resultsIndex, exists map[string]bool) *compatMatrix {
+	type rowKey struct{ sim, suite string }
+	var (
+		rows    = make(map[rowKey]*matrixRow)
+		clients = make(map[string]bool)
+	)
+	for _, e := range idx.entries {
+		if !exists[e.File] {
+			continue
+		}
+		key := rowKey{e.Simulator, e.Listing.Name}
+		row := rows[key]
+		if row == nil {
+			row = &matrixRow{Simulator: e.Simulator, Suite: e.Listing.Name, Cells: make(map[string]*matrixCell)}
+			rows[key] = row
+		}
+		for client, cell := range suiteClientResults(e) {
+			if prev := row.Cells[client]; prev != nil && !cell.Start.After(prev.Start) {
+				continue
+			}
+			row.Cells[client] = cell
+			clients[client] = true
+		}
+	}
+
+	m := &compatMatrix{Clients: make([]string, 0, len(clients)), Rows: make([]matrixRow, 0, len(rows))}
+	for client := range clients {
+		m.Clients = append(m.Clients, client)
+	}
+	sort.Strings(m.Clients)
+	for _, row := range rows {
+		if len(row.Cells) > 0 {
+			m.Rows = append(m.Rows, *row)
+		}
+	}
+	sort.Slice(m.Rows, func(i, j int) bool {
+		a, b := m.Rows[i], m.Rows[j]
+		if a.Simulator != b.Simulator {
+			return a.Simulator < b.Simulator
+		}
+		return a.Suite < b.Suite
+	})
+	return m
+}
+
+// suiteClientResults computes the result of a suite run for each client
+// listed in its client versions.
+func suiteClientResults(e *indexEntry) map[string]*matrixCell {
+	cells := make(map[string]*matrixCell)
+	for client, version := range e.Listing.Versions {
+		cells[client] = &matrixCell{File: e.File, Start: e.Listing.Start, Version: version}
+	}
+	for _, t := range e.Tests {
+		for _, client := range t.Clients {
+			cell := cells[client]
+			if cell == nil {
+				continue
+			}
+			cell.Tests++
+			if t.Pass {
+				cell.Passes++
+			}
+		}
+	}
+	for client, cell := range cells {
+		if cell.Tests == 0 {
+			delete(cells, client)
+			continue
+		}
+		cell.PassRate = float64(cell.Passes) / float64(cell.Tests)
+	}
+	return cells
+}
+
+// matrix returns the compatibility matrix of the snapshot. It is computed once.
+func (snap *resultsSnapshot) matrix() *compatMatrix {
+	snap.matrixOnce.Do(func() {
+		snap.matrixCache = buildMatrix(snap.idx, snap.exists)
+	})
+	return snap.matrixCache
+}
+
+type serveMatrix struct{ store *resultsStore }
+
+func (h serveMatrix) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	snap := h.store.snapshot()
+	if h.store.notModified(w, r, snap) {
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("cache-control", "no-cache")
+	json.NewEncoder(w).Encode(snap.matrix())
+}
+
+type serveBadge struct{ store *resultsStore }
+
+// ServeHTTP renders the status badge of a suite and client. The simulator
+// parameter is optional.
+func (h serveBadge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sim, suite, client := q.Get("sim"), q.Get("suite"), q.Get("client")
+	if suite == "" || client == "" {
+		http.Error(w, "suite and client parameters are required", http.StatusBadRequest)
+		return
+	}
+	snap := h.store.snapshot()
+	w.Header().Set("cache-control", "max-age=300")
+	if h.store.notModified(w, r, snap) {
+		return
+	}
+
+	var cell *matrixCell
+	for _, row := range snap.matrix().Rows {
+		if row.Suite != suite || (sim != "" && row.Simulator != sim) {
+			continue
+		}
+		if c := row.Cells[client]; c != nil && (cell == nil || c.Start.After(cell.Start)) {
+			cell = c
+		}
+	}
+	w.Header().Set("content-type", "image/svg+xml")
+	w.Write([]byte(renderBadge("hive "+suite, cell)))
+}
+
+// renderBadge creates a badge SVG showing the pass rate of cell.
+func renderBadge(label string, cell *matrixCell) string {
+	message, color := "no data", "#9f9f9f"
+	if cell != nil {
+		message = fmt.Sprintf("%d/%d passing", cell.Passes, cell.Tests)
+		switch {
+		case cell.PassRate == 1:
+			color = "#4c1"
+		case cell.PassRate >= 0.9:
+			color = "#97ca00"
+		case cell.PassRate >= 0.5:
+			color = "#dfb317"
+		default:
+			color = "#e05d44"
+		}
+	}
+
+	// Text width is estimated, badge renderers without font metrics do the same.
+	const charWidth, padding = 7, 10
+	lw := len(label)*charWidth + padding
+	mw := len(message)*charWidth + padding
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[3]s: %[4]s">`+
+		`<title>%[3]s: %[4]s</title>`+
+		`<rect width="%[2]d" height="20" fill="#555"/>`+
+		`<rect x="%[2]d" width="%[5]d" height="20" fill="%[6]s"/>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="14">%[3]s</text><text x="%[8]d" y="14">%[4]s</text></g></svg>`,
+		lw+mw, lw, html.EscapeString(label), html.EscapeString(message), mw, color, lw/2, lw+mw/2)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// This test checks that the matrix shows the latest run of each suite for each client.
+func TestCompatMatrix(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-run.json": {Data: []byte(`{
+			"name": "suite", "simulator": "sim", "simLog": "1-sim.log",
+			"clientVersions": {"go-ethereum": "v1", "besu": "v1"},
+			"testCases": {
+				"1": {"name": "a", "start": "2024-01-01T10:00:00Z", "summaryResult": {"pass": false}, "clientInfo": {"c1": {"name": "go-ethereum"}}},
+				"2": {"name": "a", "start": "2024-01-01T10:00:00Z", "summaryResult": {"pass": true}, "clientInfo": {"c2": {"name": "besu"}}}
+			}
+		}`)},
+		"2-run.json": {Data: []byte(`{
+			"name": "suite", "simulator": "sim", "simLog": "2-sim.log",
+			"clientVersions": {"go-ethereum": "v2"},
+			"testCases": {
+				"1": {"name": "a", "start": "2024-01-02T10:00:00Z", "summaryResult": {"pass": true}, "clientInfo": {"c1": {"name": "go-ethereum"}}},
+				"2": {"name": "b", "start": "2024-01-02T10:00:00Z", "summaryResult": {"pass": false}, "clientInfo": {"c1": {"name": "go-ethereum"}}},
+				"3": {"name": "c", "start": "2024-01-02T10:00:00Z", "summaryResult": {"pass": true}}
+			}
+		}`)},
+	}
+	snap, err := newResultsStore(fsys, "").refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := snap.matrix()
+	if strings.Join(m.Clients, ",") != "besu,go-ethereum" {
+		t.Fatalf("wrong clients: %v", m.Clients)
+	}
+	if len(m.Rows) != 1 {
+		t.Fatalf("wrong number of rows: %d", len(m.Rows))
+	}
+	geth := m.Rows[0].Cells["go-ethereum"]
+	if geth.File != "2-run.json" || geth.Version != "v2" || geth.Tests != 2 || geth.Passes != 1 {
+		t.Errorf("wrong go-ethereum cell: %+v", geth)
+	}
+	besu := m.Rows[0].Cells["besu"]
+	if besu.File != "1-run.json" || besu.Tests != 1 || besu.PassRate != 1 {
+		t.Errorf("wrong besu cell: %+v", besu)
+	}
+
+	badge := renderBadge("hive suite", geth)
+	if !strings.Contains(badge, "1/2 passing") {
+		t.Errorf("wrong badge: %s", badge)
+	}
+}
+
+// This test checks that the matrix and badges are served with caching headers.
+func TestServeMatrixCaching(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-run.json": {Data: []byte(`{
+			"name": "suite", "simLog": "1-sim.log", "clientVersions": {"besu": "v1"},
+			"testCases": {"1": {"name": "a", "summaryResult": {"pass": true}, "clientInfo": {"c1": {"name": "besu"}}}}
+		}`)},
+	}
+	store := newResultsStore(fsys, "")
+	if _, err := store.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		handler http.Handler
+		url     string
+		cache   string
+	}{
+		{serveMatrix{store}, "/matrix.json", "no-cache"},
+		{serveBadge{store}, "/badge.svg?suite=suite&client=besu", "max-age=300"},
+	} {
+		rec := httptest.NewRecorder()
+		test.handler.ServeHTTP(rec, httptest.NewRequest("GET", test.url, nil))
+		etag := rec.Header().Get("etag")
+		if rec.Code != http.StatusOK || etag == "" || rec.Header().Get("cache-control") != test.cache {
+			t.Fatalf("%s: wrong response: status %d, headers %v", test.url, rec.Code, rec.Header())
+		}
+
+		req := httptest.NewRequest("GET", test.url, nil)
+		req.Header.Set("if-none-match", etag)
+		rec = httptest.NewRecorder()
+		test.handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("%s: status %d for current etag, want 304", test.url, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+// mergeSuites combines the result files of a sharded simulation run into a single
+// suite. The input files and the output file are relative to logdir. The test details
+// logs of the inputs are concatenated into a new details log next to the output. The
+// simulator log of the first input becomes the log of the suite, and tests from other
+// inputs link to the simulator log of their shard.
+//
+// Tests which ran in several shards, i.e. AlwaysRun tests and their fixed subtests,
+// are identified by name and parent and included once.
+func mergeSuites(logdir string, files []string, output string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no result files to merge")
+	}
+	detailsPath := "details/" + strings.TrimSuffix(filepath.Base(output), ".json") + ".log"
+	detailsFile, err := createFile(filepath.Join(logdir, filepath.FromSlash(detailsPath)))
+	if err != nil {
+		return err
+	}
+	defer detailsFile.Close()
+
+	var (
+		merged *libhive.TestSuite
+		offset int64
+		seen   = make(map[mergeKey]libhive.TestID) // tests of previous shards
+	)
+	for _, file := range files {
+		suite, err := readSuiteFile(filepath.Join(logdir, file))
+		if err != nil {
+			return err
+		}
+		if merged == nil {
+			merged = &libhive.TestSuite{
+				ID:             suite.ID,
+				Name:           suite.Name,
+				Description:    suite.Description,
+				ClientVersions: make(map[string]string),
+				TestCases:      make(map[libhive.TestID]*libhive.TestCase),
+				RandomSeed:     suite.RandomSeed,
+				SimulatorLog:   suite.SimulatorLog,
+				TestDetailsLog: detailsPath,
+			}
+		} else if suite.Name != merged.Name {
+			return fmt.Errorf("%s: suite %q does not match %q", file, suite.Name, merged.Name)
+		}
+		maps.Copy(merged.ClientVersions, suite.ClientVersions)
+
+		// Append the test details log and rebase the log offsets into it.
+		n, err := appendDetailsLog(detailsFile, logdir, suite.TestDetailsLog)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		for _, test := range suite.TestCases {
+			if suite.SimulatorLog != merged.SimulatorLog {
+				test.SimulatorLog = suite.SimulatorLog
+			}
+			rebaseLogOffsets(&test.SummaryResult, offset)
+			for i := range test.Attempts {
+				rebaseLogOffsets(&test.Attempts[i].SummaryResult, offset)
+			}
+		}
+		offset += n
+
+		// Renumber the tests, keeping them in the order of the shard. Parents start
+		// before their subtests, so they are renumbered first.
+		ids := make([]libhive.TestID, 0, len(suite.TestCases))
+		for id := range suite.TestCases {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		var (
+			newIDs = make(map[libhive.TestID]libhive.TestID, len(ids))
+			added  = make(map[mergeKey]libhive.TestID, len(ids))
+		)
+		for _, id := range ids {
+			test := suite.TestCases[id]
+			if test.Parent != 0 {
+				test.Parent = newIDs[test.Parent]
+			}
+			key := mergeKey{test.Name, test.Parent}
+			if prev, ok := seen[key]; ok {
+				// AlwaysRun tests run in every shard. Keep one copy, preferring a failed one.
+				newIDs[id] = prev
+				if kept := merged.TestCases[prev]; kept.SummaryResult.Pass && !test.SummaryResult.Pass {
+					merged.TestCases[prev] = test
+				}
+				continue
+			}
+			newIDs[id] = libhive.TestID(len(merged.TestCases) + 1)
+			merged.TestCases[newIDs[id]] = test
+			added[key] = newIDs[id]
+		}
+		maps.Copy(seen, added)
+	}
+
+	out, err := createFile(filepath.Join(logdir, output))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return json.NewEncoder(out).Encode(merged)
+}
+
+// mergeKey identifies a test across shards.
+type mergeKey struct {
+	name   string
+	parent libhive.TestID // in the merged suite
+}
+
+func readSuiteFile(file string) (*libhive.TestSuite, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var suite libhive.TestSuite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if !suiteValid(&suite) {
+		return nil, fmt.Errorf("%s: invalid suite file", file)
+	}
+	return &suite, nil
+}
+
+// appendDetailsLog copies the test details log of a suite to w.
+func appendDetailsLog(w io.Writer, logdir, detailsLog string) (int64, error) {
+	if detailsLog == "" {
+		return 0, nil
+	}
+	f, err := os.Open(filepath.Join(logdir, filepath.FromSlash(detailsLog)))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
+
+func rebaseLogOffsets(r *libhive.TestResult, offset int64) {
+	if r.LogOffsets != nil {
+		r.LogOffsets.Begin += offset
+		r.LogOffsets.End += offset
+	}
+}
+
+func createFile(file string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(file)
+}
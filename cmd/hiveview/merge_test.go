@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+// This test checks that merging shard results renumbers tests, rebases log offsets,
+// keeps the simulator log of each shard and includes AlwaysRun tests once.
+func TestMergeSuites(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"1-shard1.json": `{
+			"name": "suite", "simLog": "1-sim.log", "testDetailsLog": "details/1.log",
+			"clientVersions": {"go-ethereum": "v1"},
+			"testCases": {
+				"1": {"name": "launcher", "summaryResult": {"pass": true}},
+				"2": {"name": "x", "parent": 1, "summaryResult": {"pass": true}},
+				"3": {"name": "a", "summaryResult": {"pass": true, "log": {"begin": 0, "end": 2}}},
+				"4": {"name": "a/sub", "parent": 3, "summaryResult": {"pass": true, "log": {"begin": 2, "end": 4}}}
+			}
+		}`,
+		"details/1.log": "a\nb\n",
+		"2-shard2.json": `{
+			"name": "suite", "simLog": "2-sim.log", "testDetailsLog": "details/2.log",
+			"clientVersions": {"besu": "v2"},
+			"testCases": {
+				"1": {"name": "launcher", "summaryResult": {"pass": false}},
+				"2": {"name": "y", "parent": 1, "summaryResult": {"pass": true}},
+				"3": {"name": "b", "summaryResult": {"pass": false, "log": {"begin": 0, "end": 2}}},
+				"4": {"name": "b/sub", "parent": 3, "summaryResult": {"pass": false, "log": {"begin": 2, "end": 4}}}
+			}
+		}`,
+		"details/2.log": "c\nd\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mergeSuites(dir, []string{"1-shard1.json", "2-shard2.json"}, "3-merged.json"); err != nil {
+		t.Fatal("merge failed:", err)
+	}
+	suite, err := readSuiteFile(filepath.Join(dir, "3-merged.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suite.TestCases) != 7 || len(suite.ClientVersions) != 2 {
+		t.Fatalf("wrong merged suite: %d tests, %d clients", len(suite.TestCases), len(suite.ClientVersions))
+	}
+	details, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(suite.TestDetailsLog)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := suite.TestCases[libhive.TestID(7)]
+	if sub.Name != "b/sub" || sub.Parent != 6 {
+		t.Fatalf("wrong subtest in second shard: %+v", sub)
+	}
+	if sub.SimulatorLog != "2-sim.log" || suite.TestCases[3].SimulatorLog != "" {
+		t.Errorf("wrong simulator logs of tests: %q, %q", suite.TestCases[3].SimulatorLog, sub.SimulatorLog)
+	}
+	// The launcher is included once, with the failed result, and has the launched
+	// tests of both shards.
+	launcher := suite.TestCases[1]
+	if launcher.Name != "launcher" || launcher.SummaryResult.Pass {
+		t.Errorf("wrong launcher test: %+v", launcher)
+	}
+	if x, y := suite.TestCases[2], suite.TestCases[5]; x.Name != "x" || x.Parent != 1 || y.Name != "y" || y.Parent != 1 {
+		t.Errorf("wrong launched tests: %+v, %+v", x, y)
+	}
+	offsets := sub.SummaryResult.LogOffsets
+	if log := string(details[offsets.Begin:offsets.End]); log != "d\n" {
+		t.Errorf("wrong log of subtest: %q", log)
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+	maxSearchLine      = 64 * 1024 // longer log lines are skipped
+	maxSnippetLength   = 300
+)
+
+// searchIndex is an in-memory inverted index over test names, descriptions, test output
+// and optionally client logs. It maps words to the documents containing them. The index
+// is updated incrementally in the background: run adds the suite files which appeared
+// in the results store and drops those which were deleted. Searches use the documents
+// indexed so far.
+//
+// Matching lines are found by reading the candidate documents again, so the index only
+// needs to hold the words.
+type searchIndex struct {
+	fsys       fs.FS
+	clientLogs bool
+	files      map[string][]int // suite file -> document IDs, only accessed by update
+
+	mu       sync.RWMutex
+	ready    bool             // true after the first update
+	docs     []*searchDoc     // removed documents are nil
+	postings map[string][]int // word -> document IDs, ascending
+}
+
+// indexedDoc is a document with its words, before it is added to the index.
+type indexedDoc struct {
+	doc   *searchDoc
+	words map[string]struct{}
+}
+
+// searchDoc is a test, or the log of a client in a test.
+type searchDoc struct {
+	file   string // suite file
+	suite  string
+	testID libhive.TestID
+	test   string
+	client string // set for client logs
+
+	description string
+	details     string // inline test output
+	logFile     string // client log or test details log
+	logOffsets  *libhive.TestLogOffsets
+}
+
+// searchResult is a match of a search query.
+type searchResult struct {
+	File    string         `json:"file"`
+	Suite   string         `json:"suite"`
+	TestID  libhive.TestID `json:"testID"`
+	Test    string         `json:"test"`
+	Field   string         `json:"field"` // "name", "description", "details" or "client"
+	Client  string         `json:"client,omitempty"`
+	LogFile string         `json:"logFile,omitempty"` // client log file
+	Line    int            `json:"line,omitempty"`    // line number in the test output or client log
+	Text    string         `json:"text"`
+}
+
+func newSearchIndex(fsys fs.FS, clientLogs bool) *searchIndex {
+	return &searchIndex{
+		fsys:       fsys,
+		clientLogs: clientLogs,
+		files:      make(map[string][]int),
+		postings:   make(map[string][]int),
+	}
+}
+
+// run indexes the suite files of the store, and updates the index whenever the store
+// sends to notify.
+func (idx *searchIndex) run(store *resultsStore, notify <-chan struct{}) {
+	for {
+		idx.update(store.snapshot().names)
+		if _, ok := <-notify; !ok {
+			return
+		}
+	}
+}
+
+// update adds new suite files to the index and removes those which are not in names.
+// Documents are read without holding the lock, so searches can proceed meanwhile.
+func (idx *searchIndex) update(names []string) {
+	exists := make(map[string]bool, len(names))
+	for _, name := range names {
+		exists[name] = true
+	}
+	var removed []int
+	for file, ids := range idx.files {
+		if !exists[file] {
+			removed = append(removed, ids...)
+			delete(idx.files, file)
+		}
+	}
+	idx.remove(removed)
+
+	for _, name := range names {
+		if _, ok := idx.files[name]; ok {
+			continue
+		}
+		suite, _ := parseSuite(idx.fsys, name)
+		if suite == nil {
+			idx.files[name] = nil
+			continue
+		}
+		idx.files[name] = idx.add(idx.readSuite(name, suite))
+	}
+
+	idx.mu.Lock()
+	idx.ready = true
+	idx.mu.Unlock()
+}
+
+// remove drops documents from the index and prunes them from the postings.
+func (idx *searchIndex) remove(ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, id := range ids {
+		idx.docs[id] = nil
+	}
+	for w, postings := range idx.postings {
+		postings = slices.DeleteFunc(postings, func(id int) bool { return idx.docs[id] == nil })
+		if len(postings) == 0 {
+			delete(idx.postings, w)
+		} else {
+			idx.postings[w] = postings
+		}
+	}
+}
+
+// add inserts documents into the index and returns their IDs.
+func (idx *searchIndex) add(docs []indexedDoc) []int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	ids := make([]int, 0, len(docs))
+	for _, d := range docs {
+		id := len(idx.docs)
+		idx.docs = append(idx.docs, d.doc)
+		for w := range d.words {
+			idx.postings[w] = append(idx.postings[w], id)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// readSuite creates the documents of a suite.
+func (idx *searchIndex) readSuite(file string, suite *libhive.TestSuite) []indexedDoc {
+	var docs []indexedDoc
+	add := func(doc *searchDoc) {
+		words := make(map[string]struct{})
+		idx.eachLine(doc, func(field string, line int, text string) bool {
+			for _, w := range searchWords(text) {
+				words[w] = struct{}{}
+			}
+			return true
+		})
+		docs = append(docs, indexedDoc{doc, words})
+	}
+
+	testIDs := make([]libhive.TestID, 0, len(suite.TestCases))
+	for id := range suite.TestCases {
+		testIDs = append(testIDs, id)
+	}
+	sort.Slice(testIDs, func(i, j int) bool { return testIDs[i] < testIDs[j] })
+	for _, testID := range testIDs {
+		test := suite.TestCases[testID]
+		add(&searchDoc{
+			file:        file,
+			suite:       suite.Name,
+			testID:      testID,
+			test:        test.Name,
+			description: test.Description,
+			details:     test.SummaryResult.Details,
+			logFile:     suite.TestDetailsLog,
+			logOffsets:  test.SummaryResult.LogOffsets,
+		})
+		if !idx.clientLogs {
+			continue
+		}
+		for _, client := range test.ClientInfo {
+			if client.LogFile == "" {
+				continue
+			}
+			add(&searchDoc{
+				file:    file,
+				suite:   suite.Name,
+				testID:  testID,
+				test:    test.Name,
+				client:  client.Name,
+				logFile: client.LogFile,
+			})
+		}
+	}
+	return docs
+}
+
+// eachLine calls fn for each line of text in doc, until fn returns false.
+func (idx *searchIndex) eachLine(doc *searchDoc, fn func(field string, line int, text string) bool) {
+	if doc.client != "" {
+		r, err := openLogRange(idx.fsys, doc.logFile, nil)
+		if err != nil {
+			return
+		}
+		defer r.Close()
+		scanLines(r, func(line int, text string) bool { return fn("client", line, text) })
+		return
+	}
+
+	if !fn("name", 0, doc.test) {
+		return
+	}
+	for _, text := range strings.Split(doc.description, "\n") {
+		if !fn("description", 0, text) {
+			return
+		}
+	}
+	switch {
+	case doc.details != "":
+		scanLines(strings.NewReader(doc.details), func(line int, text string) bool { return fn("details", line, text) })
+	case doc.logOffsets != nil && doc.logFile != "":
+		r, err := openLogRange(idx.fsys, doc.logFile, doc.logOffsets)
+		if err != nil {
+			return
+		}
+		defer r.Close()
+		scanLines(r, func(line int, text string) bool { return fn("details", line, text) })
+	}
+}
+
+// search returns the matches of query, newest suites first. All words of the query must
+// appear in a line, in the given order.
+func (idx *searchIndex) search(query string, limit int) ([]searchResult, bool, error) {
+	words := searchWords(query)
+	if len(words) == 0 {
+		return nil, false, errors.New("empty query")
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Intersect the postings of all query words.
+	candidates := idx.postings[words[0]]
+	for _, w := range words[1:] {
+		candidates = intersectSorted(candidates, idx.postings[w])
+	}
+	docs := make([]*searchDoc, 0, len(candidates))
+	for _, id := range candidates {
+		if idx.docs[id] != nil {
+			docs = append(docs, idx.docs[id])
+		}
+	}
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].file > docs[j].file })
+
+	// Find the matching lines.
+	results := []searchResult{}
+	phrase := " " + strings.Join(words, " ") + " "
+	truncated := false
+	for _, doc := range docs {
+		idx.eachLine(doc, func(field string, line int, text string) bool {
+			if !strings.Contains(" "+strings.Join(searchWords(text), " ")+" ", phrase) {
+				return true
+			}
+			if len(results) >= limit {
+				truncated = true
+				return false
+			}
+			res := searchResult{
+				File:   doc.file,
+				Suite:  doc.suite,
+				TestID: doc.testID,
+				Test:   doc.test,
+				Field:  field,
+				Client: doc.client,
+				Line:   line,
+				Text:   snippet(text),
+			}
+			if doc.client != "" {
+				res.LogFile = doc.logFile
+			}
+			results = append(results, res)
+			return true
+		})
+		if truncated {
+			break
+		}
+	}
+	return results, truncated, nil
+}
+
+// searchWords splits text into lower-case words.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func intersectSorted(a, b []int) []int {
+	var out []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// scanLines calls fn for each line in r, with 1-based line numbers.
+func scanLines(r io.Reader, fn func(line int, text string) bool) {
+	br := bufio.NewReaderSize(r, maxSearchLine)
+	for line := 1; ; line++ {
+		text, err := br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// Skip the rest of very long lines.
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = br.ReadSlice('\n')
+			}
+			text = nil
+		}
+		if len(text) > 0 && !fn(line, string(bytes.TrimRight(text, "\r\n"))) {
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func snippet(text string) string {
+	text = strings.TrimSpace(text)
+	if len(text) > maxSnippetLength {
+		text = text[:maxSnippetLength] + "…"
+	}
+	return text
+}
+
+// openLogRange opens a log file in the results directory. When offsets is non-nil, only
+// the given byte range is read. Files compressed by -gc are decompressed.
+func openLogRange(fsys fs.FS, name string, offsets *libhive.TestLogOffsets) (io.ReadCloser, error) {
+	var rs interface {
+		io.ReadSeeker
+		io.Closer
+	}
+	f, err := fsys.Open(name)
+	switch {
+	case err == nil:
+		seeker, ok := f.(io.ReadSeeker)
+		if !ok {
+			f.Close()
+			return nil, fmt.Errorf("%s is not seekable", name)
+		}
+		rs = struct {
+			io.ReadSeeker
+			io.Closer
+		}{seeker, f}
+	case errors.Is(err, fs.ErrNotExist):
+		gz, gzErr := newGzipReadSeeker(fsys, name+".gz")
+		if gzErr != nil {
+			return nil, err
+		}
+		rs = gz
+	default:
+		return nil, err
+	}
+	if offsets == nil {
+		return rs, nil
+	}
+	if _, err := rs.Seek(offsets.Begin, io.SeekStart); err != nil {
+		rs.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(rs, offsets.End-offsets.Begin), rs}, nil
+}
+
+// isReady reports whether the initial indexing is done.
+func (idx *searchIndex) isReady() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.ready
+}
+
+type serveSearch struct{ index *searchIndex }
+
+func (h serveSearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := defaultSearchLimit
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxSearchLimit)
+	}
+	query := q.Get("q")
+	results, truncated, err := h.index.search(query, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"query":     query,
+		"results":   results,
+		"truncated": truncated,
+		"indexing":  !h.index.isReady(),
+	})
+}
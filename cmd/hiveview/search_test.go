@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// This test checks that search finds matches in test names, test output and client
+// logs, and that index updates pick up new suite files and prune deleted ones.
+func TestSearch(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-run.json": {Data: []byte(`{
+			"name": "suite", "simLog": "1-sim.log", "testDetailsLog": "details/1.log",
+			"testCases": {
+				"1": {"name": "block import", "summaryResult": {"pass": false, "log": {"begin": 6, "end": 40}},
+				      "clientInfo": {"c1": {"name": "go-ethereum", "logFile": "clients/1.log"}}},
+				"2": {"name": "other", "summaryResult": {"pass": true, "details": "all good\nblockhash ok"}}
+			}
+		}`)},
+		"details/1.log": {Data: []byte("xxxxx\nimporting\nerror: Invalid blockhash\n")},
+		"clients/1.log": {Data: []byte("INFO start\nWARN invalid blockhash received\n")},
+	}
+	idx := newSearchIndex(fsys, true)
+	if idx.isReady() {
+		t.Fatal("index ready before first update")
+	}
+	idx.update([]string{"1-run.json"})
+	if !idx.isReady() {
+		t.Fatal("index not ready after first update")
+	}
+
+	results, _, err := idx.search("invalid blockhash", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("wrong number of results: %+v", results)
+	}
+	if r := results[0]; r.Field != "details" || r.Line != 2 || r.TestID != 1 || r.Text != "error: Invalid blockhash" {
+		t.Errorf("wrong details result: %+v", r)
+	}
+	if r := results[1]; r.Field != "client" || r.Line != 2 || r.Client != "go-ethereum" || r.LogFile != "clients/1.log" {
+		t.Errorf("wrong client log result: %+v", r)
+	}
+
+	// Words must appear in order.
+	if results, _, _ := idx.search("blockhash invalid", 10); len(results) != 0 {
+		t.Errorf("unexpected results for reversed phrase: %+v", results)
+	}
+
+	// New files are indexed incrementally.
+	fsys["2-run.json"] = &fstest.MapFile{Data: []byte(`{
+		"name": "suite2", "simLog": "2-sim.log",
+		"testCases": {"1": {"name": "invalid blockhash test", "summaryResult": {"pass": true}}}
+	}`)}
+	idx.update([]string{"2-run.json", "1-run.json"})
+	results, truncated, err := idx.search("invalid blockhash", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !truncated || results[0].File != "2-run.json" || results[0].Field != "name" {
+		t.Errorf("wrong results after adding file: %+v (truncated %t)", results, truncated)
+	}
+
+	// Deleted files are dropped, and their words are pruned from the index.
+	delete(fsys, "1-run.json")
+	idx.update([]string{"2-run.json"})
+	if results, _, _ := idx.search("blockhash", 10); len(results) != 1 {
+		t.Errorf("wrong results after deleting file: %+v", results)
+	}
+	for _, w := range []string{"importing", "received", "block"} {
+		if ids, ok := idx.postings[w]; ok {
+			t.Errorf("word %q of deleted file still in index: %v", w, ids)
+		}
+	}
+	if ids := idx.postings["blockhash"]; len(ids) != 1 {
+		t.Errorf("wrong postings of remaining word: %v", ids)
+	}
+}
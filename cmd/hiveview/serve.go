@@ -9,18 +9,30 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+var metricListingTime = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name: "hiveview_listing_seconds",
+	Help: "Time spent generating the listing.",
+})
+
 //go:embed assets
 var embeddedAssets embed.FS
 
 type serverConfig struct {
-	listenAddr    string
-	logDir        string
-	assetsDir     string
-	disableBundle bool
+	listenAddr      string
+	logDir          string
+	assetsDir       string
+	disableBundle   bool
+	listLimit       int
+	searchClients   bool
+	refreshInterval time.Duration
 }
 
 func (cfg *serverConfig) assetFS() (fs.FS, error) {
@@ -50,11 +62,25 @@ func runServer(config serverConfig) {
 	// Create handlers.
 	deployFS := newDeployFS(assetFS, &config)
 	logDirFS := os.DirFS(config.logDir)
-	logHandler := http.FileServer(http.FS(logDirFS))
-	listingHandler := serveListing{fsys: logDirFS}
+	logHandler := serveLogs{fsys: logDirFS}
+	store := newResultsStore(logDirFS, config.logDir)
+	if _, err := store.refresh(); err != nil {
+		log.Fatalf("Can't load results: %v", err)
+	}
+	search := newSearchIndex(logDirFS, config.searchClients)
+	searchNotify := make(chan struct{}, 1)
+	go search.run(store, searchNotify)
+	go store.run(config.refreshInterval, searchNotify)
+	listingHandler := serveListing{store: store, limit: config.listLimit}
+	historyHandler := serveHistory{store: store}
 
 	mux := mux.NewRouter()
 	mux.Handle("/listing.jsonl", listingHandler).Methods("GET")
+	mux.Handle("/history.json", historyHandler).Methods("GET")
+	mux.Handle("/matrix.json", serveMatrix{store: store}).Methods("GET")
+	mux.Handle("/badge.svg", serveBadge{store: store}).Methods("GET")
+	mux.Handle("/search.json", serveSearch{search}).Methods("GET")
+	mux.Handle("/metrics", promhttp.Handler()).Methods("GET")
 	mux.PathPrefix("/results").Handler(http.StripPrefix("/results/", logHandler))
 	mux.PathPrefix("/").Handler(serveFiles{deployFS})
 
@@ -67,11 +93,17 @@ func runServer(config serverConfig) {
 	http.Serve(l, mux)
 }
 
-type serveListing struct{ fsys fs.FS }
+type serveListing struct {
+	store *resultsStore
+	limit int
+}
 
 func (h serveListing) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Generating listing...")
-	err := generateListing(h.fsys, ".", w, 200)
+	start := time.Now()
+	snap := h.store.snapshot()
+	err := writeListing(h.store.fsys, ".", snap.idx, snap.names, w, h.limit)
+	metricListingTime.Observe(time.Since(start).Seconds())
 	if err != nil {
 		fmt.Println("error:", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ethereum/hive/internal/libhive"
+	"gopkg.in/yaml.v3"
+)
+
+// hiveConfig is the content of a --config file. The top-level section applies to
+// every run, and a profile selected by --profile overrides it.
+type hiveConfig struct {
+	configSection `yaml:",inline"`
+	Profiles      map[string]configSection `yaml:"profiles"`
+}
+
+// configSection holds flag values and a client list.
+type configSection struct {
+	// Flags is keyed by flag name, e.g. "sim.limit". Flags which can be given multiple
+	// times, like sim.buildarg, take a list or map of values.
+	Flags map[string]any `yaml:"flags"`
+
+	// Clients is a client list in the format of --client-file.
+	Clients yaml.Node `yaml:"clients"`
+}
+
+// loadConfig reads a config file and returns the settings of the given profile.
+func loadConfig(file, profile string) (*configSection, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var cfg hiveConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse config file: %w", err)
+	}
+
+	result := configSection{Flags: make(map[string]any), Clients: cfg.Clients}
+	for name, value := range cfg.Flags {
+		result.Flags[name] = value
+	}
+	if profile != "" {
+		p, ok := cfg.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q", profile)
+		}
+		for name, value := range p.Flags {
+			result.Flags[name] = value
+		}
+		if !p.Clients.IsZero() {
+			result.Clients = p.Clients
+		}
+	}
+	return &result, nil
+}
+
+// applyFlags sets flag values from the config. Flags given on the command line take
+// precedence, so they are not modified.
+func (c *configSection) applyFlags(fs *flag.FlagSet) error {
+	setOnCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { setOnCommandLine[f.Name] = true })
+
+	names := make([]string, 0, len(c.Flags))
+	for name := range c.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "config" || name == "profile" {
+			return fmt.Errorf("flag %q can't be set in config file", name)
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		if setOnCommandLine[name] {
+			continue
+		}
+		values, err := configFlagValues(c.Flags[name])
+		if err != nil {
+			return fmt.Errorf("flag %q: %v", name, err)
+		}
+		for _, v := range values {
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("flag %q: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configFlagValues converts a YAML value to flag values. Lists and maps produce
+// multiple values, and map entries are given as KEY=VALUE.
+func configFlagValues(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, errors.New("missing value")
+	case []any:
+		values := make([]string, len(v))
+		for i, item := range v {
+			if !isScalar(item) {
+				return nil, errors.New("list items must be plain values")
+			}
+			values[i] = fmt.Sprint(item)
+		}
+		return values, nil
+	case map[string]any:
+		values := make([]string, 0, len(v))
+		for key, item := range v {
+			if !isScalar(item) {
+				return nil, errors.New("map values must be plain values")
+			}
+			values = append(values, fmt.Sprintf("%s=%v", key, item))
+		}
+		sort.Strings(values)
+		return values, nil
+	default:
+		if !isScalar(v) {
+			return nil, fmt.Errorf("unsupported value type %T", v)
+		}
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
+
+func isScalar(v any) bool {
+	switch v.(type) {
+	case string, bool, int, int64, uint64, float64:
+		return true
+	}
+	return false
+}
+
+// clientList parses the client list of the config. It returns nil if the config
+// does not contain a client list.
+func (c *configSection) clientList(inv *libhive.Inventory) ([]libhive.ClientDesignator, error) {
+	if c.Clients.IsZero() {
+		return nil, nil
+	}
+	data, err := yaml.Marshal(&c.Clients)
+	if err != nil {
+		return nil, err
+	}
+	return libhive.ParseClientListYAML(inv, bytes.NewReader(data))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+const testConfig = `
+flags:
+  sim.parallelism: 2
+  sim.timelimit: 1h
+profiles:
+  nightly:
+    flags:
+      sim: ethereum/engine
+      sim.timelimit: 6h
+      sim.buildarg: {branch: main, fork: cancun}
+    clients:
+      - client: go-ethereum
+        dockerfile: git
+`
+
+// This test checks that profile values override the top level of the config file,
+// and that flags given on the command line override both.
+func TestConfigProfiles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hive.yaml")
+	if err := os.WriteFile(file, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("hive", flag.ContinueOnError)
+	var (
+		sim         = fs.String("sim", "", "")
+		parallelism = fs.Int("sim.parallelism", 1, "")
+		timelimit   = fs.Duration("sim.timelimit", 0, "")
+		buildArgs   = make(buildArgs)
+	)
+	fs.Var(&buildArgs, "sim.buildarg", "")
+	if err := fs.Parse([]string{"--sim.parallelism", "4"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadConfig(file, "nightly")
+	if err != nil {
+		t.Fatal("can't load config:", err)
+	}
+	if err := config.applyFlags(fs); err != nil {
+		t.Fatal("can't apply config:", err)
+	}
+	if *sim != "ethereum/engine" {
+		t.Errorf("wrong sim: %q", *sim)
+	}
+	if *parallelism != 4 {
+		t.Errorf("command-line flag was overridden: sim.parallelism = %d", *parallelism)
+	}
+	if *timelimit != 6*time.Hour {
+		t.Errorf("profile did not override top level: sim.timelimit = %v", *timelimit)
+	}
+	if buildArgs.String() != "branch=main,fork=cancun" {
+		t.Errorf("wrong build args: %s", buildArgs.String())
+	}
+	inv := libhive.Inventory{Clients: map[string]libhive.InventoryClient{"go-ethereum": {Dockerfiles: []string{"git"}}}}
+	clients, err := config.clientList(&inv)
+	if err != nil {
+		t.Fatal("can't parse client list:", err)
+	}
+	if len(clients) != 1 || clients[0].Client != "go-ethereum" || clients[0].DockerfileExt != "git" {
+		t.Errorf("wrong client list: %+v", clients)
+	}
+
+	if _, err := loadConfig(file, "missing"); err == nil {
+		t.Error("no error for unknown profile")
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
@@ -15,6 +18,7 @@ import (
 
 	"github.com/ethereum/hive/internal/libdocker"
 	"github.com/ethereum/hive/internal/libhive"
+	"github.com/ethereum/hive/internal/simapi"
 	"github.com/lmittmann/tint"
 )
 
@@ -48,16 +52,36 @@ func main() {
 		dockerPull            = flag.Bool("docker.pull", false, "Refresh base images when building images.")
 		dockerOutput          = flag.Bool("docker.output", false, "Relay all docker output to stderr.")
 		dockerBuildOutput     = flag.Bool("docker.buildoutput", false, "Relay only docker build output to stderr.")
+		dockerBuildParallel   = flag.Int("docker.buildparallelism", 1, "Max `number` of images built concurrently.")
 		simPattern            = flag.String("sim", "", "Regular `expression` selecting the simulators to run.")
 		simTestPattern        = flag.String("sim.limit", "", "Regular `expression` selecting tests/suites (interpreted by simulators).")
+		simConcurrency        = flag.Int("sim.concurrency", 1, "Max `number` of simulators to run at the same time.")
 		simParallelism        = flag.Int("sim.parallelism", 1, "Max `number` of parallel clients/containers (interpreted by simulators).")
 		simRandomSeed         = flag.Int("sim.randomseed", 0, "Randomness seed number (interpreted by simulators).")
+		simRetries            = flag.Int("sim.retries", 0, "Max `number` of reruns of a failing test (interpreted by simulators).")
+		simShard              = flag.String("sim.shard", "", "Run only the `i/n`-th shard of tests (interpreted by simulators).")
 		simTestLimit          = flag.Int("sim.testlimit", 0, "[DEPRECATED] Max `number` of tests to execute per client (interpreted by simulators).")
 		simTimeLimit          = flag.Duration("sim.timelimit", 0, "Simulation `timeout`. Hive aborts the simulator if it exceeds this time.")
 		simLogLevel           = flag.Int("sim.loglevel", 3, "Selects log `level` of client instances. Supports values 0-5.")
+		listTests             = flag.Bool("list-tests", false, "Prints the suites and tests of the simulators instead of running them.")
+		listTestsJSON         = flag.Bool("list-tests.json", false, "Prints the test list as JSON (for --list-tests).")
 		simDevMode            = flag.Bool("dev", false, "Only starts the simulator API endpoint (listening at 127.0.0.1:3000 by default) without starting any simulators.")
 		simDevModeAPIEndpoint = flag.String("dev.addr", "127.0.0.1:3000", "Endpoint that the simulator API listens on")
+		exportImages          = flag.String("export-images", "", "Builds all images and saves them to the given tar `file`, without running simulations.")
+		importImages          = flag.String("import-images", "", "Loads images from a `file` created by --export-images instead of building them.")
 		useCredHelper         = flag.Bool("docker.cred-helper", false, "configure docker authentication using locally-configured credential helper")
+		cleanup               = flag.Bool("cleanup", false, "Removes containers and networks left behind by hive processes which are no longer running.")
+		cleanupOlderThan      = flag.Duration("older-than", 0, "Removes only resources older than the given `duration` (for --cleanup).")
+		webhookURL            = flag.String("webhook", "", "Posts a JSON summary to the given `URL` when a suite ends and when the run finishes.")
+		webhookViewURL        = flag.String("webhook.viewurl", "", "Base `URL` of hiveview, used for links to results in webhook notifications.")
+		webhookRetries        = flag.Int("webhook.retries", 3, "Number of times a failed webhook delivery is retried.")
+		simRecord             = flag.Bool("sim.record", false, "Records all simulation API requests to a JSONL file in the results directory.")
+		replayFile            = flag.String("replay", "", "Replays the simulation API requests of a recording `file` instead of running simulators.")
+		replayFake            = flag.Bool("replay.fake", false, "Replays against a fake container backend, without docker (for --replay).")
+		metricsAddr           = flag.String("metrics.addr", "", "Serves Prometheus metrics at http://`address`/metrics. Metrics are also served by the simulation API.")
+
+		configFile = flag.String("config", "", "YAML `file` containing flag values and named profiles.")
+		profile    = flag.String("profile", "", "Selects a `name`d profile of the --config file.")
 
 		clientsFile = flag.String("client-file", "", `YAML `+"`file`"+` containing client configurations.`)
 
@@ -79,6 +103,18 @@ func main() {
 
 	// Parse the flags and configure the logger.
 	flag.Parse()
+	var config *configSection
+	if *configFile != "" {
+		var err error
+		if config, err = loadConfig(*configFile, *profile); err != nil {
+			fatal("-config:", err)
+		}
+		if err := config.applyFlags(flag.CommandLine); err != nil {
+			fatal("-config:", err)
+		}
+	} else if *profile != "" {
+		fatal("-profile requires -config")
+	}
 	terminal := os.Getenv("TERM")
 	tintHandler := tint.NewHandler(os.Stderr, &tint.Options{
 		Level:   convertLogLevel(*loglevelFlag),
@@ -92,6 +128,11 @@ func main() {
 	if *simTestLimit > 0 {
 		slog.Warn("Option --sim.testlimit is deprecated and will have no effect.")
 	}
+	if *simShard != "" {
+		if _, _, err := simapi.ParseShard(*simShard); err != nil {
+			fatal("-sim.shard:", err)
+		}
+	}
 
 	// Get the list of simulators.
 	inv, err := libhive.LoadInventory(".")
@@ -110,11 +151,30 @@ func main() {
 		simList = nil
 	}
 
+	// Load the API recording.
+	var replayRecords []libhive.APIRecord
+	if *replayFile != "" {
+		if replayRecords, err = libhive.ReadAPIRecording(*replayFile); err != nil {
+			fatal("-replay:", err)
+		}
+		if *replayFake {
+			if err := os.MkdirAll(*testResultsRoot, 0755); err != nil {
+				fatal(err)
+			}
+			env := libhive.SimEnv{LogDir: *testResultsRoot}
+			diffs := replayWithFakes(env, replayRecords, libhive.HiveInfo{Command: os.Args})
+			reportReplay(replayRecords, diffs)
+			return
+		}
+	}
+
 	// Create the docker backends.
+	buildLogDir := libhive.NewBuildLogDir()
 	dockerConfig := &libdocker.Config{
 		Inventory:           inv,
 		PullEnabled:         *dockerPull,
 		UseCredentialHelper: *useCredHelper,
+		BuildLogRoot:        filepath.Join(*testResultsRoot, filepath.FromSlash(buildLogDir)),
 	}
 	if *dockerNoCache != "" {
 		re, err := regexp.Compile(*dockerNoCache)
@@ -133,6 +193,14 @@ func main() {
 	if err != nil {
 		fatal(err)
 	}
+	if *cleanup {
+		removed, err := libhive.Cleanup(context.Background(), cb, *cleanupOlderThan)
+		slog.Info(fmt.Sprintf("removed %d orphaned docker resources", removed))
+		if err != nil {
+			fatal("cleanup failed:", err)
+		}
+		return
+	}
 
 	// Set up the context for CLI interrupts.
 	sig := make(chan os.Signal, 1)
@@ -150,53 +218,125 @@ func main() {
 		SimTestPattern:     *simTestPattern,
 		SimParallelism:     *simParallelism,
 		SimRandomSeed:      *simRandomSeed,
+		SimRetries:         *simRetries,
+		SimShard:           *simShard,
 		SimDurationLimit:   *simTimeLimit,
 		ClientStartTimeout: *clientTimeout,
+		RecordAPI:          *simRecord,
 	}
 	runner := libhive.NewRunner(inv, builder, cb)
+	runner.SetBuildParallelism(*dockerBuildParallel)
+	var sink libhive.ResultSink
+	if *webhookURL != "" {
+		sink = libhive.NewWebhookSink(*webhookURL, libhive.WebhookConfig{
+			ViewURL: *webhookViewURL,
+			Retries: *webhookRetries,
+		})
+		runner.SetResultSink(sink)
+	}
 
 	// Parse the client list.
 	// It can be supplied as a comma-separated list, or as a YAML file.
+	// The client list of the config file is used unless --client-file is given.
 	var clientList []libhive.ClientDesignator
-	if *clientsFile == "" {
-		clientList, err = libhive.ParseClientList(&inv, *clients)
-		if err != nil {
-			fatal("-client:", err)
-		}
-	} else {
+	switch {
+	case *clientsFile != "":
 		clientList, err = parseClientsFile(&inv, *clientsFile)
 		if err != nil {
 			fatal("-client-file:", err)
 		}
-		// If YAML file is used, the list can be filtered by the -client flag.
-		if flagIsSet("client") {
-			filter := strings.Split(*clients, ",")
-			clientList = libhive.FilterClients(clientList, filter)
+	case config != nil && !config.Clients.IsZero():
+		clientList, err = config.clientList(&inv)
+		if err != nil {
+			fatal("-config:", err)
+		}
+	default:
+		clientList, err = libhive.ParseClientList(&inv, *clients)
+		if err != nil {
+			fatal("-client:", err)
 		}
 	}
+	// If YAML is used, the list can be filtered by the -client flag.
+	if (*clientsFile != "" || (config != nil && !config.Clients.IsZero())) && flagIsSet("client") {
+		filter := strings.Split(*clients, ",")
+		clientList = libhive.FilterClients(clientList, filter)
+	}
 	hiveInfo := libhive.HiveInfo{
 		Command:    os.Args,
 		ClientFile: clientList,
 	}
 
+	if *listTests {
+		if len(simList) == 0 {
+			fatal("--list-tests requires --sim")
+		}
+		env.SimListTests = true
+		if err := runner.BuildForTestListing(ctx, clientList, simList, simBuildArgs); err != nil {
+			fatal(err)
+		}
+		results, err := runner.RunSimulators(ctx, simList, *simConcurrency, env, hiveInfo)
+		if err != nil {
+			fatal(err)
+		}
+		if err := printTestLists(os.Stdout, simList, results, *listTestsJSON); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if *metricsAddr != "" {
+		if err := serveMetrics(*metricsAddr); err != nil {
+			fatal("-metrics.addr:", err)
+		}
+	}
+	if *importImages != "" {
+		if err := importImageFile(ctx, runner, *importImages); err != nil {
+			fatal("-import-images:", err)
+		}
+	}
+
 	// Build clients and simulators.
-	if err := runner.Build(ctx, clientList, simList, simBuildArgs); err != nil {
-		fatal(err)
+	buildErr := runner.Build(ctx, clientList, simList, simBuildArgs)
+	if err := runner.WriteBuildSummary(*testResultsRoot, buildLogDir); err != nil {
+		slog.Warn("can't write build summary", "err", err)
+	}
+	if buildErr != nil {
+		fatal(buildErr)
+	}
+	if *exportImages != "" {
+		if err := exportImageFile(ctx, runner, *exportImages); err != nil {
+			fatal("-export-images:", err)
+		}
+		return
+	}
+	if *replayFile != "" {
+		env.RecordAPI = false
+		diffs, err := runner.Replay(ctx, env, replayRecords, hiveInfo)
+		if err != nil {
+			fatal("-replay:", err)
+		}
+		reportReplay(replayRecords, diffs)
+		return
 	}
 	if *simDevMode {
 		runner.RunDevMode(ctx, env, *simDevModeAPIEndpoint, hiveInfo)
+		if sink != nil {
+			sink.Close()
+		}
 		return
 	}
 
 	// Run simulators.
+	results, err := runner.RunSimulators(ctx, simList, *simConcurrency, env, hiveInfo)
+	if sink != nil {
+		sink.Close()
+	}
+	if err != nil {
+		fatal(err)
+	}
 	var failCount int
-	for _, sim := range simList {
-		result, err := runner.Run(ctx, sim, env, hiveInfo)
-		if err != nil {
-			fatal(err)
-		}
+	for _, result := range results {
 		failCount += result.TestsFailed
-		slog.Info(fmt.Sprintf("simulation %s finished", sim), "suites", result.Suites, "tests", result.Tests, "failed", result.TestsFailed)
 	}
 
 	switch failCount {
@@ -213,6 +353,44 @@ func fatal(args ...interface{}) {
 	os.Exit(1)
 }
 
+// serveMetrics starts an HTTP server for the metrics endpoint.
+func serveMetrics(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", libhive.MetricsHandler())
+	slog.Info("serving metrics", "url", "http://"+l.Addr().String()+"/metrics")
+	go func() {
+		err := http.Serve(l, mux)
+		slog.Error("metrics server stopped", "err", err)
+	}()
+	return nil
+}
+
+func importImageFile(ctx context.Context, runner *libhive.Runner, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return runner.ImportImages(ctx, f)
+}
+
+func exportImageFile(ctx context.Context, runner *libhive.Runner, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := runner.ExportImages(ctx, f); err != nil {
+		f.Close()
+		os.Remove(file)
+		return err
+	}
+	return f.Close()
+}
+
 func parseClientsFile(inv *libhive.Inventory, file string) ([]libhive.ClientDesignator, error) {
 	f, err := os.Open(file)
 	if err != nil {
@@ -0,0 +1,428 @@
+package hivesim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/hive/internal/fakes"
+	"github.com/ethereum/hive/internal/libhive"
+	"github.com/ethereum/hive/internal/openapi"
+	"github.com/ethereum/hive/internal/simapi"
+)
+
+// This test runs the client side of the simulation API against hive and checks
+// all requests and responses against the OpenAPI spec served by the API.
+func TestAPIContract(t *testing.T) {
+	tm, srv := newFakeAPI(&fakes.BackendHooks{
+		RunProgram: func(containerID string, cmd []string) (*libhive.ExecInfo, error) {
+			return &libhive.ExecInfo{Stdout: "output", ExitCode: 0}, nil
+		},
+		ContainerIP: func(containerID, networkID string) (net.IP, error) {
+			return net.IPv4(192, 0, 2, 1), nil
+		},
+	})
+	defer srv.Close()
+	defer tm.Terminate()
+
+	checker := newContractChecker(t, srv.URL, tm.API())
+	proxy := httptest.NewServer(checker)
+	defer proxy.Close()
+
+	// Use all API calls of hivesim.
+	sim := NewAt(proxy.URL)
+	if _, err := sim.ClientTypes(); err != nil {
+		t.Fatal("ClientTypes failed:", err)
+	}
+	suite, err := sim.StartSuite(&simapi.TestRequest{Name: "suite", Description: "the suite"}, "")
+	if err != nil {
+		t.Fatal("StartSuite failed:", err)
+	}
+	test, err := sim.StartTest(suite, TestStartInfo{Name: "test"})
+	if err != nil {
+		t.Fatal("StartTest failed:", err)
+	}
+	if err := sim.CreateNetwork(suite, "network1"); err != nil {
+		t.Fatal("CreateNetwork failed:", err)
+	}
+	node, _, err := sim.StartClientWithOptions(suite, test, "client-1",
+		Params{"HIVE_FOO": "1"},
+		WithInitialNetworks([]string{"network1"}),
+		WithDynamicFile("/genesis.json", func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("{}")), nil
+		}),
+	)
+	if err != nil {
+		t.Fatal("StartClient failed:", err)
+	}
+	if _, err := sim.ClientExec(suite, test, node, []string{"script.sh", "arg"}); err != nil {
+		t.Fatal("ClientExec failed:", err)
+	}
+	if _, err := sim.ContainerNetworkIP(suite, "network1", node); err != nil {
+		t.Fatal("ContainerNetworkIP failed:", err)
+	}
+	if err := sim.DisconnectContainer(suite, "network1", node); err != nil {
+		t.Fatal("DisconnectContainer failed:", err)
+	}
+	if err := sim.ConnectContainer(suite, "network1", node); err != nil {
+		t.Fatal("ConnectContainer failed:", err)
+	}
+	if err := sim.PauseClient(suite, test, node); err != nil {
+		t.Fatal("PauseClient failed:", err)
+	}
+	if err := sim.UnpauseClient(suite, test, node); err != nil {
+		t.Fatal("UnpauseClient failed:", err)
+	}
+	// The client has no disk, so this returns an error response.
+	if err := sim.SetClientDiskReadOnly(suite, test, node, true); err == nil {
+		t.Fatal("SetClientDiskReadOnly succeeded for client without disk")
+	}
+	if err := sim.SetClientDiskReadOnly(suite, test, node, false); err == nil {
+		t.Fatal("SetClientDiskReadOnly succeeded for client without disk")
+	}
+	if err := sim.StopClient(suite, test, node); err != nil {
+		t.Fatal("StopClient failed:", err)
+	}
+	// StopClient doesn't report error responses, but the response is checked by the proxy.
+	sim.StopClient(suite, test, "unknown")
+	if err := sim.RemoveNetwork(suite, "network1"); err != nil {
+		t.Fatal("RemoveNetwork failed:", err)
+	}
+	if err := sim.RetryTest(suite, test, TestResult{Pass: false, Details: "attempt failed"}); err != nil {
+		t.Fatal("RetryTest failed:", err)
+	}
+	result := TestResult{Pass: true, Details: "ok", Metrics: []Metric{{Name: "m", Value: 1.5, Unit: "s"}}}
+	if err := sim.EndTest(suite, test, result); err != nil {
+		t.Fatal("EndTest failed:", err)
+	}
+	if err := sim.EndSuite(suite); err != nil {
+		t.Fatal("EndSuite failed:", err)
+	}
+	if err := sim.EndSuite(suite); err == nil {
+		t.Fatal("EndSuite succeeded for ended suite")
+	}
+
+	// Test listing mode.
+	docs := newTestListCollector(proxy.URL)
+	listSuite, _ := docs.StartSuite(&simapi.TestRequest{Name: "suite"}, "")
+	docs.StartTest(listSuite, TestStartInfo{Name: "test", Description: "the test"})
+	if err := docs.EndSuite(listSuite); err != nil {
+		t.Fatal("sending test list failed:", err)
+	}
+
+	// Check error codes.
+	wantCodes := []simapi.ErrorCode{simapi.ErrBadRequest, simapi.ErrUnknownNode, simapi.ErrUnknownSuite}
+	if codes := checker.errorCodes(); !reflect.DeepEqual(codes, wantCodes) {
+		t.Errorf("wrong error codes %v, want %v", codes, wantCodes)
+	}
+	// Check that hivesim uses all operations except the ones which are not
+	// meant for simulators.
+	wantUnused := []string{"getClient", "getHiveInfo", "getMetrics", "getOpenAPISpec"}
+	if unused := checker.unusedOperations(); !reflect.DeepEqual(unused, wantUnused) {
+		t.Errorf("unused operations %v, want %v", unused, wantUnused)
+	}
+}
+
+// This test checks that requests which don't match the spec are rejected
+// with a validation error.
+func TestAPIValidation(t *testing.T) {
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	defer tm.Terminate()
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		code               simapi.ErrorCode
+		field              string
+	}{
+		{"POST", "/testsuite", `{"name":1}`, 400, simapi.ErrValidation, "body.name"},
+		{"POST", "/testsuite", `{"description":"d"}`, 400, simapi.ErrValidation, "body.name"},
+		{"POST", "/testsuite", ``, 400, simapi.ErrValidation, "body"},
+		{"POST", "/testsuite", `{"name":"s"`, 400, simapi.ErrValidation, "body"},
+		{"POST", "/testsuite/x/test", `{"name":"t"}`, 400, simapi.ErrValidation, "path.suite"},
+		{"POST", "/testsuite/-1/test", `{"name":"t"}`, 400, simapi.ErrValidation, "path.suite"},
+		{"POST", "/testsuite/1/test", `{"name":"t"}`, 400, simapi.ErrUnknownSuite, ""},
+		{"POST", "/testsuite/1/test/2/node", `{}`, 400, simapi.ErrValidation, "body"},
+		{"POST", "/testlist", `{"suites":[{"name":"s","tests":[{"name":"t","matched":"yes"}]}]}`, 400, simapi.ErrValidation, "body.suites[0].tests[0].matched"},
+		{"GET", "/nonexistent", ``, 404, simapi.ErrNotFound, ""},
+		{"PUT", "/testsuite", ``, 405, simapi.ErrMethodNotAllowed, ""},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, srv.URL+test.path, strings.NewReader(test.body))
+		req.Header.Set("content-type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e simapi.Error
+		err = json.NewDecoder(resp.Body).Decode(&e)
+		resp.Body.Close()
+		if err != nil {
+			t.Errorf("%s %s: can't decode error: %v", test.method, test.path, err)
+			continue
+		}
+		if resp.StatusCode != test.status || e.Code != test.code || e.Field != test.field {
+			t.Errorf("%s %s %s: got status %d, code %q, field %q (%s), want %d, %q, %q",
+				test.method, test.path, test.body, resp.StatusCode, e.Code, e.Field, e.Error, test.status, test.code, test.field)
+		}
+	}
+}
+
+// This test checks the node config in client start requests.
+func TestAPIValidationNodeConfig(t *testing.T) {
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	defer tm.Terminate()
+
+	sim := NewAt(srv.URL)
+	suite, err := sim.StartSuite(&simapi.TestRequest{Name: "suite"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := sim.StartTest(suite, TestStartInfo{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup := &clientSetup{config: simapi.NodeConfig{Client: "client-3"}}
+	url := fmt.Sprintf("%s/testsuite/%d/test/%d/node", srv.URL, suite, test)
+	if err := setup.postWithFiles(url, nil); err == nil || !strings.Contains(err.Error(), "unknown client") {
+		t.Fatalf("wrong error for unknown client: %v", err)
+	}
+	// The config must match the spec.
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("config", `{"client":"client-1","networks":"network1"}`)
+	form.Close()
+	resp, err := http.Post(url, form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var e simapi.Error
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal("can't decode error:", err)
+	}
+	if e.Code != simapi.ErrValidation || e.Field != "body.config.networks" {
+		t.Fatalf("wrong error for invalid config: %+v", e)
+	}
+}
+
+// This test checks that subtests can only be started by a running test of the suite.
+func TestAPIStartTestParent(t *testing.T) {
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	defer tm.Terminate()
+
+	sim := NewAt(srv.URL)
+	suite, err := sim.StartSuite(&simapi.TestRequest{Name: "suite"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	running, err := sim.StartTest(suite, TestStartInfo{Name: "running"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ended, err := sim.StartTest(suite, TestStartInfo{Name: "ended"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.EndTest(suite, ended, TestResult{Pass: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.StartTest(suite, TestStartInfo{Name: "child", Parent: running}); err != nil {
+		t.Fatal("can't start subtest of running test:", err)
+	}
+
+	for _, parent := range []TestID{ended, 1000} {
+		body := fmt.Sprintf(`{"name":"child","parent":%d}`, parent)
+		resp, err := http.Post(fmt.Sprintf("%s/testsuite/%d/test", srv.URL, suite), "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e simapi.Error
+		err = json.NewDecoder(resp.Body).Decode(&e)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal("can't decode error:", err)
+		}
+		if resp.StatusCode != http.StatusBadRequest || e.Code != simapi.ErrBadRequest {
+			t.Errorf("parent %d: got status %d, code %q (%s), want 400 %q", parent, resp.StatusCode, e.Code, e.Error, simapi.ErrBadRequest)
+		}
+	}
+}
+
+// contractChecker is a proxy for the simulation API which validates requests
+// and responses against the OpenAPI spec.
+type contractChecker struct {
+	t    *testing.T
+	spec *openapi.Document
+	api  http.Handler
+
+	mu    sync.Mutex
+	used  map[string]bool
+	codes map[simapi.ErrorCode]bool
+}
+
+func newContractChecker(t *testing.T, url string, api http.Handler) *contractChecker {
+	resp, err := http.Get(url + "/openapi.json")
+	if err != nil {
+		t.Fatal("can't get spec:", err)
+	}
+	defer resp.Body.Close()
+	var spec openapi.Document
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		t.Fatal("can't decode spec:", err)
+	}
+	return &contractChecker{
+		t:     t,
+		spec:  &spec,
+		api:   api,
+		used:  make(map[string]bool),
+		codes: make(map[simapi.ErrorCode]bool),
+	}
+}
+
+func (c *contractChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	op, path, vars := c.spec.Find(r.Method, r.URL.Path)
+	if op == nil {
+		c.t.Errorf("request %s %s is not in the spec", r.Method, r.URL.Path)
+		http.Error(w, "not in spec", http.StatusNotFound)
+		return
+	}
+	c.mu.Lock()
+	c.used[op.OperationID] = true
+	c.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := c.spec.ValidatePath(op, vars); err != nil {
+		c.t.Errorf("%s %s: invalid request: %v", r.Method, path, err)
+	}
+	if err := c.checkRequestBody(r, op, body); err != nil {
+		c.t.Errorf("%s %s: invalid request: %v", r.Method, path, err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	rec := httptest.NewRecorder()
+	c.api.ServeHTTP(rec, r)
+	if err := c.checkResponse(op, rec); err != nil {
+		c.t.Errorf("%s %s: invalid response (status %d): %v", r.Method, path, rec.Code, err)
+	}
+
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.Code)
+	w.Write(rec.Body.Bytes())
+}
+
+func (c *contractChecker) checkRequestBody(r *http.Request, op *openapi.Operation, body []byte) error {
+	if op.RequestBody == nil {
+		if len(body) > 0 {
+			return fmt.Errorf("unexpected request body")
+		}
+		return nil
+	}
+	mt, params, err := mime.ParseMediaType(r.Header.Get("content-type"))
+	if err != nil {
+		return fmt.Errorf("invalid content type: %v", err)
+	}
+	spec := op.RequestBody.Content[mt]
+	if spec == nil {
+		return fmt.Errorf("unexpected content type %q", mt)
+	}
+	if mt != "multipart/form-data" {
+		return c.spec.ValidateJSON(spec.Schema, body, "body")
+	}
+	// Check the JSON fields of the form.
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.Header.Set("content-type", mime.FormatMediaType(mt, params))
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		return err
+	}
+	defer r.MultipartForm.RemoveAll()
+	schema := c.spec.Resolve(spec.Schema)
+	for _, name := range schema.Required {
+		if !r.Form.Has(name) {
+			return fmt.Errorf("missing form field %q", name)
+		}
+	}
+	for name, enc := range spec.Encoding {
+		if enc.ContentType != "application/json" || !r.Form.Has(name) {
+			continue
+		}
+		if err := c.spec.ValidateJSON(schema.Properties[name], []byte(r.Form.Get(name)), "body."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *contractChecker) checkResponse(op *openapi.Operation, rec *httptest.ResponseRecorder) error {
+	resp := op.Responses[strconv.Itoa(rec.Code)]
+	if resp == nil {
+		resp = op.Responses["default"]
+	}
+	if resp == nil {
+		return fmt.Errorf("status not in spec")
+	}
+	ct := rec.Header().Get("content-type")
+	mt, _, _ := mime.ParseMediaType(ct)
+	spec := resp.Content[mt]
+	if spec == nil {
+		return fmt.Errorf("unexpected content type %q", ct)
+	}
+	if mt != "application/json" {
+		return nil
+	}
+	if err := c.spec.ValidateJSON(spec.Schema, rec.Body.Bytes(), "response"); err != nil {
+		return err
+	}
+	if rec.Code >= 400 {
+		var e simapi.Error
+		json.Unmarshal(rec.Body.Bytes(), &e)
+		c.mu.Lock()
+		c.codes[e.Code] = true
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+func (c *contractChecker) errorCodes() []simapi.ErrorCode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var codes []simapi.ErrorCode
+	for code := range c.codes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+func (c *contractChecker) unusedOperations() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var unused []string
+	for _, item := range c.spec.Paths {
+		for _, op := range item {
+			if !c.used[op.OperationID] {
+				unused = append(unused, op.OperationID)
+			}
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
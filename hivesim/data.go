@@ -10,8 +10,16 @@ type TestID uint32
 
 // TestResult describes the outcome of a test.
 type TestResult struct {
-	Pass    bool   `json:"pass"`
-	Details string `json:"details"`
+	Pass    bool     `json:"pass"`
+	Details string   `json:"details"`
+	Metrics []Metric `json:"metrics,omitempty"`
+}
+
+// Metric is a measurement reported by a test.
+type Metric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
 }
 
 // TestStartInfo contains metadata about a test which is supplied to the hive API.
@@ -21,6 +29,10 @@ type TestStartInfo struct {
 	Location    string `json:"location"`
 	Category    string `json:"category"`
 	Description string `json:"description"`
+
+	// Parent is the test that started this test. It is set automatically
+	// for subtests launched from a running test.
+	Parent TestID `json:"parent,omitempty"`
 }
 
 // ExecInfo is the result of running a command in a client container.
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
@@ -84,8 +85,9 @@ func (tc *markdownTestCase) toMarkdown(simName string, suiteName string, depth i
 // Represents a test suite to be printed in the markdown file.
 type markdownSuite struct {
 	simapi.TestRequest
-	running bool
-	tests   map[TestID]*markdownTestCase
+	running   bool
+	tests     map[TestID]*markdownTestCase
+	unmatched map[TestID]bool // tests not selected by the test pattern, in list mode
 }
 
 // Returns true if the test suite should be printed in the markdown file.
@@ -230,10 +232,11 @@ func (s *markdownSuite) toMarkdownFile(fw FileWriter, simName string) error {
 
 // Docs collector object:
 // - Collects the test cases and test suites.
-// - Generates markdown files.
+// - Generates markdown files, or sends the test list to hive in list mode.
 type docsCollector struct {
 	simName   string
 	outputDir string
+	hiveURL   string // set in list mode
 	suites    map[SuiteID]*markdownSuite
 }
 
@@ -283,6 +286,20 @@ func NewDocsCollector() *docsCollector {
 	return docs
 }
 
+// newTestListCollector creates a collector for test listing mode. Instead of generating
+// markdown files, it sends the collected tests to the hive API at url.
+func newTestListCollector(url string) *docsCollector {
+	return &docsCollector{
+		hiveURL: url,
+		suites:  make(map[SuiteID]*markdownSuite),
+	}
+}
+
+// Returns true if the collector runs in test listing mode.
+func (docs *docsCollector) listMode() bool {
+	return docs.hiveURL != ""
+}
+
 // Returns true if any suite is still running
 func (docs *docsCollector) AnyRunning() bool {
 	for _, s := range docs.suites {
@@ -313,6 +330,7 @@ func (docs *docsCollector) StartSuite(suite *simapi.TestRequest, simlog string)
 		TestRequest: *suite,
 		running:     true,
 		tests:       make(map[TestID]*markdownTestCase),
+		unmatched:   make(map[TestID]bool),
 	}
 	// Next suite id
 	suiteID := SuiteID(len(docs.suites))
@@ -330,6 +348,10 @@ func (docs *docsCollector) EndSuite(testSuite SuiteID) error {
 		return fmt.Errorf("test suite %d does not exist", testSuite)
 	}
 	suite.running = false
+	if !docs.AnyRunning() && docs.listMode() {
+		// Send the tests collected so far. The list is sent again if another suite runs.
+		return post(docs.hiveURL+"/testlist", docs.testList(), nil)
+	}
 	if !docs.AnyRunning() {
 		// Generate markdown files when all suites are done.
 		if err := docs.genSimulatorMarkdownFiles(NewFileWriter(docs.outputDir)); err != nil {
@@ -369,8 +391,48 @@ func (docs *docsCollector) EndTest(testSuite SuiteID, test TestID, testResult Te
 	return nil
 }
 
-// Return a generic "Client" client type.
+// setUnmatched marks a test which is not selected by the test pattern.
+func (docs *docsCollector) setUnmatched(testSuite SuiteID, test TestID) {
+	if suite, ok := docs.suites[testSuite]; ok {
+		suite.unmatched[test] = true
+	}
+}
+
+// testList returns all collected suites and tests.
+func (docs *docsCollector) testList() *simapi.TestList {
+	list := &simapi.TestList{Suites: make([]simapi.ListedSuite, 0, len(docs.suites))}
+	for _, sID := range docs.suiteIDs() {
+		s := docs.suites[sID]
+		ls := simapi.ListedSuite{
+			Name:        s.Name,
+			DisplayName: s.DisplayName,
+			Description: s.Description,
+			Location:    s.Location,
+			Tests:       make([]simapi.ListedTest, 0, len(s.tests)),
+		}
+		for _, tcID := range s.testIDs() {
+			tc := s.tests[tcID]
+			ls.Tests = append(ls.Tests, simapi.ListedTest{
+				Name:        tc.Name,
+				DisplayName: tc.DisplayName,
+				Category:    tc.Category,
+				Description: tc.Description,
+				Matched:     !s.unmatched[tcID],
+			})
+		}
+		list.Suites = append(list.Suites, ls)
+	}
+	return list
+}
+
+// Return a generic "Client" client type. In list mode, the client types are
+// requested from hive, so client test names match those of a real run.
 func (docs *docsCollector) ClientTypes() ([]*ClientDefinition, error) {
+	if docs.listMode() {
+		var resp []*ClientDefinition
+		err := get(docs.hiveURL+"/clients", &resp)
+		return slices.DeleteFunc(resp, func(cd *ClientDefinition) bool { return cd == nil }), err
+	}
 	return []*ClientDefinition{
 		{
 			Name:    "Client",
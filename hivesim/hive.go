@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"mime/multipart"
 	"net"
 	"net/http"
@@ -22,16 +23,20 @@ import (
 
 // Simulation wraps the simulation HTTP API provided by hive.
 type Simulation struct {
-	url  string
-	m    testMatcher
-	docs *docsCollector
-	ll   int
+	url     string
+	m       testMatcher
+	docs    *docsCollector
+	ll      int
+	seed    int64
+	retries int
+	shard   testShard
 }
 
 // New looks up the hive host URI using the HIVE_SIMULATOR environment variable
 // and connects to it. It will panic if HIVE_SIMULATOR is not set.
 // If HIVE_DOCS_MODE is set to "true", it will inhibit most of the functionality
-// in order to simplify execution for documentation generation.
+// in order to simplify execution for documentation generation. HIVE_LIST_TESTS works
+// the same way, but reports the collected tests to hive instead.
 func New() *Simulation {
 	var (
 		docs *docsCollector
@@ -46,8 +51,29 @@ func New() *Simulation {
 		} else if url == "" {
 			panic("HIVE_SIMULATOR environment variable is empty")
 		}
+		if os.Getenv("HIVE_LIST_TESTS") == "true" {
+			docs = newTestListCollector(url)
+		}
+	}
+	sim := &Simulation{url: url, docs: docs, seed: newRandomSeed()}
+	if s := os.Getenv("HIVE_RANDOM_SEED"); s != "" {
+		seed, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: ignoring invalid random seed: "+err.Error())
+		} else if seed != 0 {
+			sim.seed = seed
+		}
+	}
+	if r := os.Getenv("HIVE_RETRIES"); r != "" {
+		sim.retries, _ = strconv.Atoi(r)
+	}
+	if s := os.Getenv("HIVE_SHARD"); s != "" {
+		shard, err := parseTestShard(s)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: ignoring invalid test shard: "+err.Error())
+		}
+		sim.shard = shard
 	}
-	sim := &Simulation{url: url, docs: docs}
 	if p := os.Getenv("HIVE_TEST_PATTERN"); p != "" {
 		m, err := parseTestPattern(p)
 		if err != nil {
@@ -64,7 +90,17 @@ func New() *Simulation {
 // NewAt creates a simulation connected to the given API endpoint. You'll will rarely need
 // to use this. In simulations launched by hive, use New() instead.
 func NewAt(url string) *Simulation {
-	return &Simulation{url: url}
+	return &Simulation{url: url, seed: newRandomSeed()}
+}
+
+// maxRandomSeed is the largest seed chosen by newRandomSeed. Larger values can't be
+// represented exactly as JavaScript numbers, so hiveview would show a rounded seed.
+const maxRandomSeed = 1<<53 - 1
+
+// newRandomSeed picks the seed for runs where hive did not supply one.
+// The seed is never zero, because zero means 'unset' in HIVE_RANDOM_SEED.
+func newRandomSeed() int64 {
+	return rand.Int63n(maxRandomSeed) + 1
 }
 
 // SetTestPattern sets the regular expression that enables/skips suites and test cases.
@@ -91,6 +127,39 @@ func (sim *Simulation) TestPattern() (suiteExpr string, testNameExpr string) {
 	return se, te
 }
 
+// SetRandomSeed sets the random seed of the simulation. This method is provided for use
+// in unit tests. For simulator runs launched by hive, the seed is set automatically in
+// New().
+func (sim *Simulation) SetRandomSeed(seed int64) {
+	sim.seed = seed
+}
+
+// RandomSeed returns the random seed of the simulation. This is the value of the
+// --sim.randomseed flag. If the flag is not set, a random seed is chosen when the
+// simulation starts. The seed is recorded in the result of every suite, so a run can
+// be reproduced by passing the recorded value to --sim.randomseed.
+func (sim *Simulation) RandomSeed() int64 {
+	return sim.seed
+}
+
+// SetRetries sets the number of times failing tests are rerun. This method is provided
+// for use in unit tests. For simulator runs launched by hive, the value of --sim.retries
+// is applied automatically in New().
+func (sim *Simulation) SetRetries(n int) {
+	sim.retries = n
+}
+
+// SetShard selects the shard of tests run by the simulation. Shard indexes start at one.
+// This method is provided for use in unit tests. For simulator runs launched by hive,
+// the value of --sim.shard is applied automatically in New().
+func (sim *Simulation) SetShard(index, count int) {
+	shard, err := parseTestShard(fmt.Sprintf("%d/%d", index, count))
+	if err != nil {
+		panic(err)
+	}
+	sim.shard = shard
+}
+
 // CollectTestsOnly returns true if the simulation is running in collect-tests-only mode.
 func (sim *Simulation) CollectTestsOnly() bool {
 	return sim.docs != nil
@@ -106,6 +175,16 @@ func (sim *Simulation) EndTest(testSuite SuiteID, test TestID, testResult TestRe
 	return post(url, &testResult, nil)
 }
 
+// RetryTest reports a failed attempt of a test. The test stays running, and its
+// clients are stopped so the next attempt can start new ones.
+func (sim *Simulation) RetryTest(testSuite SuiteID, test TestID, testResult TestResult) error {
+	if sim.docs != nil {
+		return errors.New("RetryTest is not supported in docs mode")
+	}
+	url := fmt.Sprintf("%s/testsuite/%d/test/%d/retry", sim.url, testSuite, test)
+	return post(url, &testResult, nil)
+}
+
 // StartSuite signals the start of a test suite.
 func (sim *Simulation) StartSuite(suite *simapi.TestRequest, simlog string) (SuiteID, error) {
 	if sim.docs != nil {
@@ -246,6 +325,19 @@ func (sim *Simulation) UnpauseClient(testSuite SuiteID, test TestID, nodeid stri
 	return err
 }
 
+// SetClientDiskReadOnly remounts the disk of a client as read-only or writable. The
+// client must have been started with the WithDiskQuota option.
+func (sim *Simulation) SetClientDiskReadOnly(testSuite SuiteID, test TestID, nodeid string, readOnly bool) error {
+	if sim.docs != nil {
+		return errors.New("SetClientDiskReadOnly is not supported in docs mode")
+	}
+	url := fmt.Sprintf("%s/testsuite/%d/test/%d/node/%s/disk/readonly", sim.url, testSuite, test, nodeid)
+	if readOnly {
+		return post(url, nil, nil)
+	}
+	return requestDelete(url)
+}
+
 // ClientEnodeURL returns the enode URL of a running client.
 func (sim *Simulation) ClientEnodeURL(testSuite SuiteID, test TestID, node string) (string, error) {
 	if sim.docs != nil {
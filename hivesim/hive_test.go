@@ -2,13 +2,18 @@ package hivesim
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/hive/internal/fakes"
@@ -243,6 +248,61 @@ func TestStartClientStartOptions(t *testing.T) {
 	})
 }
 
+// This test checks that environment and files from the client file are applied to
+// client containers, overriding the values sent by the simulator.
+func TestStartClientOverrides(t *testing.T) {
+	file, err := os.CreateTemp("", "hivesim_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("config")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	var lastOptions libhive.ContainerOptions
+	hooks := &fakes.BackendHooks{
+		StartContainer: func(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+			lastOptions = opt
+			return &libhive.ContainerInfo{}, nil
+		},
+	}
+	defs := []*libhive.ClientDefinition{{
+		Name:        "client-1",
+		Environment: map[string]string{"HIVE_FOO": "override", "HIVE_EXTRA": "1"},
+		Files:       map[string]string{"/config.toml": file.Name()},
+	}}
+	tm := libhive.NewTestManager(libhive.SimEnv{}, fakes.NewContainerBackend(hooks), defs, libhive.HiveInfo{})
+	srv := httptest.NewServer(tm.API())
+	defer srv.Close()
+	defer tm.Terminate()
+
+	sim := NewAt(srv.URL)
+	suiteID, err := sim.StartSuite(&simapi.TestRequest{Name: "suite"}, "")
+	if err != nil {
+		t.Fatal("can't start suite:", err)
+	}
+	testID, err := sim.StartTest(suiteID, TestStartInfo{Name: "test"})
+	if err != nil {
+		t.Fatal("can't start test:", err)
+	}
+	_, _, err = sim.StartClientWithOptions(suiteID, testID, "client-1", Params{"HIVE_FOO": "sim", "HIVE_BAR": "sim"})
+	if err != nil {
+		t.Fatal("can't start client:", err)
+	}
+
+	wantEnv := map[string]string{"HIVE_FOO": "override", "HIVE_BAR": "sim", "HIVE_EXTRA": "1"}
+	for k, v := range wantEnv {
+		if lastOptions.Env[k] != v {
+			t.Errorf("wrong %s: got %q, want %q", k, lastOptions.Env[k], v)
+		}
+	}
+	if f, ok := lastOptions.Files["/config.toml"]; !ok {
+		t.Error("client file /config.toml is missing")
+	} else if f.Size != 6 {
+		t.Errorf("wrong size of /config.toml: %d", f.Size)
+	}
+}
+
 // This checks running scripts in a client container.
 func TestRunProgram(t *testing.T) {
 	hooks := &fakes.BackendHooks{
@@ -400,6 +460,70 @@ func TestStartClientInitialNetworks(t *testing.T) {
 	}
 }
 
+// This checks the disk fault injection options.
+func TestClientDiskReadOnly(t *testing.T) {
+	var (
+		lastOptions libhive.ContainerOptions
+		remounts    []string
+	)
+	tm, srv := newFakeAPI(&fakes.BackendHooks{
+		StartContainer: func(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+			lastOptions = opt
+			return &libhive.ContainerInfo{}, nil
+		},
+		SetDiskReadOnly: func(containerID, path string, readOnly bool) error {
+			remounts = append(remounts, fmt.Sprintf("%s %v", path, readOnly))
+			return nil
+		},
+	})
+	defer srv.Close()
+	defer tm.Terminate()
+
+	sim := NewAt(srv.URL)
+	suiteID, err := sim.StartSuite(&simapi.TestRequest{Name: "suite"}, "")
+	if err != nil {
+		t.Fatal("can't start suite:", err)
+	}
+	testID, err := sim.StartTest(suiteID, TestStartInfo{Name: "test"})
+	if err != nil {
+		t.Fatal("can't start test:", err)
+	}
+
+	// Remounting is only allowed for clients with a disk.
+	plainID, _, err := sim.StartClientWithOptions(suiteID, testID, "client-1")
+	if err != nil {
+		t.Fatal("can't start client:", err)
+	}
+	if err := sim.SetClientDiskReadOnly(suiteID, testID, plainID, true); err == nil {
+		t.Fatal("expected error for client without disk")
+	}
+
+	// Start with quota.
+	clientID, _, err := sim.StartClientWithOptions(suiteID, testID, "client-1", WithDiskQuota("/data", 1<<20))
+	if err != nil {
+		t.Fatal("can't start client:", err)
+	}
+	if lastOptions.DiskPath != "/data" || lastOptions.DiskQuota != 1<<20 {
+		t.Fatalf("wrong disk options: path %q, quota %d", lastOptions.DiskPath, lastOptions.DiskQuota)
+	}
+	if err := sim.SetClientDiskReadOnly(suiteID, testID, clientID, true); err != nil {
+		t.Fatal("can't make disk read-only:", err)
+	}
+	if err := sim.SetClientDiskReadOnly(suiteID, testID, clientID, false); err != nil {
+		t.Fatal("can't make disk writable:", err)
+	}
+	want := []string{"/data true", "/data false"}
+	if !reflect.DeepEqual(remounts, want) {
+		t.Fatalf("wrong remounts %v\nwant %v", remounts, want)
+	}
+
+	// Relative paths are rejected.
+	_, _, err = sim.StartClientWithOptions(suiteID, testID, "client-1", WithDiskQuota("data", 1<<20))
+	if err == nil || !strings.Contains(err.Error(), "not absolute") {
+		t.Fatalf("wrong error for relative disk path: %v", err)
+	}
+}
+
 func newFakeAPI(hooks *fakes.BackendHooks) (*libhive.TestManager, *httptest.Server) {
 	defs := []*libhive.ClientDefinition{
 		{Name: "client-1", Image: "/ignored/in/api", Version: "client-1-version", Meta: libhive.ClientMetadata{Roles: []string{"eth1"}}},
@@ -414,3 +538,180 @@ func newFakeAPI(hooks *fakes.BackendHooks) (*libhive.TestManager, *httptest.Serv
 	srv := httptest.NewServer(tm.API())
 	return tm, srv
 }
+
+// This test checks that client state changes are recorded in the test result.
+func TestClientEvents(t *testing.T) {
+	tm, srv := newFakeAPI(&fakes.BackendHooks{
+		StartContainer: func(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+			return &libhive.ContainerInfo{Wait: func() {}}, nil
+		},
+	})
+	defer srv.Close()
+	defer tm.Terminate()
+
+	sim := NewAt(srv.URL)
+	suiteID, err := sim.StartSuite(&simapi.TestRequest{Name: "suite"}, "")
+	if err != nil {
+		t.Fatal("can't start suite:", err)
+	}
+	testID, err := sim.StartTest(suiteID, TestStartInfo{Name: "test"})
+	if err != nil {
+		t.Fatal("can't start test:", err)
+	}
+	clientID, _, err := sim.StartClientWithOptions(suiteID, testID, "client-1")
+	if err != nil {
+		t.Fatal("can't start client:", err)
+	}
+	if err := sim.CreateNetwork(suiteID, "net1"); err != nil {
+		t.Fatal("can't create network:", err)
+	}
+	steps := []func() error{
+		func() error { return sim.PauseClient(suiteID, testID, clientID) },
+		func() error { return sim.UnpauseClient(suiteID, testID, clientID) },
+		func() error { return sim.ConnectContainer(suiteID, "net1", clientID) },
+		func() error { return sim.DisconnectContainer(suiteID, "net1", clientID) },
+		func() error { return sim.StopClient(suiteID, testID, clientID) },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d failed: %v", i, err)
+		}
+	}
+	if err := sim.EndTest(suiteID, testID, TestResult{Pass: true}); err != nil {
+		t.Fatal("can't end test:", err)
+	}
+	if err := sim.EndSuite(suiteID); err != nil {
+		t.Fatal("can't end suite:", err)
+	}
+
+	test := tm.Results()[libhive.TestSuiteID(suiteID)].TestCases[libhive.TestID(testID)]
+	var got []string
+	for _, ev := range test.Events {
+		if ev.Client != clientID || ev.Time.IsZero() {
+			t.Errorf("wrong event: %+v", ev)
+		}
+		got = append(got, strings.TrimSpace(ev.Type+" "+ev.Network))
+	}
+	want := []string{"pause", "unpause", "connect net1", "disconnect net1", "stop"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong events %v\nwant %v", got, want)
+	}
+}
+
+// This test checks that the simulation API serves metrics.
+func TestMetrics(t *testing.T) {
+	var (
+		starts   int
+		exit     = make(chan struct{})
+		exitOnce sync.Once
+	)
+	tm, srv := newFakeAPI(&fakes.BackendHooks{
+		StartContainer: func(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+			starts++
+			if starts == 2 {
+				return nil, errors.New("boom")
+			}
+			return &libhive.ContainerInfo{
+				StartupTime: 2 * time.Second,
+				Wait:        func() { <-exit },
+			}, nil
+		},
+		DeleteContainer: func(containerID string) error {
+			exitOnce.Do(func() { close(exit) })
+			return nil
+		},
+	})
+	defer srv.Close()
+	defer tm.Terminate()
+
+	const (
+		running  = `hive_clients_running{client="client-2"}`
+		startSum = `hive_client_start_seconds_sum{client="client-2"}`
+		failures = `hive_container_start_failures_total{client="client-2"}`
+		requests = `hive_api_requests_total{code="200",method="POST",route="/testsuite/{suite}/test/{test}/node"}`
+		tests    = `hive_tests_running`
+	)
+	before := scrapeMetrics(t, srv.URL)
+
+	sim := NewAt(srv.URL)
+	suiteID, err := sim.StartSuite(&simapi.TestRequest{Name: "suite"}, "")
+	if err != nil {
+		t.Fatal("can't start suite:", err)
+	}
+	testID, err := sim.StartTest(suiteID, TestStartInfo{Name: "test"})
+	if err != nil {
+		t.Fatal("can't start test:", err)
+	}
+	clientID, _, err := sim.StartClientWithOptions(suiteID, testID, "client-2")
+	if err != nil {
+		t.Fatal("can't start client:", err)
+	}
+	if _, _, err := sim.StartClientWithOptions(suiteID, testID, "client-2"); err == nil {
+		t.Fatal("second client start did not fail")
+	}
+
+	after := scrapeMetrics(t, srv.URL)
+	for _, check := range []struct {
+		name  string
+		delta float64
+	}{
+		{running, 1},
+		{startSum, 2},
+		{failures, 1},
+		{requests, 1},
+		{tests, 1},
+	} {
+		if d := after[check.name] - before[check.name]; d != check.delta {
+			t.Errorf("%s changed by %v, want %v", check.name, d, check.delta)
+		}
+	}
+
+	// Stopping the client and ending the test should reset the gauges.
+	if err := sim.StopClient(suiteID, testID, clientID); err != nil {
+		t.Fatal("can't stop client:", err)
+	}
+	if err := sim.EndTest(suiteID, testID, TestResult{Pass: true}); err != nil {
+		t.Fatal("can't end test:", err)
+	}
+	var final map[string]float64
+	for i := 0; i < 100; i++ {
+		final = scrapeMetrics(t, srv.URL)
+		if final[running] == before[running] {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if final[running] != before[running] {
+		t.Errorf("%s is %v after stopping client, want %v", running, final[running], before[running])
+	}
+	if final[tests] != before[tests] {
+		t.Errorf("%s is %v after ending test, want %v", tests, final[tests], before[tests])
+	}
+}
+
+// scrapeMetrics fetches the metrics of the API, keyed by series name.
+func scrapeMetrics(t *testing.T, url string) map[string]float64 {
+	t.Helper()
+	resp, err := http.Get(url + "/metrics")
+	if err != nil {
+		t.Fatal("can't get metrics:", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]float64)
+	for _, line := range strings.Split(string(body), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("invalid metrics line %q", line)
+		}
+		values[line[:i]] = v
+	}
+	return values
+}
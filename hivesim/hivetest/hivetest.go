@@ -0,0 +1,289 @@
+// Package hivetest provides an in-process hive simulation API for unit tests of
+// simulators.
+//
+// A Server runs the simulation API of hive with fake clients. Instead of starting
+// containers, the server runs an HTTP handler for each started client instance. The
+// handler listens on the client's IP at the RPC port (8545) and the engine API port
+// (8551), so simulator code can talk to the fake clients like to real ones:
+//
+//	rpc := hivetest.NewRPCHandler().Respond("eth_chainId", "0x1")
+//	srv := hivetest.NewServer(&hivetest.Client{
+//		Name:    "fake-el",
+//		Handler: func(*hivetest.Instance) http.Handler { return rpc },
+//	})
+//	defer srv.Close()
+//	hivesim.RunSuite(srv.Simulation(), suite)
+//
+// Each instance gets its own loopback address in 127.0.0.0/8. These addresses are
+// available by default on Linux, but other operating systems may require configuring
+// them as loopback aliases.
+package hivetest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/ethereum/hive/hivesim"
+	"github.com/ethereum/hive/internal/fakes"
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+// Ports served by fake client instances.
+var instancePorts = []int{8545, 8551}
+
+// Client describes a fake client type.
+type Client struct {
+	Name    string
+	Version string
+	Roles   []string // defaults to "eth1"
+
+	// Handler is called for every started instance of the client. The returned handler
+	// serves the RPC and engine API ports of the instance.
+	Handler func(inst *Instance) http.Handler
+}
+
+// Instance is a started fake client.
+type Instance struct {
+	ID     string
+	Client string
+	IP     net.IP
+	Env    map[string]string // HIVE_ variables set by the simulator
+	Files  map[string][]byte // files uploaded by the simulator
+
+	servers []*http.Server
+	stopped chan struct{}
+	once    sync.Once
+}
+
+func (inst *Instance) stop() {
+	inst.once.Do(func() {
+		for _, srv := range inst.servers {
+			srv.Close()
+		}
+		close(inst.stopped)
+	})
+}
+
+// Server is an in-process simulation API.
+type Server struct {
+	tm      *libhive.TestManager
+	srv     *httptest.Server
+	clients map[string]*Client
+
+	mu        sync.Mutex
+	instances map[string]*Instance
+	order     []*Instance
+}
+
+// NewServer starts a simulation API server with the given fake clients.
+// Call Close to stop the server and all client instances.
+func NewServer(clients ...*Client) *Server {
+	s := &Server{
+		clients:   make(map[string]*Client),
+		instances: make(map[string]*Instance),
+	}
+	defs := make([]*libhive.ClientDefinition, 0, len(clients))
+	for _, c := range clients {
+		if _, dup := s.clients[c.Name]; dup {
+			panic(fmt.Sprintf("hivetest: duplicate client %q", c.Name))
+		}
+		s.clients[c.Name] = c
+		roles := c.Roles
+		if len(roles) == 0 {
+			roles = []string{"eth1"}
+		}
+		defs = append(defs, &libhive.ClientDefinition{
+			Name:    c.Name,
+			Version: c.Version,
+			Image:   c.Name,
+			Meta:    libhive.ClientMetadata{Roles: roles},
+		})
+	}
+	backend := fakes.NewContainerBackend(&fakes.BackendHooks{
+		StartContainer:  s.startInstance,
+		DeleteContainer: s.stopInstance,
+	})
+	s.tm = libhive.NewTestManager(libhive.SimEnv{}, backend, defs, libhive.HiveInfo{})
+	s.srv = httptest.NewServer(s.tm.API())
+	return s
+}
+
+// URL returns the URL of the simulation API. This is the value of HIVE_SIMULATOR.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Simulation returns a simulation connected to the server.
+func (s *Server) Simulation() *hivesim.Simulation {
+	return hivesim.NewAt(s.srv.URL)
+}
+
+// Close stops the server. Running tests are ended and all client instances are stopped.
+func (s *Server) Close() {
+	s.srv.Close()
+	s.tm.Terminate()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, inst := range s.instances {
+		inst.stop()
+	}
+}
+
+// Instances returns all client instances started so far, in start order.
+func (s *Server) Instances() []*Instance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Instance(nil), s.order...)
+}
+
+// SuiteResult is the result of a finished test suite.
+type SuiteResult struct {
+	Name  string
+	Tests []TestResult
+}
+
+// TestResult is the result of a test.
+type TestResult struct {
+	Name    string
+	Pass    bool
+	Details string
+	Clients []string // client names
+}
+
+// Results returns the results of all finished suites, in start order.
+func (s *Server) Results() []SuiteResult {
+	suites := s.tm.Results()
+	ids := make([]libhive.TestSuiteID, 0, len(suites))
+	for id := range suites {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	results := make([]SuiteResult, 0, len(ids))
+	for _, id := range ids {
+		suite := suites[id]
+		testIDs := make([]libhive.TestID, 0, len(suite.TestCases))
+		for id := range suite.TestCases {
+			testIDs = append(testIDs, id)
+		}
+		sort.Slice(testIDs, func(i, j int) bool { return testIDs[i] < testIDs[j] })
+
+		res := SuiteResult{Name: suite.Name}
+		for _, id := range testIDs {
+			test := suite.TestCases[id]
+			tr := TestResult{Name: test.Name, Pass: test.SummaryResult.Pass, Details: test.SummaryResult.Details}
+			for _, c := range test.ClientInfo {
+				tr.Clients = append(tr.Clients, c.Name)
+			}
+			sort.Strings(tr.Clients)
+			res.Tests = append(res.Tests, tr)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// instanceCounter assigns IP addresses. It is shared by all servers
+// so instances of concurrent tests don't collide.
+var instanceCounter atomic.Uint32
+
+func nextInstanceIP() net.IP {
+	n := instanceCounter.Add(1)
+	return net.IPv4(127, 100+byte(n>>16), byte(n>>8), byte(n))
+}
+
+func (s *Server) startInstance(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+	client := s.clients[image]
+	if client == nil {
+		return nil, fmt.Errorf("unknown client %q", image)
+	}
+	inst := &Instance{
+		ID:      containerID,
+		Client:  client.Name,
+		Env:     opt.Env,
+		Files:   make(map[string][]byte),
+		stopped: make(chan struct{}),
+	}
+	for name, fh := range opt.Files {
+		content, err := readFormFile(fh)
+		if err != nil {
+			return nil, err
+		}
+		inst.Files[name] = content
+	}
+
+	var handler http.Handler = http.NotFoundHandler()
+	if client.Handler != nil {
+		handler = client.Handler(inst)
+	}
+	// Find an address where the ports are free.
+	var (
+		listeners []net.Listener
+		err       error
+	)
+	for attempt := 0; attempt < 10; attempt++ {
+		inst.IP = nextInstanceIP()
+		if listeners, err = listenAll(inst.IP); !errors.Is(err, syscall.EADDRINUSE) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range listeners {
+		srv := &http.Server{Handler: handler}
+		inst.servers = append(inst.servers, srv)
+		go srv.Serve(l)
+	}
+
+	s.mu.Lock()
+	s.instances[containerID] = inst
+	s.order = append(s.order, inst)
+	s.mu.Unlock()
+	return &libhive.ContainerInfo{ID: containerID, IP: inst.IP.String(), Wait: func() { <-inst.stopped }}, nil
+}
+
+// listenAll opens the instance ports on ip.
+func listenAll(ip net.IP) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, port := range instancePorts {
+		l, err := net.Listen("tcp", net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+func (s *Server) stopInstance(containerID string) error {
+	s.mu.Lock()
+	inst := s.instances[containerID]
+	s.mu.Unlock()
+	if inst != nil {
+		inst.stop()
+	}
+	return nil
+}
+
+func readFormFile(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -0,0 +1,136 @@
+package hivetest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/hive/hivesim"
+	"github.com/ethereum/hive/hivesim/hivetest"
+)
+
+func TestServer(t *testing.T) {
+	rpc := hivetest.NewRPCHandler().Respond("eth_chainId", "0x1")
+	srv := hivetest.NewServer(&hivetest.Client{
+		Name:    "fake-el",
+		Version: "v1.0.0",
+		Handler: func(*hivetest.Instance) http.Handler { return rpc },
+	})
+	defer srv.Close()
+
+	genesis := filepath.Join(t.TempDir(), "genesis.json")
+	if err := os.WriteFile(genesis, []byte(`{"config":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	suite := hivesim.Suite{Name: "suite"}
+	suite.Add(hivesim.ClientTestSpec{
+		Name:       "chainid",
+		Parameters: hivesim.Params{"HIVE_CHAIN_ID": "1"},
+		Files:      map[string]string{"/genesis.json": genesis},
+		Run: func(t *hivesim.T, c *hivesim.Client) {
+			var id string
+			if err := c.RPC().Call(&id, "eth_chainId"); err != nil {
+				t.Fatal("eth_chainId failed:", err)
+			}
+			if id != "0x1" {
+				t.Fatalf("wrong chain ID %q", id)
+			}
+		},
+	})
+	suite.Add(hivesim.ClientTestSpec{
+		Name: "unknown-method",
+		Run: func(t *hivesim.T, c *hivesim.Client) {
+			err := c.RPC().Call(nil, "eth_blockNumber")
+			if err == nil || !strings.Contains(err.Error(), "does not exist") {
+				t.Fatalf("wrong error for unknown method: %v", err)
+			}
+		},
+	})
+	if err := hivesim.RunSuite(srv.Simulation(), suite); err != nil {
+		t.Fatal("suite failed:", err)
+	}
+
+	// Check the results.
+	wantResults := []hivetest.SuiteResult{{
+		Name: "suite",
+		Tests: []hivetest.TestResult{
+			{Name: "chainid (fake-el)", Pass: true, Clients: []string{"fake-el"}},
+			{Name: "unknown-method (fake-el)", Pass: true, Clients: []string{"fake-el"}},
+		},
+	}}
+	if results := srv.Results(); !reflect.DeepEqual(results, wantResults) {
+		t.Fatalf("wrong results:\n got %+v\nwant %+v", results, wantResults)
+	}
+
+	// Check the client instances.
+	instances := srv.Instances()
+	if len(instances) != 2 {
+		t.Fatalf("wrong number of instances %d", len(instances))
+	}
+	inst := instances[0]
+	if inst.Client != "fake-el" {
+		t.Errorf("wrong client %q", inst.Client)
+	}
+	if inst.Env["HIVE_CHAIN_ID"] != "1" {
+		t.Errorf("wrong environment %v", inst.Env)
+	}
+	if string(inst.Files["/genesis.json"]) != `{"config":{}}` {
+		t.Errorf("wrong files %v", inst.Files)
+	}
+	if instances[0].IP.Equal(instances[1].IP) {
+		t.Errorf("instances have the same IP %v", inst.IP)
+	}
+
+	// Check the calls received by the handler.
+	var methods []string
+	for _, call := range rpc.Calls() {
+		methods = append(methods, call.Method)
+	}
+	if want := []string{"eth_chainId", "eth_blockNumber"}; !reflect.DeepEqual(methods, want) {
+		t.Errorf("wrong calls %v, want %v", methods, want)
+	}
+
+	// Instances are stopped at the end of the test.
+	if _, err := http.Post("http://"+inst.IP.String()+":8545", "application/json", nil); err == nil {
+		t.Error("instance still running after test")
+	}
+}
+
+func TestRPCHandler(t *testing.T) {
+	h := hivetest.NewRPCHandler().
+		RespondSequence("eth_blockNumber", "0x1", "0x2").
+		Fail("eth_call", 3, "execution reverted")
+
+	for _, tc := range []struct {
+		req, resp string
+	}{
+		{
+			`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`,
+			`{"jsonrpc":"2.0","id":1,"result":"0x1"}`,
+		},
+		{
+			`[{"jsonrpc":"2.0","id":2,"method":"eth_blockNumber"},{"jsonrpc":"2.0","id":3,"method":"eth_blockNumber"}]`,
+			`[{"jsonrpc":"2.0","id":2,"result":"0x2"},{"jsonrpc":"2.0","id":3,"result":"0x2"}]`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":4,"method":"eth_call"}`,
+			`{"jsonrpc":"2.0","id":4,"error":{"code":3,"message":"execution reverted"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":5,"method":"eth_foo"}`,
+			`{"jsonrpc":"2.0","id":5,"error":{"code":-32601,"message":"the method eth_foo does not exist/is not available"}}`,
+		},
+	} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tc.req))
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		if got := strings.TrimSpace(resp.Body.String()); got != tc.resp {
+			t.Errorf("request %s\n got %s\nwant %s", tc.req, got, tc.resp)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package hivetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// RPCHandler is a JSON-RPC server with scripted responses. Methods without a
+// script return the 'method not found' error.
+type RPCHandler struct {
+	mu      sync.Mutex
+	methods map[string]RPCFunc
+	calls   []RPCCall
+}
+
+// RPCFunc computes the result of a call. A non-nil error is returned to the caller
+// as a JSON-RPC error. Use *RPCError to set the error code.
+type RPCFunc func(params json.RawMessage) (any, error)
+
+// RPCCall is a call received by an RPCHandler.
+type RPCCall struct {
+	Method string
+	Params json.RawMessage
+}
+
+// RPCError is a JSON-RPC error with a code.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string { return e.Message }
+
+// NewRPCHandler creates a handler without any methods.
+func NewRPCHandler() *RPCHandler {
+	return &RPCHandler{methods: make(map[string]RPCFunc)}
+}
+
+// Respond makes the method return a fixed result.
+func (h *RPCHandler) Respond(method string, result any) *RPCHandler {
+	return h.Handle(method, func(json.RawMessage) (any, error) { return result, nil })
+}
+
+// RespondSequence makes the method return the given results, one per call. When the
+// results are used up, the last one is repeated.
+func (h *RPCHandler) RespondSequence(method string, results ...any) *RPCHandler {
+	var (
+		mu sync.Mutex
+		i  int
+	)
+	return h.Handle(method, func(json.RawMessage) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		r := results[min(i, len(results)-1)]
+		i++
+		return r, nil
+	})
+}
+
+// Fail makes the method return an error.
+func (h *RPCHandler) Fail(method string, code int, message string) *RPCHandler {
+	return h.Handle(method, func(json.RawMessage) (any, error) {
+		return nil, &RPCError{Code: code, Message: message}
+	})
+}
+
+// Handle sets the function which handles a method.
+func (h *RPCHandler) Handle(method string, fn RPCFunc) *RPCHandler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.methods[method] = fn
+	return h
+}
+
+// Calls returns the calls received so far.
+func (h *RPCHandler) Calls() []RPCCall {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]RPCCall(nil), h.calls...)
+}
+
+type rpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// ServeHTTP handles JSON-RPC requests, including batches.
+func (h *RPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []rpcRequest
+		if err := json.Unmarshal(body, &batch); err != nil {
+			json.NewEncoder(w).Encode(parseError(err))
+			return
+		}
+		responses := make([]*rpcResponse, 0, len(batch))
+		for i := range batch {
+			if resp := h.call(&batch[i]); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		json.NewEncoder(w).Encode(parseError(err))
+		return
+	}
+	if resp := h.call(&req); resp != nil {
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// call handles a request. It returns nil for notifications.
+func (h *RPCHandler) call(req *rpcRequest) *rpcResponse {
+	h.mu.Lock()
+	h.calls = append(h.calls, RPCCall{Method: req.Method, Params: req.Params})
+	fn := h.methods[req.Method]
+	h.mu.Unlock()
+
+	resp := &rpcResponse{Version: "2.0", ID: req.ID}
+	if fn == nil {
+		resp.Error = &RPCError{Code: -32601, Message: "the method " + req.Method + " does not exist/is not available"}
+	} else if result, err := fn(req.Params); err != nil {
+		rpcErr, ok := err.(*RPCError)
+		if !ok {
+			rpcErr = &RPCError{Code: -32000, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+	} else {
+		resp.Result = result
+		if result == nil {
+			resp.Result = json.RawMessage("null")
+		}
+	}
+	if len(req.ID) == 0 {
+		return nil
+	}
+	return resp
+}
+
+func parseError(err error) *rpcResponse {
+	return &rpcResponse{
+		Version: "2.0",
+		ID:      json.RawMessage("null"),
+		Error:   &RPCError{Code: -32700, Message: err.Error()},
+	}
+}
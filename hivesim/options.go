@@ -37,6 +37,18 @@ func WithInitialNetworks(networks []string) StartOption {
 	})
 }
 
+// WithDiskQuota mounts the given directory of the client container on a tmpfs holding at
+// most size bytes. Writes beyond the quota fail with ENOSPC. A size of zero leaves the
+// tmpfs at its default size, which is useful for tests that only need Client.SetDiskReadOnly.
+//
+// The path should be the data directory used by the client.
+func WithDiskQuota(path string, size int64) StartOption {
+	return optionFunc(func(setup *clientSetup) {
+		setup.config.DiskPath = path
+		setup.config.DiskQuota = size
+	})
+}
+
 // WithStaticFiles adds files from the local filesystem to the client. Map: destination file path -> source file path.
 func WithStaticFiles(initFiles map[string]string) StartOption {
 	return optionFunc(func(setup *clientSetup) {
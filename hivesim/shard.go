@@ -0,0 +1,42 @@
+package hivesim
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/ethereum/hive/internal/simapi"
+)
+
+// testShard selects a subset of tests. Tests are assigned to shards by a hash of the
+// suite and test name, so the assignment is stable across runs and the shards of a
+// simulation partition its tests.
+type testShard struct {
+	index int // 1-based, zero means sharding is disabled
+	count int
+}
+
+// parseTestShard parses the HIVE_SHARD setting, which has the form "i/n". The value is
+// validated by hive before the simulator is launched.
+func parseTestShard(s string) (testShard, error) {
+	index, count, err := simapi.ParseShard(s)
+	if err != nil {
+		return testShard{}, err
+	}
+	return testShard{index, count}, nil
+}
+
+// contains reports whether the test belongs to the shard.
+func (s testShard) contains(suite, test string) bool {
+	if s.count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(suite))
+	h.Write([]byte{0})
+	h.Write([]byte(test))
+	return int(h.Sum32()%uint32(s.count)) == s.index-1
+}
+
+func (s testShard) String() string {
+	return fmt.Sprintf("%d/%d", s.index, s.count)
+}
@@ -3,6 +3,9 @@ package hivesim
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
 	"net"
 	"os"
 	"runtime"
@@ -41,7 +44,7 @@ func (s *Suite) Add(test AnyTest) *Suite {
 
 // AnyTest is a TestSpec or ClientTestSpec.
 type AnyTest interface {
-	runTest(*Simulation, SuiteID, *Suite) error
+	runTest(host *Simulation, suiteID SuiteID, suite *Suite, parent *T) error
 }
 
 // Run executes all given test suites.
@@ -71,14 +74,16 @@ func RunSuite(host *Simulation, suite Suite) error {
 		return nil
 	}
 
-	suiteID, err := host.StartSuite(suite.request(), "")
+	req := suite.request()
+	req.RandomSeed = host.seed
+	suiteID, err := host.StartSuite(req, "")
 	if err != nil {
 		return err
 	}
 	defer host.EndSuite(suiteID)
 
 	for _, test := range suite.Tests {
-		if err := test.runTest(host, suiteID, &suite); err != nil {
+		if err := test.runTest(host, suiteID, &suite, nil); err != nil {
 			return err
 		}
 	}
@@ -118,6 +123,11 @@ type TestSpec struct {
 	// then perform further tests against it.
 	AlwaysRun bool
 
+	// Retries is the number of times the test is rerun if it fails. If zero, the
+	// --sim.retries setting of hive is used, and a negative value disables retries.
+	// A test which passes on a retry is reported as flaky.
+	Retries int
+
 	// The Run function is invoked when the test executes.
 	Run func(*T)
 }
@@ -142,6 +152,11 @@ type ClientTestSpec struct {
 	// then perform further tests against it.
 	AlwaysRun bool
 
+	// Retries is the number of times the test is rerun if it fails. If zero, the
+	// --sim.retries setting of hive is used, and a negative value disables retries.
+	// A test which passes on a retry is reported as flaky.
+	Retries int
+
 	// This filters client types by role.
 	// If no role is specified, the test runs for all available client types.
 	Role string
@@ -215,6 +230,15 @@ func (c *Client) Unpause() error {
 	return c.test.Sim.UnpauseClient(c.test.SuiteID, c.test.TestID, c.Container)
 }
 
+// SetDiskReadOnly makes the client's data directory read-only or writable.
+// The client must have been started with the WithDiskQuota option.
+//
+// When the directory is read-only, creating files or opening them for writing fails,
+// but files which were already open for writing remain writable.
+func (c *Client) SetDiskReadOnly(readOnly bool) error {
+	return c.test.Sim.SetClientDiskReadOnly(c.test.SuiteID, c.test.TestID, c.Container, readOnly)
+}
+
 // T is a running test. This is a lot like testing.T, but has some additional methods for
 // launching clients.
 //
@@ -225,8 +249,23 @@ type T struct {
 	TestID  TestID
 	SuiteID SuiteID
 	suite   *Suite
+	name    string
+	inShard bool // test was selected by sharding, so its subtests are not sharded
 	mu      sync.Mutex
 	result  TestResult
+	rand    *rand.Rand
+}
+
+// testID returns the ID of a parent test, or zero for tests at the top level.
+func (t *T) testID() TestID {
+	if t == nil {
+		return 0
+	}
+	return t.TestID
+}
+
+func (t *T) isInShard() bool {
+	return t != nil && t.inShard
 }
 
 // StartClient starts a client instance. If the client cannot by started, the test fails immediately.
@@ -244,11 +283,14 @@ func (t *T) RunClient(clientType string, spec ClientTestSpec) {
 	test := testSpec{
 		suiteID:     t.SuiteID,
 		suite:       t.suite,
+		parent:      t.TestID,
+		inShard:     t.inShard,
 		name:        clientTestName(spec.Name, clientType),
 		displayName: spec.DisplayName,
 		category:    spec.Category,
 		desc:        spec.Description,
 		alwaysRun:   spec.AlwaysRun,
+		retries:     spec.Retries,
 	}
 	runTest(t.Sim, test, func(t *T) {
 		client := t.StartClient(clientType, spec.Parameters, WithStaticFiles(spec.Files))
@@ -259,14 +301,62 @@ func (t *T) RunClient(clientType string, spec ClientTestSpec) {
 // RunAllClients runs the given client test against all available client types.
 // It waits for all subtests to complete.
 func (t *T) RunAllClients(spec ClientTestSpec) {
-	spec.runTest(t.Sim, t.SuiteID, t.suite)
+	spec.runTest(t.Sim, t.SuiteID, t.suite, t)
 }
 
 // Run runs a subtest of this test. It waits for the subtest to complete before continuing.
 // It is safe to call this from multiple goroutines concurrently, just be sure to wait for
 // all your tests to finish until returning from the parent test.
 func (t *T) Run(spec TestSpec) {
-	spec.runTest(t.Sim, t.SuiteID, t.suite)
+	spec.runTest(t.Sim, t.SuiteID, t.suite, t)
+}
+
+// Rand returns the random number generator of the test. It is seeded from the
+// simulation's RandomSeed and the suite and test name, so a test draws the same values
+// every time it runs with a given seed, regardless of the order in which tests execute.
+//
+// Rand returns the same generator on every call. It is not safe for concurrent use.
+func (t *T) Rand() *rand.Rand {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rand == nil {
+		seed := testRandomSeed(t.Sim.seed, t.suite.Name, t.name)
+		t.rand = rand.New(rand.NewSource(seed))
+	}
+	return t.rand
+}
+
+// testRandomSeed derives the random seed of a single test.
+func testRandomSeed(seed int64, suiteName, testName string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(suiteName))
+	h.Write([]byte{0})
+	h.Write([]byte(testName))
+	return seed ^ int64(h.Sum64())
+}
+
+// ReportMetric records a measurement in the test result, e.g.
+//
+//	t.ReportMetric("sync duration", elapsed.Seconds(), "s")
+//
+// Metrics are shown in hiveview and charted across runs, so they should have stable
+// names. Reporting a metric name again replaces the previous value. NaN and infinite
+// values cannot be encoded in the test result, so they are dropped. This is noted in
+// the test log, but doesn't fail the test.
+func (t *T) ReportMetric(name string, value float64, unit string) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		t.Logf("ReportMetric: dropping non-finite value %v of metric %q", value, name)
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.result.Metrics {
+		if t.result.Metrics[i].Name == name {
+			t.result.Metrics[i] = Metric{Name: name, Value: value, Unit: unit}
+			return
+		}
+	}
+	t.result.Metrics = append(t.result.Metrics, Metric{Name: name, Value: value, Unit: unit})
 }
 
 // Error is like testing.T.Error.
@@ -336,11 +426,14 @@ func (t *T) FailNow() {
 type testSpec struct {
 	suiteID     SuiteID
 	suite       *Suite
+	parent      TestID
+	inShard     bool // parent test was selected by sharding
 	name        string
 	displayName string
 	category    string
 	desc        string
 	alwaysRun   bool
+	retries     int
 }
 
 func (spec testSpec) request() TestStartInfo {
@@ -349,36 +442,69 @@ func (spec testSpec) request() TestStartInfo {
 		DisplayName: spec.displayName,
 		Category:    spec.category,
 		Description: spec.desc,
+		Parent:      spec.parent,
 	}
 }
 
 func runTest(host *Simulation, test testSpec, runit func(t *T)) error {
-	if !test.alwaysRun && !host.m.match(test.suite.Name, test.name) {
+	// In list mode, tests which don't match the pattern are reported as unmatched.
+	matched := test.alwaysRun || host.m.match(test.suite.Name, test.name)
+	listMode := host.docs != nil && host.docs.listMode()
+	if !matched && !listMode {
 		if host.ll > 3 { // hive log level > 3
 			fmt.Fprintf(os.Stderr, "skipping test %q because it doesn't match test pattern %s\n", test.name, host.m.pattern)
 		}
 		return nil
 	}
+	// Subtests of a test in the shard always run, so only tests launched at the top
+	// level or by AlwaysRun tests are sharded.
+	if !test.alwaysRun && !test.inShard && !host.shard.contains(test.suite.Name, test.name) {
+		if host.ll > 3 { // hive log level > 3
+			fmt.Fprintf(os.Stderr, "skipping test %q because it is not in shard %s\n", test.name, host.shard)
+		}
+		return nil
+	}
 
-	// Register test on simulation server and initialize the T.
+	testID, err := host.StartTest(test.suiteID, test.request())
+	if err != nil {
+		return err
+	}
+	if !matched {
+		host.docs.setUnmatched(test.suiteID, testID)
+	}
+	retries := test.retries
+	if retries == 0 {
+		retries = host.retries
+	}
+	if host.CollectTestsOnly() {
+		// Tests don't really run when collecting them, and RetryTest is unsupported.
+		retries = 0
+	}
+	for attempt := 0; ; attempt++ {
+		t := runAttempt(host, test, testID, runit)
+		if t.result.Pass || attempt >= retries {
+			host.EndTest(test.suiteID, testID, t.result)
+			return nil
+		}
+		if err := host.RetryTest(test.suiteID, testID, t.result); err != nil {
+			host.EndTest(test.suiteID, testID, t.result)
+			return err
+		}
+	}
+}
+
+// runAttempt runs the test function once.
+func runAttempt(host *Simulation, test testSpec, testID TestID, runit func(t *T)) *T {
 	t := &T{
 		Sim:     host,
 		SuiteID: test.suiteID,
+		TestID:  testID,
 		suite:   test.suite,
+		name:    test.name,
+		inShard: test.inShard || !test.alwaysRun,
 	}
-	testID, err := host.StartTest(test.suiteID, test.request())
-	if err != nil {
-		return err
-	}
-	t.TestID = testID
 	t.result.Pass = true
-	defer func() {
-		t.mu.Lock()
-		defer t.mu.Unlock()
-		host.EndTest(test.suiteID, testID, t.result)
-	}()
 
-	// Run the test function.
 	done := make(chan struct{})
 	go func() {
 		defer func() {
@@ -397,10 +523,13 @@ func runTest(host *Simulation, test testSpec, runit func(t *T)) error {
 		runit(t)
 	}()
 	<-done
-	return nil
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t
 }
 
-func (spec ClientTestSpec) runTest(host *Simulation, suiteID SuiteID, suite *Suite) error {
+func (spec ClientTestSpec) runTest(host *Simulation, suiteID SuiteID, suite *Suite, parent *T) error {
 	clients, err := host.ClientTypes()
 	if err != nil {
 		return err
@@ -414,11 +543,14 @@ func (spec ClientTestSpec) runTest(host *Simulation, suiteID SuiteID, suite *Sui
 		test := testSpec{
 			suiteID:     suiteID,
 			suite:       suite,
+			parent:      parent.testID(),
+			inShard:     parent.isInShard(),
 			name:        clientTestName(spec.Name, clientDef.Name),
 			displayName: spec.DisplayName,
 			category:    spec.Category,
 			desc:        spec.Description,
 			alwaysRun:   spec.AlwaysRun,
+			retries:     spec.Retries,
 		}
 		err := runTest(host, test, func(t *T) {
 			client := t.StartClient(clientDef.Name, spec.Parameters, WithStaticFiles(spec.Files))
@@ -442,15 +574,18 @@ func clientTestName(name, clientType string) string {
 	return name + " (" + clientType + ")"
 }
 
-func (spec TestSpec) runTest(host *Simulation, suiteID SuiteID, suite *Suite) error {
+func (spec TestSpec) runTest(host *Simulation, suiteID SuiteID, suite *Suite, parent *T) error {
 	test := testSpec{
 		suiteID:     suiteID,
 		suite:       suite,
+		parent:      parent.testID(),
+		inShard:     parent.isInShard(),
 		name:        spec.Name,
 		displayName: spec.DisplayName,
 		category:    spec.Category,
 		desc:        spec.Description,
 		alwaysRun:   spec.AlwaysRun,
+		retries:     spec.Retries,
 	}
 	return runTest(host, test, spec.Run)
 }
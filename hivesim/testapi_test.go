@@ -1,13 +1,18 @@
 package hivesim
 
 import (
+	"fmt"
+	"math"
 	"reflect"
+	"slices"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/hive/internal/libhive"
+	"github.com/ethereum/hive/internal/simapi"
 )
 
 // This test verifies that test errors are reported correctly through the API.
@@ -21,6 +26,9 @@ func TestSuiteReporting(t *testing.T) {
 		Description: "this test passes",
 		Run: func(t *T) {
 			t.Log("message from the passing test")
+			t.ReportMetric("duration", 1, "s")
+			t.ReportMetric("blocks", 10, "")
+			t.ReportMetric("duration", 2.5, "s")
 		},
 	})
 	suite.Add(TestSpec{
@@ -34,7 +42,9 @@ func TestSuiteReporting(t *testing.T) {
 	tm, srv := newFakeAPI(nil)
 	defer srv.Close()
 
-	err := RunSuite(NewAt(srv.URL), suite)
+	sim := NewAt(srv.URL)
+	sim.SetRandomSeed(42)
+	err := RunSuite(sim, suite)
 	if err != nil {
 		t.Fatal("suite run failed:", err)
 	}
@@ -49,6 +59,7 @@ func TestSuiteReporting(t *testing.T) {
 			Name:           suite.Name,
 			Description:    suite.Description,
 			ClientVersions: make(map[string]string),
+			RandomSeed:     42,
 			TestCases: map[libhive.TestID]*libhive.TestCase{
 				1: {
 					Name:        "passing test",
@@ -57,6 +68,10 @@ func TestSuiteReporting(t *testing.T) {
 						Pass:    true,
 						Details: "message from the passing test\n",
 					},
+					Metrics: []libhive.TestMetric{
+						{Name: "duration", Value: 2.5, Unit: "s"},
+						{Name: "blocks", Value: 10},
+					},
 				},
 				2: {
 					Name:        "failing test",
@@ -74,6 +89,380 @@ func TestSuiteReporting(t *testing.T) {
 	}
 }
 
+// This test checks that non-finite metric values are dropped instead of breaking
+// the test result.
+func TestReportMetricNonFinite(t *testing.T) {
+	suite := Suite{Name: "suite"}
+	suite.Add(TestSpec{
+		Name: "test",
+		Run: func(t *T) {
+			t.ReportMetric("nan", math.NaN(), "")
+			t.ReportMetric("inf", math.Inf(-1), "s")
+			t.ReportMetric("ok", 1, "")
+		},
+	})
+
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	if err := RunSuite(NewAt(srv.URL), suite); err != nil {
+		t.Fatal("suite run failed:", err)
+	}
+	tm.Terminate()
+
+	test := tm.Results()[0].TestCases[1]
+	if !test.SummaryResult.Pass {
+		t.Fatal("test failed:", test.SummaryResult.Details)
+	}
+	wantMetrics := []libhive.TestMetric{{Name: "ok", Value: 1}}
+	if !reflect.DeepEqual(test.Metrics, wantMetrics) {
+		t.Fatal("wrong metrics:", spew.Sdump(test.Metrics))
+	}
+	wantDetails := "ReportMetric: dropping non-finite value NaN of metric \"nan\"\n" +
+		"ReportMetric: dropping non-finite value -Inf of metric \"inf\"\n"
+	if test.SummaryResult.Details != wantDetails {
+		t.Fatalf("wrong details: %q", test.SummaryResult.Details)
+	}
+}
+
+// This test checks that subtests are linked to the test that started them.
+func TestSubtestParent(t *testing.T) {
+	suite := Suite{Name: "suite"}
+	suite.Add(TestSpec{
+		Name: "parent",
+		Run: func(t *T) {
+			t.Run(TestSpec{
+				Name: "child",
+				Run: func(t *T) {
+					t.Run(TestSpec{Name: "grandchild", Run: func(t *T) {}})
+				},
+			})
+		},
+	})
+	suite.Add(TestSpec{Name: "toplevel", Run: func(t *T) {}})
+
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	if err := RunSuite(NewAt(srv.URL), suite); err != nil {
+		t.Fatal("suite run failed:", err)
+	}
+	tm.Terminate()
+
+	parents := make(map[string]string)
+	for _, suite := range tm.Results() {
+		for _, test := range suite.TestCases {
+			if test.Parent != 0 {
+				parents[test.Name] = suite.TestCases[test.Parent].Name
+			} else {
+				parents[test.Name] = ""
+			}
+		}
+	}
+	want := map[string]string{
+		"parent":     "",
+		"child":      "parent",
+		"grandchild": "child",
+		"toplevel":   "",
+	}
+	if !reflect.DeepEqual(parents, want) {
+		t.Fatalf("wrong test hierarchy: %v", parents)
+	}
+}
+
+// This test checks that failing tests are retried and recorded as flaky.
+func TestRetries(t *testing.T) {
+	var runs int
+	suite := Suite{Name: "suite"}
+	suite.Add(TestSpec{
+		Name:    "flaky",
+		Retries: 3,
+		Run: func(t *T) {
+			runs++
+			if runs < 3 {
+				t.Fatal("attempt", runs, "failed")
+			}
+		},
+	})
+	suite.Add(TestSpec{
+		Name: "failing",
+		Run:  func(t *T) { t.Fatal("always fails") },
+	})
+	suite.Add(TestSpec{
+		Name:    "not retried",
+		Retries: -1,
+		Run:     func(t *T) { t.Fatal("always fails") },
+	})
+	var parentRuns int
+	suite.Add(TestSpec{
+		Name: "parent",
+		Run: func(t *T) {
+			parentRuns++
+			t.Run(TestSpec{Name: "child", Retries: -1, Run: func(t *T) {}})
+			if parentRuns < 2 {
+				t.Fatal("first attempt failed")
+			}
+		},
+	})
+
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	sim := NewAt(srv.URL)
+	sim.SetRetries(1)
+	if err := RunSuite(sim, suite); err != nil {
+		t.Fatal("suite run failed:", err)
+	}
+	tm.Terminate()
+
+	tests := make(map[string]*libhive.TestCase)
+	for _, suite := range tm.Results() {
+		for _, test := range suite.TestCases {
+			if tests[test.Name] != nil {
+				t.Errorf("test %q recorded more than once", test.Name)
+			}
+			tests[test.Name] = test
+		}
+	}
+	flaky := tests["flaky"]
+	if !flaky.SummaryResult.Pass || !flaky.SummaryResult.Flaky {
+		t.Errorf("flaky test has wrong result: %+v", flaky.SummaryResult)
+	}
+	if len(flaky.Attempts) != 2 {
+		t.Fatalf("flaky test has %d attempts recorded, want 2", len(flaky.Attempts))
+	}
+	if d := flaky.Attempts[1].SummaryResult.Details; d != "attempt 2 failed\n" {
+		t.Errorf("wrong details of second attempt: %q", d)
+	}
+	failing := tests["failing"]
+	if failing.SummaryResult.Pass || failing.SummaryResult.Flaky {
+		t.Errorf("failing test has wrong result: %+v", failing.SummaryResult)
+	}
+	if len(failing.Attempts) != 1 {
+		t.Errorf("failing test has %d attempts recorded, want 1", len(failing.Attempts))
+	}
+	if n := len(tests["not retried"].Attempts); n != 0 {
+		t.Errorf("test with retries disabled has %d attempts recorded, want 0", n)
+	}
+
+	// The subtest of the failed attempt is moved into the attempt.
+	parent := tests["parent"]
+	if !parent.SummaryResult.Pass || len(parent.Attempts) != 1 {
+		t.Fatalf("parent test has wrong result %+v, %d attempts", parent.SummaryResult, len(parent.Attempts))
+	}
+	if n := len(parent.Attempts[0].Subtests); n != 1 {
+		t.Errorf("failed attempt has %d subtests, want 1", n)
+	}
+	if child := tests["child"]; child == nil || !child.SummaryResult.Pass {
+		t.Errorf("subtest of final attempt has wrong result: %+v", child)
+	}
+}
+
+// This test checks that the shards of a simulation partition its tests.
+func TestShard(t *testing.T) {
+	suite := Suite{Name: "suite"}
+	suite.Add(TestSpec{
+		Name:      "launcher",
+		AlwaysRun: true,
+		Run: func(t *T) {
+			for i := 0; i < 10; i++ {
+				t.Run(TestSpec{Name: fmt.Sprintf("launched-%d", i), Run: func(t *T) {}})
+			}
+		},
+	})
+	for i := 0; i < 20; i++ {
+		suite.Add(TestSpec{
+			Name: fmt.Sprintf("test-%d", i),
+			Run: func(t *T) {
+				t.Run(TestSpec{Name: "subtest", Run: func(t *T) {}})
+			},
+		})
+	}
+
+	const shards = 3
+	seen := make(map[string]int)
+	for i := 1; i <= shards; i++ {
+		tm, srv := newFakeAPI(nil)
+		sim := NewAt(srv.URL)
+		sim.SetShard(i, shards)
+		if err := RunSuite(sim, suite); err != nil {
+			t.Fatal("suite run failed:", err)
+		}
+		srv.Close()
+		tm.Terminate()
+
+		var names []string
+		for _, suite := range tm.Results() {
+			for _, test := range suite.TestCases {
+				names = append(names, test.Name)
+			}
+		}
+		if !slices.Contains(names, "launcher") {
+			t.Errorf("shard %d: AlwaysRun test did not run", i)
+		}
+		if !slices.Contains(names, "subtest") {
+			t.Errorf("shard %d: no subtests ran", i)
+		}
+		for _, name := range names {
+			seen[name]++
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("launched-%d", i)
+		if seen[name] != 1 {
+			t.Errorf("test %q ran in %d shards, want 1", name, seen[name])
+		}
+	}
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("test-%d", i)
+		if seen[name] != 1 {
+			t.Errorf("test %q ran in %d shards, want 1", name, seen[name])
+		}
+	}
+	if seen["subtest"] != 20 {
+		t.Errorf("subtests ran %d times, want 20", seen["subtest"])
+	}
+}
+
+// This test checks that list mode reports all tests to hive without running them.
+func TestListTests(t *testing.T) {
+	var ran bool
+	suite := Suite{Name: "suite", Description: "the suite"}
+	suite.Add(TestSpec{
+		Name:     "selected",
+		Category: "cat",
+		Run:      func(t *T) { ran = true },
+	})
+	suite.Add(TestSpec{
+		Name: "other",
+		Run:  func(t *T) { ran = true },
+	})
+	suite.Add(ClientTestSpec{
+		Name: "selected CLIENT",
+		Run:  func(t *T, c *Client) { ran = true },
+	})
+
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	sim := NewAt(srv.URL)
+	sim.docs = newTestListCollector(srv.URL)
+	sim.SetTestPattern("suite/selected")
+	if err := RunSuite(sim, suite); err != nil {
+		t.Fatal("suite run failed:", err)
+	}
+	tm.Terminate()
+
+	if ran {
+		t.Error("test function was executed in list mode")
+	}
+	if len(tm.Results()) != 0 {
+		t.Error("list mode reported test results")
+	}
+	list := tm.TestList()
+	if list == nil || len(list.Suites) != 1 {
+		t.Fatalf("wrong test list: %+v", list)
+	}
+	want := []simapi.ListedTest{
+		{Name: "selected", Category: "cat", Matched: true},
+		{Name: "other", Matched: false},
+		{Name: "selected client-1", Matched: true},
+		{Name: "selected client-2", Matched: true},
+	}
+	if !reflect.DeepEqual(list.Suites[0].Tests, want) {
+		t.Errorf("wrong tests listed:\n got %+v\nwant %+v", list.Suites[0].Tests, want)
+	}
+}
+
+// This test checks that failing AlwaysRun tests are not retried in list mode, since
+// retrying is not supported there.
+func TestListTestsRetries(t *testing.T) {
+	suite := Suite{Name: "suite"}
+	suite.Add(TestSpec{
+		Name:      "launcher",
+		AlwaysRun: true,
+		Run: func(t *T) {
+			t.Run(TestSpec{Name: "launched", Run: func(t *T) {}})
+			t.Fatal("launcher failed")
+		},
+	})
+
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	t.Setenv("HIVE_SIMULATOR", srv.URL)
+	t.Setenv("HIVE_LIST_TESTS", "true")
+	t.Setenv("HIVE_RETRIES", "2")
+	if err := RunSuite(New(), suite); err != nil {
+		t.Fatal("suite run failed:", err)
+	}
+	tm.Terminate()
+
+	list := tm.TestList()
+	if list == nil || len(list.Suites) != 1 {
+		t.Fatalf("wrong test list: %+v", list)
+	}
+	var names []string
+	for _, test := range list.Suites[0].Tests {
+		names = append(names, test.Name)
+	}
+	if want := []string{"launcher", "launched"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("wrong tests listed: %v, want %v", names, want)
+	}
+}
+
+// This test checks that T.Rand is deterministic for a given seed and test name.
+func TestRandPerTest(t *testing.T) {
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	defer tm.Terminate()
+
+	// draw runs the suite and returns the first value drawn by each test.
+	draw := func(seed int64) map[string]int64 {
+		var (
+			mu     sync.Mutex
+			values = make(map[string]int64)
+		)
+		record := func(t *T) {
+			mu.Lock()
+			defer mu.Unlock()
+			values[t.name] = t.Rand().Int63()
+		}
+		suite := Suite{Name: "suite"}
+		suite.Add(TestSpec{Name: "test-a", Run: record})
+		suite.Add(TestSpec{Name: "test-b", Run: record})
+		sim := NewAt(srv.URL)
+		sim.SetRandomSeed(seed)
+		if err := RunSuite(sim, suite); err != nil {
+			t.Fatal("suite run failed:", err)
+		}
+		return values
+	}
+
+	run1, run2 := draw(1), draw(1)
+	if !reflect.DeepEqual(run1, run2) {
+		t.Fatalf("different values for same seed: %v, %v", run1, run2)
+	}
+	if run1["test-a"] == run1["test-b"] {
+		t.Fatal("tests with different names got the same random values")
+	}
+	if run3 := draw(2); reflect.DeepEqual(run1, run3) {
+		t.Fatal("same values for different seeds")
+	}
+
+	// Check the seed is recorded in the suite result.
+	for _, suite := range tm.Results() {
+		if suite.RandomSeed == 0 {
+			t.Fatalf("suite %d has no random seed", suite.ID)
+		}
+	}
+}
+
+// This test checks that generated seeds can be displayed exactly by hiveview.
+func TestNewRandomSeed(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if seed := newRandomSeed(); seed < 1 || seed > maxRandomSeed {
+			t.Fatalf("seed %d out of range", seed)
+		}
+	}
+}
+
 // removeTimestamps removes test timestamps in results so they can be
 // compared using reflect.DeepEqual.
 func removeTimestamps(result map[libhive.TestSuiteID]*libhive.TestSuite) {
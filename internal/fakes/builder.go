@@ -1,7 +1,9 @@
 package fakes
 
 import (
+	"archive/tar"
 	"context"
+	"io"
 	"io/fs"
 
 	"github.com/ethereum/hive/internal/libhive"
@@ -12,6 +14,8 @@ type BuilderHooks struct {
 	BuildClientImage    func(context.Context, libhive.ClientDesignator) (string, error)
 	BuildSimulatorImage func(context.Context, string, map[string]string) (string, error)
 	ReadFile            func(ctx context.Context, image string, file string) ([]byte, error)
+	SaveImages          func(ctx context.Context, images []string, w io.Writer) error
+	LoadImages          func(ctx context.Context, r io.Reader) error
 }
 
 // fakeBuilder implements Backend without docker.
@@ -46,6 +50,29 @@ func (b *fakeBuilder) BuildImage(ctx context.Context, name string, fsys fs.FS) e
 	return nil
 }
 
+// SaveImages writes a tar archive with an empty entry for each image, unless overridden
+// by a hook.
+func (b *fakeBuilder) SaveImages(ctx context.Context, images []string, w io.Writer) error {
+	if b.hooks.SaveImages != nil {
+		return b.hooks.SaveImages(ctx, images, w)
+	}
+	tw := tar.NewWriter(w)
+	for _, image := range images {
+		if err := tw.WriteHeader(&tar.Header{Name: image, Mode: 0644}); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func (b *fakeBuilder) LoadImages(ctx context.Context, r io.Reader) error {
+	if b.hooks.LoadImages != nil {
+		return b.hooks.LoadImages(ctx, r)
+	}
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
 func (b *fakeBuilder) ReadFile(ctx context.Context, image, file string) ([]byte, error) {
 	if b.hooks.ReadFile != nil {
 		return b.hooks.ReadFile(ctx, image, file)
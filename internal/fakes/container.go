@@ -19,6 +19,7 @@ type BackendHooks struct {
 	DeleteContainer  func(containerID string) error
 	PauseContainer   func(containerID string) error
 	UnpauseContainer func(containerID string) error
+	SetDiskReadOnly  func(containerID, path string, readOnly bool) error
 	RunProgram       func(containerID string, cmd []string) (*libhive.ExecInfo, error)
 
 	NetworkNameToID     func(string) (string, error)
@@ -27,6 +28,9 @@ type BackendHooks struct {
 	ContainerIP         func(containerID, networkID string) (net.IP, error)
 	ConnectContainer    func(containerID, networkID string) error
 	DisconnectContainer func(containerID, networkID string) error
+
+	ListResources  func() ([]libhive.Resource, error)
+	RemoveResource func(libhive.Resource) error
 }
 
 var _ = libhive.ContainerBackend(&fakeBackend{})
@@ -67,6 +71,10 @@ func (b *fakeBackend) Build(context.Context, libhive.Builder) error {
 	return nil
 }
 
+func (b *fakeBackend) HelperImages() []string {
+	return nil
+}
+
 func (b *fakeBackend) ServeAPI(ctx context.Context, h http.Handler) (libhive.APIServer, error) {
 	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -131,7 +139,9 @@ func (b *fakeBackend) StartContainer(ctx context.Context, containerID string, op
 	if info.MAC == "" {
 		info.MAC = "00:80:41:ae:fd:7e"
 	}
-	info.Wait = func() {}
+	if info.Wait == nil {
+		info.Wait = func() {}
+	}
 	return &info, nil
 }
 
@@ -161,6 +171,13 @@ func (b *fakeBackend) UnpauseContainer(containerID string) error {
 	return nil
 }
 
+func (b *fakeBackend) SetDiskReadOnly(ctx context.Context, containerID, path string, readOnly bool) error {
+	if b.hooks.SetDiskReadOnly != nil {
+		return b.hooks.SetDiskReadOnly(containerID, path, readOnly)
+	}
+	return nil
+}
+
 func (b *fakeBackend) RunProgram(ctx context.Context, containerID string, cmd []string) (*libhive.ExecInfo, error) {
 	if b.hooks.RunProgram != nil {
 		return b.hooks.RunProgram(containerID, cmd)
@@ -175,7 +192,7 @@ func (b *fakeBackend) NetworkNameToID(name string) (string, error) {
 	return "", errors.New("network not found")
 }
 
-func (b *fakeBackend) CreateNetwork(name string) (string, error) {
+func (b *fakeBackend) CreateNetwork(name string, labels map[string]string) (string, error) {
 	if b.hooks.CreateNetwork != nil {
 		return b.hooks.CreateNetwork(name)
 	}
@@ -210,3 +227,17 @@ func (b *fakeBackend) DisconnectContainer(containerID, networkID string) error {
 	}
 	return nil
 }
+
+func (b *fakeBackend) ListResources(ctx context.Context) ([]libhive.Resource, error) {
+	if b.hooks.ListResources != nil {
+		return b.hooks.ListResources()
+	}
+	return nil, nil
+}
+
+func (b *fakeBackend) RemoveResource(ctx context.Context, r libhive.Resource) error {
+	if b.hooks.RemoveResource != nil {
+		return b.hooks.RemoveResource(r)
+	}
+	return nil
+}
@@ -38,15 +38,94 @@ func NewBuilder(client *docker.Client, cfg *Config, auth Authenticator) *Builder
 	return b
 }
 
-// BuildClientImage builds a docker image of the given client.
+// BuildClientImage builds a docker image of the given client. Prebuilt client images
+// are tagged with the hive image name instead.
 func (b *Builder) BuildClientImage(ctx context.Context, client libhive.ClientDesignator) (string, error) {
-	dir := b.config.Inventory.ClientDirectory(client)
 	tag := fmt.Sprintf("hive/clients/%s:latest", client.Name())
+	if client.IsPrebuilt() {
+		err := b.tagPrebuiltImage(ctx, client.Image, tag)
+		return tag, err
+	}
+	dir := b.config.Inventory.ClientDirectory(client)
 	dockerFile := client.Dockerfile()
 	err := b.buildImage(ctx, dir, dockerFile, tag, client.BuildArgs)
 	return tag, err
 }
 
+// tagPrebuiltImage makes a prebuilt image available under the given tag. The image is
+// pulled if it doesn't exist locally, or when pulling is enabled.
+func (b *Builder) tagPrebuiltImage(ctx context.Context, image, tag string) error {
+	logger := b.logger.With("image", tag, "source", image)
+	if _, err := b.client.InspectImage(image); err != nil || b.config.PullEnabled {
+		logger.Info("pulling prebuilt image")
+		if err := b.pullImage(ctx, image, tag); err != nil {
+			logger.Error("image pull failed", "err", err)
+			return err
+		}
+	}
+	repo, version := libhive.SplitImageRef(tag)
+	opts := docker.TagImageOptions{Repo: repo, Tag: version, Force: true, Context: ctx}
+	if err := b.client.TagImage(image, opts); err != nil {
+		logger.Error("can't tag prebuilt image", "err", err)
+		return err
+	}
+	logger.Info("using prebuilt image")
+	return nil
+}
+
+// pullImage pulls an image from its registry. The output is written to the build log
+// of the given hive image.
+func (b *Builder) pullImage(ctx context.Context, image, hiveImage string) error {
+	repo, tag := libhive.SplitImageRef(image)
+	output, closeOutput, err := b.buildOutput(hiveImage)
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	opts := docker.PullImageOptions{
+		Repository:   repo,
+		Tag:          tag,
+		Context:      ctx,
+		OutputStream: output,
+	}
+	var auth docker.AuthConfiguration
+	if b.authenticator != nil {
+		configs := b.authenticator.AuthConfigs().Configs
+		for _, key := range registryAuthKeys(imageRegistry(repo)) {
+			if a, ok := configs[key]; ok {
+				auth = a
+				break
+			}
+		}
+	}
+	return b.client.PullImage(opts, auth)
+}
+
+const dockerHubRegistry = "docker.io"
+
+// imageRegistry returns the registry host of an image repository. Like docker, it
+// treats the first path component as the registry only if it contains a '.' or ':',
+// or is "localhost". Other repositories, e.g. "user/image", are on Docker Hub.
+func imageRegistry(repo string) string {
+	first, _, ok := strings.Cut(repo, "/")
+	if !ok || (first != "localhost" && !strings.ContainsAny(first, ".:")) {
+		return dockerHubRegistry
+	}
+	if first == "index.docker.io" {
+		return dockerHubRegistry
+	}
+	return first
+}
+
+// registryAuthKeys returns the keys under which credentials of a registry can be
+// configured. Docker Hub credentials are usually stored under the index URL.
+func registryAuthKeys(registry string) []string {
+	if registry == dockerHubRegistry {
+		return []string{"https://index.docker.io/v1/", "index.docker.io", dockerHubRegistry}
+	}
+	return []string{registry}
+}
+
 // BuildSimulatorImage builds a docker image of a simulator.
 func (b *Builder) BuildSimulatorImage(ctx context.Context, name string, buildArgs map[string]string) (string, error) {
 	dir := b.config.Inventory.SimulatorDirectory(name)
@@ -208,6 +287,19 @@ func (b *Builder) ReadFile(ctx context.Context, image, path string) ([]byte, err
 	}
 }
 
+// SaveImages writes the given images to w as a tar archive, like 'docker save'.
+func (b *Builder) SaveImages(ctx context.Context, images []string, w io.Writer) error {
+	b.logger.Info("saving images", "count", len(images))
+	opts := docker.ExportImagesOptions{Names: images, OutputStream: w, Context: ctx}
+	return b.client.ExportImages(opts)
+}
+
+// LoadImages loads images from a tar archive, like 'docker load'.
+func (b *Builder) LoadImages(ctx context.Context, r io.Reader) error {
+	b.logger.Info("loading images")
+	return b.client.LoadImage(docker.LoadImageOptions{InputStream: r, Context: ctx})
+}
+
 // buildImage builds a single docker image from the specified context.
 // branch specifies a build argument to use a specific base image branch or github source branch.
 func (b *Builder) buildImage(ctx context.Context, contextDir, dockerFile, imageTag string, buildArgs map[string]string) error {
@@ -230,6 +322,14 @@ func (b *Builder) buildImage(ctx context.Context, contextDir, dockerFile, imageT
 		opts.BuildArgs = args
 	}
 
+	output, closeOutput, err := b.buildOutput(imageTag)
+	if err != nil {
+		logger.Error("can't create build log", "err", err)
+		return err
+	}
+	defer closeOutput()
+	opts.OutputStream = output
+
 	logger.Info("building image", logctx...)
 	if err := b.client.BuildImage(opts); err != nil {
 		logger.Error("image build failed", "err", err)
@@ -238,6 +338,80 @@ func (b *Builder) buildImage(ctx context.Context, contextDir, dockerFile, imageT
 	return nil
 }
 
+// buildOutput creates the output stream of an image build. Since multiple images can
+// be built concurrently, lines written to the shared build output are prefixed with the
+// image name.
+func (b *Builder) buildOutput(imageTag string) (io.Writer, func(), error) {
+	var (
+		writers []io.Writer
+		closers []func()
+	)
+	if b.config.BuildOutput != nil {
+		pw := newPrefixWriter(b.config.BuildOutput, "["+imageTag+"] ")
+		writers = append(writers, pw)
+		closers = append(closers, pw.flush)
+	}
+	if b.config.BuildLogRoot != "" {
+		file := filepath.Join(b.config.BuildLogRoot, libhive.BuildLogFile(imageTag))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return nil, nil, err
+		}
+		f, err := os.Create(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		writers = append(writers, f)
+		closers = append(closers, func() { f.Close() })
+	}
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+	if len(writers) == 0 {
+		return io.Discard, closeAll, nil
+	}
+	return io.MultiWriter(writers...), closeAll, nil
+}
+
+// prefixWriter writes complete lines to the underlying writer, adding a prefix to each
+// line. It is used to tell apart the output of concurrent builds.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: prefix}
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.buf = append(pw.buf, p...)
+	for {
+		i := bytes.IndexByte(pw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := make([]byte, 0, len(pw.prefix)+i+1)
+		line = append(line, pw.prefix...)
+		line = append(line, pw.buf[:i+1]...)
+		pw.buf = pw.buf[i+1:]
+		if _, err := pw.w.Write(line); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes any remaining incomplete line.
+func (pw *prefixWriter) flush() {
+	if len(pw.buf) > 0 {
+		pw.w.Write([]byte(pw.prefix + string(pw.buf) + "\n"))
+		pw.buf = nil
+	}
+}
+
 func convertBuildArgs(m map[string]string) []docker.BuildArg {
 	args := make([]docker.BuildArg, 0, len(m))
 	for key, value := range m {
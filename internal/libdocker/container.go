@@ -12,6 +12,8 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,7 +27,8 @@ type ContainerBackend struct {
 	config *Config
 	logger *slog.Logger
 
-	proxy *hiveproxy.Proxy
+	proxyMu sync.Mutex
+	proxy   *hiveproxy.Proxy // used for CheckLive when the request has no proxy
 }
 
 func NewContainerBackend(c *docker.Client, cfg *Config) *ContainerBackend {
@@ -36,8 +39,29 @@ func NewContainerBackend(c *docker.Client, cfg *Config) *ContainerBackend {
 	return b
 }
 
+// setProxy replaces the registered proxy if it is currently old.
+func (b *ContainerBackend) setProxy(old, new *hiveproxy.Proxy) {
+	b.proxyMu.Lock()
+	defer b.proxyMu.Unlock()
+	if b.proxy == old || old == nil {
+		b.proxy = new
+	}
+}
+
+func (b *ContainerBackend) getProxy() *hiveproxy.Proxy {
+	b.proxyMu.Lock()
+	defer b.proxyMu.Unlock()
+	return b.proxy
+}
+
 // RunProgram runs a /hive-bin script in a container.
 func (b *ContainerBackend) RunProgram(ctx context.Context, containerID string, cmd []string) (*libhive.ExecInfo, error) {
+	return b.exec(ctx, containerID, "", cmd)
+}
+
+// exec runs a command in a container as the given user. If user is empty,
+// the default user of the container is used.
+func (b *ContainerBackend) exec(ctx context.Context, containerID, user string, cmd []string) (*libhive.ExecInfo, error) {
 	exec, err := b.client.CreateExec(docker.CreateExecOptions{
 		Context:      ctx,
 		AttachStdout: true,
@@ -45,6 +69,7 @@ func (b *ContainerBackend) RunProgram(ctx context.Context, containerID string, c
 		Tty:          false,
 		Cmd:          cmd,
 		Container:    containerID,
+		User:         user,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("can't create exec %v: %v", cmd, err)
@@ -81,8 +106,9 @@ func (b *ContainerBackend) CreateContainer(ctx context.Context, imageName string
 	createOpts := docker.CreateContainerOptions{
 		Context: ctx,
 		Config: &docker.Config{
-			Image: imageName,
-			Env:   vars,
+			Image:  imageName,
+			Env:    vars,
+			Labels: libhive.ResourceLabels(opt.Labels),
 		},
 	}
 
@@ -98,6 +124,17 @@ func (b *ContainerBackend) CreateContainer(ctx context.Context, imageName string
 		// but it's probably best to give Docker the info as early as possible.
 		createOpts.Config.AttachStdout = true
 	}
+	if opt.DiskPath != "" {
+		// The fault injection disk is a tmpfs. It is made read-only by the disk
+		// helper, so the client doesn't need any extra privileges.
+		tmpfsOpt := "rw"
+		if opt.DiskQuota > 0 {
+			tmpfsOpt += ",size=" + strconv.FormatInt(opt.DiskQuota, 10)
+		}
+		createOpts.HostConfig = &docker.HostConfig{
+			Tmpfs: map[string]string{opt.DiskPath: tmpfsOpt},
+		}
+	}
 
 	c, err := b.client.CreateContainer(createOpts)
 	if err != nil {
@@ -117,7 +154,11 @@ func (b *ContainerBackend) CreateContainer(ctx context.Context, imageName string
 
 // StartContainer starts a docker container.
 func (b *ContainerBackend) StartContainer(ctx context.Context, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
-	if opt.CheckLive != 0 && b.proxy == nil {
+	proxy := proxyFromContext(ctx)
+	if proxy == nil {
+		proxy = b.getProxy()
+	}
+	if opt.CheckLive != 0 && proxy == nil {
 		panic("attempt to start container with CheckLive, but proxy is not running")
 	}
 
@@ -165,7 +206,7 @@ func (b *ContainerBackend) StartContainer(ctx context.Context, containerID strin
 		defer cancel()
 		addr := &net.TCPAddr{IP: net.ParseIP(info.IP), Port: int(opt.CheckLive)}
 		go func() {
-			err := b.proxy.CheckLive(ctx, addr)
+			err := proxy.CheckLive(ctx, addr)
 			if err == nil {
 				close(hasStarted)
 			}
@@ -178,7 +219,8 @@ func (b *ContainerBackend) StartContainer(ctx context.Context, containerID strin
 	var checkErr error
 	select {
 	case <-hasStarted:
-		logger.Debug("container online", "time", time.Since(startTime))
+		info.StartupTime = time.Since(startTime)
+		logger.Debug("container online", "time", info.StartupTime)
 	case <-containerExit:
 		checkErr = errors.New("terminated unexpectedly")
 	case <-ctx.Done():
@@ -223,11 +265,12 @@ func (b *ContainerBackend) UnpauseContainer(containerID string) error {
 }
 
 // CreateNetwork creates a docker network.
-func (b *ContainerBackend) CreateNetwork(name string) (string, error) {
+func (b *ContainerBackend) CreateNetwork(name string, labels map[string]string) (string, error) {
 	network, err := b.client.CreateNetwork(docker.CreateNetworkOptions{
 		Name:           name,
 		CheckDuplicate: true,
 		Attachable:     true,
+		Labels:         libhive.ResourceLabels(labels),
 	})
 	if err != nil {
 		return "", err
@@ -235,6 +278,51 @@ func (b *ContainerBackend) CreateNetwork(name string) (string, error) {
 	return network.ID, nil
 }
 
+// ListResources returns all containers and networks labelled by hive.
+func (b *ContainerBackend) ListResources(ctx context.Context) ([]libhive.Resource, error) {
+	containers, err := b.client.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Filters: map[string][]string{"label": {libhive.LabelRunID}},
+		Context: ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	networks, err := b.client.FilteredListNetworks(docker.NetworkFilterOpts{"label": {libhive.LabelRunID: true}})
+	if err != nil {
+		return nil, err
+	}
+	var list []libhive.Resource
+	for _, c := range containers {
+		var name string
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		list = append(list, libhive.Resource{Kind: "container", ID: c.ID, Name: name, Labels: c.Labels})
+	}
+	for _, n := range networks {
+		list = append(list, libhive.Resource{Kind: "network", ID: n.ID, Name: n.Name, Labels: n.Labels})
+	}
+	return list, nil
+}
+
+// RemoveResource deletes a container or network returned by ListResources.
+func (b *ContainerBackend) RemoveResource(ctx context.Context, r libhive.Resource) error {
+	switch r.Kind {
+	case "container":
+		return b.client.RemoveContainer(docker.RemoveContainerOptions{
+			ID:            r.ID,
+			Force:         true,
+			RemoveVolumes: true,
+			Context:       ctx,
+		})
+	case "network":
+		return b.client.RemoveNetwork(r.ID)
+	default:
+		return fmt.Errorf("unknown resource kind %q", r.Kind)
+	}
+}
+
 // NetworkNameToID finds the network ID of network by the given name.
 func (b *ContainerBackend) NetworkNameToID(name string) (string, error) {
 	networks, err := b.client.ListNetworks()
@@ -0,0 +1,126 @@
+package libdocker
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/ethereum/hive/internal/libhive"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+const diskHelperTag = "hive/diskhelper"
+
+//go:embed diskhelper/Dockerfile
+var diskHelperFiles embed.FS
+
+func diskHelperSource() fs.FS {
+	sub, err := fs.Sub(diskHelperFiles, "diskhelper")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// diskHelperScript runs in the disk helper container, which shares the PID namespace of
+// the client. It enters the mount namespace of the client's init process and runs
+// diskMountScript there. The busybox binary of the helper is reachable from the client's
+// namespace through /proc/<pid>/root of the helper shell, so nothing is required of
+// the client image.
+//
+// Arguments: diskMountScript, mode, path.
+const diskHelperScript = `bb=/proc/$$/root/bin/busybox
+nsenter -t 1 -m -- "$bb" sh -c "$1" sh "$bb" "$2" "$3"
+exit $?`
+
+// diskMountScript switches the fault injection disk between read-only and writable.
+//
+// A read-only remount of the tmpfs itself fails with EBUSY while the client has files
+// open for writing. Instead, the directory is bind-mounted onto itself and the new
+// mount is made read-only, which always works because it has no writers yet. Removing
+// the bind mount makes the disk writable again.
+//
+// Arguments: busybox path, mode, path.
+const diskMountScript = `bb=$1 mode=$2 dir=$3
+top=$("$bb" awk -v d="$dir" '$5 == d { o = $6 } END { print o }' /proc/self/mountinfo)
+case "$mode,$top" in
+	*,)           echo "$dir is not a mount point" >&2; exit 1 ;;
+	ro,ro*|rw,rw*) ;;
+	ro,*)         "$bb" mount --bind "$dir" "$dir" && "$bb" mount -o remount,bind,ro "$dir" ;;
+	rw,*)         "$bb" umount -l "$dir" ;;
+esac`
+
+// SetDiskReadOnly makes the fault injection disk of a container read-only or writable.
+//
+// The mount is changed by a privileged helper container, the client container itself
+// runs without extra privileges. Making the disk read-only works while the client has
+// files open. Files created or opened for writing afterwards fail with EROFS, but
+// writes through file descriptors which were already open keep working.
+func (b *ContainerBackend) SetDiskReadOnly(ctx context.Context, containerID, path string, readOnly bool) error {
+	mode := "rw"
+	if readOnly {
+		mode = "ro"
+	}
+	b.logger.Debug("remounting container disk", "container", containerID[:8], "path", path, "mode", mode)
+
+	cmd := []string{"sh", "-c", diskHelperScript, "sh", diskMountScript, mode, path}
+	info, err := b.runDiskHelper(ctx, containerID, cmd)
+	if err != nil {
+		return err
+	}
+	if info.ExitCode != 0 {
+		output := strings.TrimSpace(info.Stderr + info.Stdout)
+		return fmt.Errorf("remount of %s failed (exit code %d): %s", path, info.ExitCode, output)
+	}
+	return nil
+}
+
+// runDiskHelper runs the disk helper image in the PID namespace of a container.
+func (b *ContainerBackend) runDiskHelper(ctx context.Context, containerID string, cmd []string) (*libhive.ExecInfo, error) {
+	c, err := b.client.CreateContainer(docker.CreateContainerOptions{
+		Context: ctx,
+		Config: &docker.Config{
+			Image:  diskHelperTag,
+			Cmd:    cmd,
+			Labels: libhive.ResourceLabels(nil),
+		},
+		HostConfig: &docker.HostConfig{
+			Privileged:  true,
+			PidMode:     "container:" + containerID,
+			NetworkMode: "none",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't create disk helper: %v", err)
+	}
+	defer b.client.RemoveContainer(docker.RemoveContainerOptions{ID: c.ID, Force: true})
+
+	if err := b.client.StartContainerWithContext(c.ID, nil, ctx); err != nil {
+		return nil, fmt.Errorf("can't start disk helper: %v", err)
+	}
+	exitCode, err := b.client.WaitContainerWithContext(c.ID, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't wait for disk helper: %v", err)
+	}
+	outputBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	err = b.client.Logs(docker.LogsOptions{
+		Context:      ctx,
+		Container:    c.ID,
+		OutputStream: outputBuf,
+		ErrorStream:  errBuf,
+		Stdout:       true,
+		Stderr:       true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't read disk helper output: %v", err)
+	}
+	return &libhive.ExecInfo{
+		Stdout:   outputBuf.String(),
+		Stderr:   errBuf.String(),
+		ExitCode: exitCode,
+	}, nil
+}
@@ -0,0 +1,98 @@
+package libdocker
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+// This Dockerfile creates a 'client' which keeps a file on its disk open for writing.
+const diskClientDockerfile = `FROM busybox:1.36
+ENTRYPOINT ["sh", "-c", "exec 3>/data/held; while sleep 1; do echo x >&3; done"]
+`
+
+// This test checks that the disk of a client can be made read-only and writable again
+// while the client has a file open for writing. It requires a docker daemon.
+func TestSetDiskReadOnly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	builder, backend, err := Connect("", &Config{})
+	if err != nil {
+		t.Skip("docker is not available:", err)
+	}
+	if err := builder.BuildImage(ctx, diskHelperTag, diskHelperSource()); err != nil {
+		t.Fatal("can't build disk helper:", err)
+	}
+	clientFS := fstest.MapFS{"Dockerfile": {Data: []byte(diskClientDockerfile)}}
+	if err := builder.BuildImage(ctx, "hive/test-diskclient", clientFS); err != nil {
+		t.Fatal("can't build client:", err)
+	}
+
+	opt := libhive.ContainerOptions{
+		LogFile:   filepath.Join(t.TempDir(), "client.log"),
+		DiskPath:  "/data",
+		DiskQuota: 1 << 20,
+	}
+	id, err := backend.CreateContainer(ctx, "hive/test-diskclient", opt)
+	if err != nil {
+		t.Fatal("can't create client:", err)
+	}
+	defer backend.DeleteContainer(id)
+	if _, err := backend.StartContainer(ctx, id, opt); err != nil {
+		t.Fatal("can't start client:", err)
+	}
+	waitForFile(t, ctx, backend, id, "/data/held")
+
+	// Making the disk read-only must work even though /data/held is open.
+	if err := backend.SetDiskReadOnly(ctx, id, "/data", true); err != nil {
+		t.Fatal("can't make disk read-only:", err)
+	}
+	if err := backend.SetDiskReadOnly(ctx, id, "/data", true); err != nil {
+		t.Fatal("can't make disk read-only again:", err)
+	}
+	checkTouch(t, ctx, backend, id, "/data/new", false)
+
+	if err := backend.SetDiskReadOnly(ctx, id, "/data", false); err != nil {
+		t.Fatal("can't make disk writable:", err)
+	}
+	checkTouch(t, ctx, backend, id, "/data/new", true)
+
+	// A directory which isn't the disk can't be made read-only.
+	if err := backend.SetDiskReadOnly(ctx, id, "/tmp", true); err == nil {
+		t.Fatal("no error for path which is not a mount point")
+	}
+}
+
+func waitForFile(t *testing.T, ctx context.Context, b *ContainerBackend, id, file string) {
+	t.Helper()
+	for {
+		info, err := b.exec(ctx, id, "", []string{"test", "-e", file})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ExitCode == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("%s was not created", file)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func checkTouch(t *testing.T, ctx context.Context, b *ContainerBackend, id, file string, wantOK bool) {
+	t.Helper()
+	info, err := b.exec(ctx, id, "", []string{"touch", file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok := info.ExitCode == 0; ok != wantOK {
+		t.Fatalf("touch %s: ok=%t, want %t (output: %s)", file, ok, wantOK, info.Stderr)
+	}
+}
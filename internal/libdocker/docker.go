@@ -29,6 +29,10 @@ type Config struct {
 	ContainerOutput io.Writer
 	BuildOutput     io.Writer
 
+	// If set, the output of each client and simulator image build is also written
+	// to a separate log file in this directory. See libhive.NewBuildLogDir.
+	BuildLogRoot string
+
 	// This tells the docker client whether to authenticate requests with credential helper
 	UseCredentialHelper bool
 }
@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/hive/hiveproxy"
 	"github.com/ethereum/hive/internal/libhive"
@@ -14,9 +15,17 @@ import (
 
 const hiveproxyTag = "hive/hiveproxy"
 
-// Build builds the hiveproxy image.
+// Build builds the hiveproxy and disk helper images.
 func (cb *ContainerBackend) Build(ctx context.Context, b libhive.Builder) error {
-	return b.BuildImage(ctx, hiveproxyTag, hiveproxy.Source)
+	if err := b.BuildImage(ctx, hiveproxyTag, hiveproxy.Source); err != nil {
+		return err
+	}
+	return b.BuildImage(ctx, diskHelperTag, diskHelperSource())
+}
+
+// HelperImages returns the names of the images built by Build.
+func (cb *ContainerBackend) HelperImages() []string {
+	return []string{hiveproxyTag, diskHelperTag}
 }
 
 // ServeAPI starts the API server.
@@ -30,6 +39,14 @@ func (cb *ContainerBackend) ServeAPI(ctx context.Context, h http.Handler) (libhi
 		return nil, err
 	}
 
+	// API requests carry the proxy in their context, so client containers started
+	// by a simulator are checked through the proxy of the same simulation.
+	var proxyRef atomic.Pointer[hiveproxy.Proxy]
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), proxyContextKey{}, proxyRef.Load())
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+
 	// Launch the proxy server before starting the container.
 	var (
 		proxy     *hiveproxy.Proxy
@@ -37,7 +54,8 @@ func (cb *ContainerBackend) ServeAPI(ctx context.Context, h http.Handler) (libhi
 	)
 	go func() {
 		var err error
-		proxy, err = hiveproxy.RunBackend(outR, inW, h)
+		proxy, err = hiveproxy.RunBackend(outR, inW, handler)
+		proxyRef.Store(proxy)
 		if err != nil {
 			slog.Error("proxy backend startup failed", "err", err)
 		}
@@ -60,9 +78,6 @@ func (cb *ContainerBackend) ServeAPI(ctx context.Context, h http.Handler) (libhi
 		}
 	}
 
-	// Register proxy in ContainerBackend, so it can be used for CheckLive.
-	cb.proxy = proxy
-
 	srv := &proxyContainer{
 		cb:              cb,
 		containerID:     id,
@@ -74,11 +89,19 @@ func (cb *ContainerBackend) ServeAPI(ctx context.Context, h http.Handler) (libhi
 	}
 
 	// Register proxy in ContainerBackend, so it can be used for CheckLive.
-	cb.proxy = proxy
+	cb.setProxy(nil, proxy)
 	slog.Info("hiveproxy started", "container", id[:12], "addr", srv.Addr())
 	return srv, nil
 }
 
+type proxyContextKey struct{}
+
+// proxyFromContext returns the proxy of the API server that received a request.
+func proxyFromContext(ctx context.Context) *hiveproxy.Proxy {
+	p, _ := ctx.Value(proxyContextKey{}).(*hiveproxy.Proxy)
+	return p
+}
+
 type proxyContainer struct {
 	cb *ContainerBackend
 
@@ -102,7 +125,7 @@ func (c *proxyContainer) Addr() net.Addr {
 func (c *proxyContainer) Close() error {
 	c.stopping.Do(func() {
 		// Unregister proxy in backend.
-		c.cb.proxy = nil
+		c.cb.setProxy(c.proxy, nil)
 
 		// Stop the container.
 		c.containerStdin.Close()
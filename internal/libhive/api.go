@@ -1,6 +1,7 @@
 package libhive
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -9,12 +10,14 @@ import (
 	"log/slog"
 	"mime/multipart"
 	"net/http"
+	"os"
 	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ethereum/hive/internal/openapi"
 	"github.com/ethereum/hive/internal/simapi"
 	"github.com/gorilla/mux"
 )
@@ -40,16 +43,31 @@ func newSimulationAPI(b ContainerBackend, env SimEnv, tm *TestManager, hive Hive
 	router.HandleFunc("/testsuite/{suite}/test/{test}/node/{node}", api.stopClient).Methods("DELETE")
 	router.HandleFunc("/testsuite/{suite}/test/{test}/node/{node}/pause", api.pauseClient).Methods("POST")
 	router.HandleFunc("/testsuite/{suite}/test/{test}/node/{node}/pause", api.unpauseClient).Methods("DELETE")
+	router.HandleFunc("/testsuite/{suite}/test/{test}/node/{node}/disk/readonly", api.setClientDiskReadOnly).Methods("POST")
+	router.HandleFunc("/testsuite/{suite}/test/{test}/node/{node}/disk/readonly", api.setClientDiskWritable).Methods("DELETE")
 	router.HandleFunc("/testsuite/{suite}/test", api.startTest).Methods("POST")
 	// post because the delete http verb does not always support a message body
 	router.HandleFunc("/testsuite/{suite}/test/{test}", api.endTest).Methods("POST")
+	router.HandleFunc("/testsuite/{suite}/test/{test}/retry", api.retryTest).Methods("POST")
 	router.HandleFunc("/testsuite", api.startSuite).Methods("POST")
+	router.HandleFunc("/testlist", api.setTestList).Methods("POST")
 	router.HandleFunc("/testsuite/{suite}", api.endSuite).Methods("DELETE")
 	router.HandleFunc("/testsuite/{suite}/network/{network}", api.networkCreate).Methods("POST")
 	router.HandleFunc("/testsuite/{suite}/network/{network}", api.networkRemove).Methods("DELETE")
 	router.HandleFunc("/testsuite/{suite}/network/{network}/{node}", api.networkIPGet).Methods("GET")
 	router.HandleFunc("/testsuite/{suite}/network/{network}/{node}", api.networkConnect).Methods("POST")
 	router.HandleFunc("/testsuite/{suite}/network/{network}/{node}", api.networkDisconnect).Methods("DELETE")
+	router.Handle("/metrics", MetricsHandler()).Methods("GET")
+	router.HandleFunc("/openapi.json", api.serveOpenAPISpec).Methods("GET")
+	router.NotFoundHandler = http.HandlerFunc(serveNotFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(serveMethodNotAllowed)
+
+	api.spec = newOpenAPISpec(router)
+	router.Use(apiMetrics)
+	if tm.recorder != nil {
+		router.Use(tm.recorder.middleware)
+	}
+	router.Use(api.validateRequests)
 	return router
 }
 
@@ -58,6 +76,7 @@ type simAPI struct {
 	env     SimEnv
 	tm      *TestManager
 	hive    HiveInfo
+	spec    *openapi.Document
 }
 
 // getHiveInfo returns information about the hive server instance.
@@ -71,6 +90,19 @@ func (api *simAPI) getClientTypes(w http.ResponseWriter, r *http.Request) {
 	serveJSON(w, api.tm.clientDefs)
 }
 
+// setTestList receives the test list of a simulator in test listing mode.
+func (api *simAPI) setTestList(w http.ResponseWriter, r *http.Request) {
+	var list simapi.TestList
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		slog.Error("API: invalid JSON in test list", "err", err)
+		serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	slog.Info("API: test list received", "suites", len(list.Suites))
+	api.tm.SetTestList(&list)
+	serveOK(w)
+}
+
 // startSuite starts a suite.
 func (api *simAPI) startSuite(w http.ResponseWriter, r *http.Request) {
 	var suite simapi.TestRequest
@@ -83,7 +115,7 @@ func (api *simAPI) startSuite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	suiteID, err := api.tm.StartTestSuite(suite.Name, suite.Description)
+	suiteID, err := api.tm.StartTestSuite(suite.Name, suite.Description, suite.RandomSeed)
 	if err != nil {
 		slog.Error("API: StartTestSuite failed", "error", err)
 		serveError(w, err, http.StatusInternalServerError)
@@ -125,13 +157,16 @@ func (api *simAPI) startTest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	testID, err := api.tm.StartTest(suiteID, test.Name, test.Description)
+	testID, err := api.tm.StartTest(suiteID, test.Name, test.Description, TestID(test.Parent))
 	if err != nil {
-		err := fmt.Errorf("can't start test case: %s", err.Error())
-		serveError(w, err, http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrNoSuchParentTest) {
+			status = http.StatusBadRequest
+		}
+		serveError(w, fmt.Errorf("can't start test case: %w", err), status)
 		return
 	}
-	slog.Info("API: test started", "suite", suiteID, "test", testID, "name", test.Name)
+	slog.Info("API: test started", "suite", suiteID, "test", testID, "parent", test.Parent, "name", test.Name)
 	serveJSON(w, testID)
 }
 
@@ -164,6 +199,34 @@ func (api *simAPI) endTest(w http.ResponseWriter, r *http.Request) {
 	serveOK(w)
 }
 
+// retryTest ends a failed attempt of a test case. The test keeps running.
+func (api *simAPI) retryTest(w http.ResponseWriter, r *http.Request) {
+	suiteID, testID, err := api.requestSuiteAndTest(r)
+	if err != nil {
+		serveError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var result TestResult
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		slog.Error("API: invalid result data in retryTest", "suite", suiteID, "test", testID, "error", err)
+		err := fmt.Errorf("can't unmarshal result: %v", err)
+		serveError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = api.tm.RetryTest(suiteID, testID, &result)
+	if err != nil {
+		slog.Error("API: RetryTest failed", "suite", suiteID, "test", testID, "error", err)
+		err := fmt.Errorf("can't retry test case: %v", err)
+		serveError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("API: test attempt failed, retrying", "suite", suiteID, "test", testID)
+	serveOK(w)
+}
+
 // startClient starts a client container.
 func (api *simAPI) startClient(w http.ResponseWriter, r *http.Request) {
 	suiteID, testID, err := api.requestSuiteAndTest(r)
@@ -184,12 +247,18 @@ func (api *simAPI) startClient(w http.ResponseWriter, r *http.Request) {
 
 	if !r.Form.Has("config") {
 		slog.Error("API: missing 'config' parameter in node request", "error", err)
-		err := fmt.Errorf("missing 'config' parameter in node request")
+		err := &openapi.ValidationError{Field: "body.config", Message: "missing 'config' parameter in node request"}
+		serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	config := []byte(r.Form.Get("config"))
+	if err := api.spec.ValidateJSON(openapi.Ref("NodeConfig"), config, "body.config"); err != nil {
+		slog.Error("API: invalid 'config' parameter in node request", "error", err)
 		serveError(w, err, http.StatusBadRequest)
 		return
 	}
 	var clientConfig simapi.NodeConfig
-	if err := json.Unmarshal([]byte(r.Form.Get("config")), &clientConfig); err != nil {
+	if err := json.Unmarshal(config, &clientConfig); err != nil {
 		slog.Error("API: invalid 'config' parameter in node request", "error", err)
 		err := fmt.Errorf("invalid 'config' parameter in node request")
 		serveError(w, err, http.StatusBadRequest)
@@ -237,6 +306,41 @@ func (api *simAPI) startClient(w http.ResponseWriter, r *http.Request) {
 	if env["HIVE_LOGLEVEL"] == "" {
 		env["HIVE_LOGLEVEL"] = strconv.Itoa(api.env.SimLogLevel)
 	}
+	// Apply overrides from the client file.
+	for k, v := range clientDef.Environment {
+		if strings.HasPrefix(k, hiveEnvvarPrefix) {
+			env[k] = v
+		}
+	}
+	if len(clientDef.Files) > 0 {
+		form, err := readHostFiles(clientDef.Files, maxMemory)
+		if err != nil {
+			slog.Error("API: can't read client files", "client", clientDef.Name, "error", err)
+			serveError(w, err, http.StatusInternalServerError)
+			return
+		}
+		defer form.RemoveAll()
+		for dest, fheaders := range form.File {
+			files[dest] = fheaders[0]
+		}
+	}
+
+	// Check the disk options.
+	if clientConfig.DiskQuota < 0 {
+		err := errors.New("negative disk quota in node request")
+		serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if clientConfig.DiskQuota > 0 && clientConfig.DiskPath == "" {
+		err := errors.New("disk quota requires disk path in node request")
+		serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if clientConfig.DiskPath != "" && !path.IsAbs(clientConfig.DiskPath) {
+		err := fmt.Errorf("disk path %q is not absolute", clientConfig.DiskPath)
+		serveError(w, err, http.StatusBadRequest)
+		return
+	}
 
 	// Set up the timeout.
 	timeout := api.env.ClientStartTimeout
@@ -247,11 +351,18 @@ func (api *simAPI) startClient(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Create the client container.
-	options := ContainerOptions{Env: env, Files: files}
+	options := ContainerOptions{
+		Env:       env,
+		Files:     files,
+		Labels:    api.tm.resourceLabels(suiteID, testID),
+		DiskPath:  clientConfig.DiskPath,
+		DiskQuota: clientConfig.DiskQuota,
+	}
 	containerID, err := api.backend.CreateContainer(ctx, clientDef.Image, options)
 	if err != nil {
+		metricStartFailures.WithLabelValues(clientDef.Name).Inc()
 		slog.Error("API: client container create failed", "client", clientDef.Name, "error", err)
-		err := fmt.Errorf("client container create failed (%v)", err)
+		err := withCode(simapi.ErrClientStart, fmt.Errorf("client container create failed (%v)", err))
 		serveError(w, err, http.StatusInternalServerError)
 		return
 	}
@@ -292,6 +403,7 @@ func (api *simAPI) startClient(w http.ResponseWriter, r *http.Request) {
 			InstantiatedAt: time.Now(),
 			LogFile:        logPath,
 			wait:           info.Wait,
+			diskPath:       clientConfig.DiskPath,
 		}
 
 		// Add client version to the test suite.
@@ -306,17 +418,42 @@ func (api *simAPI) startClient(w http.ResponseWriter, r *http.Request) {
 		api.tm.RegisterNode(testID, info.ID, clientInfo)
 	}
 	if err != nil {
+		metricStartFailures.WithLabelValues(clientDef.Name).Inc()
 		slog.Error("API: could not start client", "client", clientDef.Name, "container", containerID[:8], "error", err)
-		err := fmt.Errorf("client did not start: %v", err)
+		err := withCode(simapi.ErrClientStart, fmt.Errorf("client did not start: %v", err))
 		serveError(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	// It's started.
+	trackClient(clientDef.Name, info)
 	slog.Info("API: client "+clientDef.Name+" started", "suite", suiteID, "test", testID, "container", containerID[:8])
 	serveJSON(w, &simapi.StartNodeResponse{ID: info.ID, IP: info.IP})
 }
 
+// readHostFiles loads files from the host filesystem as a multipart form, so they can be
+// uploaded to a container like the files sent by the simulator. The keys of the files
+// map are the destination paths in the container.
+func readHostFiles(files map[string]string, maxMemory int64) (*multipart.Form, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for dest, src := range files {
+		content, err := os.ReadFile(src)
+		if err != nil {
+			return nil, err
+		}
+		fw, err := mw.CreateFormFile(dest, filepath.Base(src))
+		if err != nil {
+			return nil, err
+		}
+		fw.Write(content)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return multipart.NewReader(&buf, mw.Boundary()).ReadForm(maxMemory)
+}
+
 // clientLogFilePaths determines the log file path of a client container.
 // Note that jsonPath gets written to the result JSON and always uses '/' as the separator.
 // The filePath is passed to the docker backend and uses the platform separator.
@@ -337,14 +474,14 @@ func (api *simAPI) checkClient(req *simapi.NodeConfig) (*ClientDefinition, error
 			return client, nil
 		}
 	}
-	return nil, errors.New("unknown client type in start request")
+	return nil, withCode(simapi.ErrUnknownClient, errors.New("unknown client type in start request"))
 }
 
 // checkClientNetworks pre-checks the existence of initial networks for a client container.
 func (api *simAPI) checkClientNetworks(req *simapi.NodeConfig, suiteID TestSuiteID) ([]string, error) {
 	for _, network := range req.Networks {
 		if !api.tm.NetworkExists(suiteID, network) {
-			return nil, fmt.Errorf("invalid network name '%s' in client start request", network)
+			return nil, withCode(simapi.ErrUnknownNetwork, fmt.Errorf("invalid network name '%s' in client start request", network))
 		}
 	}
 	return req.Networks, nil
@@ -410,6 +547,39 @@ func (api *simAPI) unpauseClient(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// setClientDiskReadOnly makes the fault injection disk of a client read-only.
+func (api *simAPI) setClientDiskReadOnly(w http.ResponseWriter, r *http.Request) {
+	api.setClientDiskMode(w, r, true)
+}
+
+// setClientDiskWritable makes the fault injection disk of a client writable again.
+func (api *simAPI) setClientDiskWritable(w http.ResponseWriter, r *http.Request) {
+	api.setClientDiskMode(w, r, false)
+}
+
+func (api *simAPI) setClientDiskMode(w http.ResponseWriter, r *http.Request, readOnly bool) {
+	_, testID, err := api.requestSuiteAndTest(r)
+	if err != nil {
+		serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	node := mux.Vars(r)["node"]
+
+	err = api.tm.SetNodeDiskReadOnly(r.Context(), testID, node, readOnly)
+	switch {
+	case err == ErrNoSuchNode:
+		serveError(w, err, http.StatusNotFound)
+	case err == ErrNoDiskPath:
+		serveError(w, err, http.StatusBadRequest)
+	case err != nil:
+		slog.Error("API: client disk remount failed", "node", node, "readonly", readOnly, "error", err)
+		serveError(w, err, http.StatusInternalServerError)
+	default:
+		slog.Info("API: client disk remounted", "node", node, "readonly", readOnly)
+		serveOK(w)
+	}
+}
+
 // getNodeStatus returns the status of a client container.
 func (api *simAPI) getNodeStatus(w http.ResponseWriter, r *http.Request) {
 	suiteID, testID, err := api.requestSuiteAndTest(r)
@@ -584,7 +754,7 @@ func (api *simAPI) requestSuite(r *http.Request) (TestSuiteID, error) {
 	}
 	testSuiteID := TestSuiteID(testSuite)
 	if _, running := api.tm.IsTestSuiteRunning(testSuiteID); !running {
-		return 0, fmt.Errorf("test suite %d not running", testSuite)
+		return 0, withCode(simapi.ErrUnknownSuite, fmt.Errorf("test suite %d not running", testSuite))
 	}
 	return testSuiteID, nil
 }
@@ -600,7 +770,7 @@ func (api *simAPI) requestTest(r *http.Request) (TestID, error) {
 	}
 	testCaseID := TestID(testCase)
 	if _, running := api.tm.IsTestRunning(testCaseID); !running {
-		return 0, fmt.Errorf("test case %d is not running", testCaseID)
+		return 0, withCode(simapi.ErrUnknownTest, fmt.Errorf("test case %d is not running", testCaseID))
 	}
 	return testCaseID, nil
 }
@@ -634,8 +804,65 @@ func serveOK(w http.ResponseWriter) {
 }
 
 func serveError(w http.ResponseWriter, err error, status int) {
-	resp, _ := json.Marshal(&simapi.Error{Error: err.Error()})
+	e := &simapi.Error{Error: err.Error(), Code: errorCode(err, status)}
+	var verr *openapi.ValidationError
+	if errors.As(err, &verr) {
+		e.Field = verr.Field
+	}
+	resp, _ := json.Marshal(e)
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(status)
 	w.Write(resp)
 }
+
+func serveNotFound(w http.ResponseWriter, r *http.Request) {
+	serveError(w, fmt.Errorf("no API endpoint %s", r.URL.Path), http.StatusNotFound)
+}
+
+func serveMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	serveError(w, fmt.Errorf("method %s not allowed for %s", r.Method, r.URL.Path), http.StatusMethodNotAllowed)
+}
+
+// codedError is an error with an API error code.
+type codedError struct {
+	code simapi.ErrorCode
+	err  error
+}
+
+func withCode(code simapi.ErrorCode, err error) error {
+	return &codedError{code, err}
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// errorCode returns the API error code of err. Errors without a code are
+// classified by the response status.
+func errorCode(err error, status int) simapi.ErrorCode {
+	var (
+		coded *codedError
+		verr  *openapi.ValidationError
+	)
+	switch {
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.As(err, &verr):
+		return simapi.ErrValidation
+	case errors.Is(err, ErrNoSuchNode):
+		return simapi.ErrUnknownNode
+	case errors.Is(err, ErrNoSuchTestSuite):
+		return simapi.ErrUnknownSuite
+	case errors.Is(err, ErrNoSuchTestCase):
+		return simapi.ErrUnknownTest
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return simapi.ErrNotFound
+	case status == http.StatusMethodNotAllowed:
+		return simapi.ErrMethodNotAllowed
+	case status >= 500:
+		return simapi.ErrInternal
+	default:
+		return simapi.ErrBadRequest
+	}
+}
@@ -0,0 +1,121 @@
+package libhive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Cleanup removes containers and networks left behind by hive processes which are no
+// longer running. When olderThan is non-zero, only resources created before that
+// duration are removed. It returns the number of removed resources.
+//
+// Whether the creating process is running can only be checked for resources created
+// on the same host, i.e. with the same hostname. PIDs of other hosts and containers
+// are meaningless here, so their resources are removed only when olderThan is set and
+// they are old enough.
+func Cleanup(ctx context.Context, cb ContainerBackend, olderThan time.Duration) (int, error) {
+	resources, err := cb.ListResources(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var orphans []Resource
+	now := time.Now()
+	for _, r := range resources {
+		if isOrphan(r, now, olderThan) {
+			orphans = append(orphans, r)
+		}
+	}
+	// Containers must go first because networks with attached containers
+	// can't be removed.
+	var (
+		removed int
+		errs    []error
+	)
+	for _, kind := range []string{"container", "network"} {
+		for _, r := range orphans {
+			if r.Kind != kind {
+				continue
+			}
+			slog.Info("removing orphaned "+r.Kind, "id", r.ID[:min(12, len(r.ID))], "name", r.Name, "run", r.Labels[LabelRunID])
+			if err := cb.RemoveResource(ctx, r); err != nil {
+				slog.Error("can't remove "+r.Kind, "id", r.ID, "err", err)
+				errs = append(errs, err)
+				continue
+			}
+			removed++
+		}
+	}
+	return removed, errors.Join(errs...)
+}
+
+// isOrphan reports whether r was created by a hive process that has exited.
+func isOrphan(r Resource, now time.Time, olderThan time.Duration) bool {
+	if r.Labels[LabelRunID] == runID {
+		return false
+	}
+	if r.Labels[LabelHost] == hostname {
+		if processRunning(r.Labels[LabelPID], r.Labels[LabelStart]) {
+			return false
+		}
+	} else if olderThan == 0 {
+		// The process may be running elsewhere.
+		return false
+	}
+	if olderThan > 0 {
+		created, err := time.Parse(time.RFC3339, r.Labels[LabelCreated])
+		if err != nil || now.Sub(created) < olderThan {
+			return false
+		}
+	}
+	return true
+}
+
+// processRunning reports whether the process with the given PID and start time exists
+// on this host. The start time detects reuse of the PID by another process. It can only
+// be checked on Linux, elsewhere any process with the PID is assumed to be hive.
+func processRunning(pidLabel, startLabel string) bool {
+	pid, err := strconv.Atoi(pidLabel)
+	if err != nil || pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if err := p.Signal(syscall.Signal(0)); err != nil && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+	if start, ok := processStartTime(pid); ok && startLabel != "" {
+		return start == startLabel
+	}
+	return true
+}
+
+// processStartTime returns the start time of a process in clock ticks after boot, from
+// /proc/<pid>/stat. It returns false if the start time isn't available.
+func processStartTime(pid int) (string, bool) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", false
+	}
+	// The process name in field 2 can contain spaces, so fields are counted from
+	// its closing parenthesis. The start time is field 22.
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return "", false
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 20 {
+		return "", false
+	}
+	return fields[19], true
+}
@@ -27,33 +27,87 @@ type TestSuite struct {
 	Description    string               `json:"description"`
 	ClientVersions map[string]string    `json:"clientVersions"`
 	TestCases      map[TestID]*TestCase `json:"testCases"`
+	RandomSeed     int64                `json:"randomSeed,omitempty"` // seed reported by the simulator
 
-	SimulatorLog   string `json:"simLog"`         // path to simulator log-file simulator. (may be shared with multiple suites)
-	TestDetailsLog string `json:"testDetailsLog"` // the test details output file
+	Simulator      string `json:"simulator,omitempty"`    // name of the simulator which ran the suite
+	SimulatorLog   string `json:"simLog"`                 // path to simulator log-file simulator. (may be shared with multiple suites)
+	TestDetailsLog string `json:"testDetailsLog"`         // the test details output file
+	APIRecording   string `json:"apiRecording,omitempty"` // simulation API recording, if enabled
 
 	testDetailsFile *os.File
 	testLogOffset   int64
+	resultFile      string // name of the suite file, set when the suite ends
 }
 
 // TestCase represents a single test case in a test suite.
 type TestCase struct {
-	Name          string                 `json:"name"`        // Test case short name.
-	Description   string                 `json:"description"` // Test case long description in MD.
+	Name          string                 `json:"name"`             // Test case short name.
+	Description   string                 `json:"description"`      // Test case long description in MD.
+	Parent        TestID                 `json:"parent,omitempty"` // ID of the parent test, for subtests.
 	Start         time.Time              `json:"start"`
 	End           time.Time              `json:"end"`
-	SummaryResult TestResult             `json:"summaryResult"` // The result of the whole test case.
-	ClientInfo    map[string]*ClientInfo `json:"clientInfo"`    // Info about each client.
+	SummaryResult TestResult             `json:"summaryResult"`      // The result of the whole test case.
+	ClientInfo    map[string]*ClientInfo `json:"clientInfo"`         // Info about each client.
+	Metrics       []TestMetric           `json:"metrics,omitempty"`  // Measurements reported by the test.
+	Attempts      []TestAttempt          `json:"attempts,omitempty"` // Failed attempts of a retried test.
+	Events        []ClientEvent          `json:"events,omitempty"`   // Client state changes.
+
+	// SimulatorLog is set in merged suites when the test ran in a different simulator
+	// than the one whose log is the SimulatorLog of the suite.
+	SimulatorLog string `json:"simLog,omitempty"`
 }
 
+// TestAttempt is a failed run of a test case which was retried.
+type TestAttempt struct {
+	Start         time.Time              `json:"start"`
+	End           time.Time              `json:"end"`
+	SummaryResult TestResult             `json:"summaryResult"`
+	ClientInfo    map[string]*ClientInfo `json:"clientInfo"`
+	Events        []ClientEvent          `json:"events,omitempty"`
+	Subtests      map[TestID]*TestCase   `json:"subtests,omitempty"` // Subtests started by the attempt.
+}
+
+// ClientEvent is a change of the state of a client during a test.
+type ClientEvent struct {
+	Time    time.Time `json:"time"`
+	Client  string    `json:"client"`            // client container ID, key of TestCase.ClientInfo
+	Type    string    `json:"type"`              // see ClientEvent* constants
+	Network string    `json:"network,omitempty"` // network name for connect/disconnect
+}
+
+// Client event types.
+const (
+	ClientEventStop         = "stop"
+	ClientEventPause        = "pause"
+	ClientEventUnpause      = "unpause"
+	ClientEventConnect      = "connect"
+	ClientEventDisconnect   = "disconnect"
+	ClientEventDiskReadOnly = "disk-readonly"
+	ClientEventDiskWritable = "disk-writable"
+)
+
 // TestResult represents the result of a test case.
 type TestResult struct {
-	Pass    bool `json:"pass"`
+	Pass    bool `json:"pass" openapi:"required"`
 	Timeout bool `json:"timeout,omitempty"`
 
+	// Flaky is set when the test passed after failing in earlier attempts.
+	Flaky bool `json:"flaky,omitempty"`
+
 	// The test log can be stored inline ("details"), or as offsets into the
 	// suite's TestDetailsLog file ("log").
 	Details    string          `json:"details,omitempty"`
 	LogOffsets *TestLogOffsets `json:"log,omitempty"`
+
+	// Metrics reported by the simulator are moved to the TestCase when the test ends.
+	Metrics []TestMetric `json:"metrics,omitempty"`
+}
+
+// TestMetric is a named measurement reported by a test, e.g. the duration of a sync.
+type TestMetric struct {
+	Name  string  `json:"name" openapi:"required"`
+	Value float64 `json:"value" openapi:"required"`
+	Unit  string  `json:"unit,omitempty"`
 }
 
 type TestLogOffsets struct {
@@ -69,7 +123,8 @@ type ClientInfo struct {
 	InstantiatedAt time.Time `json:"instantiatedAt"`
 	LogFile        string    `json:"logFile"` //Absolute path to the logfile.
 
-	wait func()
+	wait     func()
+	diskPath string // fault injection mount point, if any
 }
 
 // HiveInstance contains information about hive itself.
@@ -81,10 +136,16 @@ type HiveInstance struct {
 
 // ClientDefinition is served by the /clients API endpoint to list the available clients
 type ClientDefinition struct {
-	Name    string         `json:"name"`
+	Name    string         `json:"name" openapi:"required"`
 	Version string         `json:"version"`
 	Image   string         `json:"-"` // not exposed via API
 	Meta    ClientMetadata `json:"meta"`
+
+	// Container overrides from the client file, not exposed via API.
+	Environment map[string]string `json:"-"`
+	Files       map[string]string `json:"-"`
+
+	designator ClientDesignator // the client list entry of the client
 }
 
 // ExecInfo is the result of running a script in a client container.
@@ -93,3 +154,25 @@ type ExecInfo struct {
 	Stderr   string `json:"stderr"`
 	ExitCode int    `json:"exitCode"`
 }
+
+// BuildSummary records the image builds of a hive run. It is stored as
+// summary.json in the build log directory.
+type BuildSummary struct {
+	Images []ImageBuild `json:"images"`
+}
+
+// ImageBuild is the outcome of building a client or simulator image.
+type ImageBuild struct {
+	Name    string    `json:"name"` // client or simulator name
+	Kind    string    `json:"kind"` // "client" or "simulator"
+	Image   string    `json:"image"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Error   string    `json:"error,omitempty"`   // set when the build failed
+	LogFile string    `json:"logFile,omitempty"` // build log, relative to the results directory
+}
+
+// Failed reports whether the image failed to build.
+func (b *ImageBuild) Failed() bool {
+	return b.Error != ""
+}
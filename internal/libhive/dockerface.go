@@ -8,6 +8,10 @@ import (
 	"mime/multipart"
 	"net"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // ContainerBackend captures the docker interactions of the simulation API.
@@ -16,6 +20,9 @@ type ContainerBackend interface {
 	// This is called before anything else in the simulation run.
 	Build(context.Context, Builder) error
 
+	// HelperImages returns the names of the images built by Build.
+	HelperImages() []string
+
 	// This is for launching the simulation API server.
 	ServeAPI(context.Context, http.Handler) (APIServer, error)
 
@@ -26,16 +33,33 @@ type ContainerBackend interface {
 	PauseContainer(containerID string) error
 	UnpauseContainer(containerID string) error
 
+	// SetDiskReadOnly makes the DiskPath of a running container read-only or
+	// writable. Files which are already open for writing remain writable.
+	SetDiskReadOnly(ctx context.Context, containerID, path string, readOnly bool) error
+
 	// RunProgram runs a command in the given container and returns its outputs and exit code.
 	RunProgram(ctx context.Context, containerID string, cmdline []string) (*ExecInfo, error)
 
 	// These methods configure docker networks.
 	NetworkNameToID(name string) (string, error)
-	CreateNetwork(name string) (string, error)
+	CreateNetwork(name string, labels map[string]string) (string, error)
 	RemoveNetwork(id string) error
 	ContainerIP(containerID, networkID string) (net.IP, error)
 	ConnectContainer(containerID, networkID string) error
 	DisconnectContainer(containerID, networkID string) error
+
+	// ListResources returns all containers and networks labelled by hive.
+	ListResources(ctx context.Context) ([]Resource, error)
+	// RemoveResource deletes a container or network returned by ListResources.
+	RemoveResource(ctx context.Context, r Resource) error
+}
+
+// Resource is a docker container or network created by hive.
+type Resource struct {
+	Kind   string // "container" or "network"
+	ID     string
+	Name   string
+	Labels map[string]string
 }
 
 // APIServer is a handle for the HTTP API server.
@@ -52,6 +76,9 @@ type ContainerOptions struct {
 	Env   map[string]string
 	Files map[string]*multipart.FileHeader
 
+	// Labels are added to the labels identifying the hive process, see ResourceLabels.
+	Labels map[string]string
+
 	// This requests checking for the given TCP port to be opened by the container.
 	CheckLive uint16
 
@@ -63,6 +90,11 @@ type ContainerOptions struct {
 
 	// Input: if set, container stdin draws from the given reader.
 	Input io.ReadCloser
+
+	// Disk fault injection: if DiskPath is set, the directory is mounted on a tmpfs
+	// of at most DiskQuota bytes. A zero quota uses the docker default size.
+	DiskPath  string
+	DiskQuota int64
 }
 
 // ContainerInfo is returned by StartContainer.
@@ -72,6 +104,9 @@ type ContainerInfo struct {
 	MAC     string // MAC address. TODO: remove
 	LogFile string
 
+	// StartupTime is the time it took until the container was online.
+	StartupTime time.Duration
+
 	// The wait function returns when the container is stopped.
 	// This must be called for all containers that were started
 	// to avoid resource leaks.
@@ -86,6 +121,26 @@ type Builder interface {
 
 	// ReadFile returns the content of a file in the given image.
 	ReadFile(ctx context.Context, image, path string) ([]byte, error)
+
+	// SaveImages writes the given images to w as a tar archive.
+	SaveImages(ctx context.Context, images []string, w io.Writer) error
+	// LoadImages loads images from an archive written by SaveImages.
+	LoadImages(ctx context.Context, r io.Reader) error
+}
+
+// BuildLogFile returns the file name of the build log of an image. Build logs of a hive
+// run are stored in a common directory, see NewBuildLogDir.
+func BuildLogFile(image string) string {
+	name := strings.TrimSuffix(image, ":latest")
+	name = strings.TrimPrefix(name, "hive/")
+	name = strings.NewReplacer("/", "-", ":", "-").Replace(name)
+	return name + ".log"
+}
+
+// NewBuildLogDir returns the directory for build logs of a new hive run, relative to the
+// results directory.
+func NewBuildLogDir() string {
+	return path.Join("builds", strconv.FormatInt(time.Now().Unix(), 10))
 }
 
 // ClientMetadata is metadata to describe the client in more detail, configured with a YAML file in the client dir.
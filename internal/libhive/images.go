@@ -0,0 +1,170 @@
+package libhive
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// The image export archive is the tar archive written by Builder.SaveImages, preceded
+// by an entry containing the manifest. The manifest is written first, so the archive
+// can be imported in a single pass.
+const exportManifestFile = "hive-images.json"
+
+// ImageManifest describes the images contained in an export archive.
+type ImageManifest struct {
+	Clients    []ExportedClient  `json:"clients"`
+	Simulators map[string]string `json:"simulators"` // simulator name -> image
+	Helpers    []string          `json:"helpers"`    // images of the container backend
+}
+
+// ExportedClient is a client image in an export archive.
+type ExportedClient struct {
+	Client  ClientDesignator `json:"client"`
+	Image   string           `json:"image"`
+	Version string           `json:"version"`
+}
+
+// ExportImages writes all built client, simulator and helper images to w. The archive
+// contains the image manifest followed by the entries written by Builder.SaveImages.
+func (r *Runner) ExportImages(ctx context.Context, w io.Writer) error {
+	manifest := ImageManifest{
+		Simulators: r.simImages,
+		Helpers:    r.container.HelperImages(),
+	}
+	var images []string
+	for _, def := range r.clientDefs {
+		manifest.Clients = append(manifest.Clients, ExportedClient{Client: def.designator, Image: def.Image, Version: def.Version})
+		images = append(images, def.Image)
+	}
+	for _, image := range r.simImages {
+		images = append(images, image)
+	}
+	images = append(images, manifest.Helpers...)
+
+	manifestJSON, err := json.MarshalIndent(&manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(w)
+	hdr := &tar.Header{Name: exportManifestFile, Mode: 0644, Size: int64(len(manifestJSON)), ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifestJSON); err != nil {
+		return err
+	}
+
+	// Stream the saved images into the archive.
+	slog.Info(fmt.Sprintf("exporting %d images...", len(images)))
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(r.builder.SaveImages(ctx, images, pw))
+	}()
+	err = copyTarEntries(tw, tar.NewReader(pr))
+	if err == nil {
+		// Wait for SaveImages to finish, it may write padding after the end of the archive.
+		_, err = io.Copy(io.Discard, pr)
+	}
+	pr.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("can't save images: %w", err)
+	}
+	return tw.Close()
+}
+
+// ImportImages loads images from an archive written by ExportImages. After importing,
+// Build selects the imported images instead of building them.
+func (r *Runner) ImportImages(ctx context.Context, rd io.Reader) error {
+	tr := tar.NewReader(rd)
+	hdr, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("can't read image archive: %w", err)
+	}
+	if hdr.Name != exportManifestFile {
+		return fmt.Errorf("invalid image archive: first entry is %q, want %s", hdr.Name, exportManifestFile)
+	}
+	var manifest ImageManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return fmt.Errorf("invalid image manifest: %w", err)
+	}
+
+	// The remaining entries are passed to LoadImages as an archive of their own.
+	slog.Info(fmt.Sprintf("importing %d client and %d simulator images...", len(manifest.Clients), len(manifest.Simulators)))
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := copyTarEntries(tw, tr)
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	err = r.builder.LoadImages(ctx, pr)
+	pr.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("can't load images: %w", err)
+	}
+	r.imported = &manifest
+	return nil
+}
+
+// copyTarEntries copies all entries of a tar archive to tw.
+func copyTarEntries(tw *tar.Writer, tr *tar.Reader) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// useImportedImages selects the imported images of the given clients and simulators.
+func (r *Runner) useImportedImages(clientList []ClientDesignator, simList []string) error {
+	var errs []error
+	r.clientDefs = make([]*ClientDefinition, 0, len(clientList))
+	for _, client := range clientList {
+		found := false
+		for _, c := range r.imported.Clients {
+			if c.Client.Name() == client.Name() {
+				r.clientDefs = append(r.clientDefs, &ClientDefinition{
+					Name:        client.Name(),
+					Version:     c.Version,
+					Image:       c.Image,
+					Meta:        r.inv.clientMeta(client.Client),
+					Environment: client.Environment,
+					Files:       client.Files,
+					designator:  client,
+				})
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("client %s is not in the imported images", client.Name()))
+		}
+	}
+	r.simImages = make(map[string]string)
+	for _, sim := range simList {
+		image, ok := r.imported.Simulators[sim]
+		if !ok {
+			errs = append(errs, fmt.Errorf("simulator %s is not in the imported images", sim))
+			continue
+		}
+		r.simImages[sim] = image
+	}
+	return errors.Join(errs...)
+}
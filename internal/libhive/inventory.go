@@ -5,6 +5,7 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -133,11 +134,7 @@ func findClients(dir string) (map[string]InventoryClient, error) {
 		file := info.Name()
 		switch {
 		case file == "Dockerfile":
-			clients[clientName] = InventoryClient{
-				Meta: ClientMetadata{
-					Roles: []string{"eth1"}, // default role
-				},
-			}
+			clients[clientName] = InventoryClient{Meta: defaultClientMeta()}
 		case strings.HasPrefix(file, "Dockerfile."):
 			client, ok := clients[clientName]
 			if !ok {
@@ -164,6 +161,21 @@ func findClients(dir string) (map[string]InventoryClient, error) {
 	return clients, err
 }
 
+func defaultClientMeta() ClientMetadata {
+	return ClientMetadata{
+		Roles: []string{"eth1"}, // default role
+	}
+}
+
+// clientMeta returns the metadata of a client. Prebuilt clients without a directory in
+// clients/ have the default metadata.
+func (inv Inventory) clientMeta(client string) ClientMetadata {
+	if ic, ok := inv.Clients[client]; ok {
+		return ic.Meta
+	}
+	return defaultClientMeta()
+}
+
 func loadClientMetadata(path string) (m ClientMetadata, err error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -196,6 +208,22 @@ type ClientDesignator struct {
 
 	// Arguments passed to the docker build.
 	BuildArgs map[string]string `yaml:"build_args,omitempty" json:"build_args,omitempty"`
+
+	// Image is a prebuilt client image. By default, the image is used as the base
+	// image of the client's Dockerfile in clients/, which adds the hive scripts.
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+
+	// Prebuilt marks Image as a complete hive client image, which is used as-is.
+	// This is required for clients without a directory in clients/.
+	Prebuilt bool `yaml:"prebuilt,omitempty" json:"prebuilt,omitempty"`
+
+	// Environment is added to the environment of every container of the client,
+	// overriding the values sent by the simulator. Variable names must start with HIVE_.
+	Environment map[string]string `yaml:"environment,omitempty" json:"environment,omitempty"`
+
+	// Files are copied into every container of the client. Keys are absolute paths
+	// in the container, values are paths of files on the host.
+	Files map[string]string `yaml:"files,omitempty" json:"files,omitempty"`
 }
 
 func (c ClientDesignator) buildString() string {
@@ -225,6 +253,34 @@ func (c ClientDesignator) Dockerfile() string {
 	return "Dockerfile." + c.DockerfileExt
 }
 
+// IsPrebuilt reports whether the client uses a complete prebuilt image, i.e. the image
+// is not layered with a client directory of the inventory.
+func (c ClientDesignator) IsPrebuilt() bool {
+	return c.Prebuilt
+}
+
+// SplitImageRef splits a docker image reference into repository and tag. The tag
+// defaults to "latest". References containing a digest are returned unchanged, with
+// an empty tag.
+func SplitImageRef(ref string) (repo, tag string) {
+	if strings.Contains(ref, "@") {
+		return ref, ""
+	}
+	if i := strings.LastIndexByte(ref, ':'); i > strings.LastIndexByte(ref, '/') {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, "latest"
+}
+
+// versionTag returns the tag of the client image or source.
+func (c ClientDesignator) versionTag() string {
+	if c.Image != "" {
+		_, tag := SplitImageRef(c.Image)
+		return tag
+	}
+	return c.BuildArgs["tag"]
+}
+
 // Name returns the full client name including nametag.
 func (c ClientDesignator) Name() string {
 	if c.Nametag == "" {
@@ -319,11 +375,36 @@ func validateClients(inv *Inventory, list []ClientDesignator) error {
 	for _, c := range list {
 		occurrences[c.Client]++
 
+		// Prebuilt images are used as-is, so build options don't apply.
+		if c.IsPrebuilt() {
+			if c.Image == "" {
+				return fmt.Errorf("client %s: prebuilt requires an image", c.Client)
+			}
+			if c.DockerfileExt != "" || len(c.BuildArgs) > 0 {
+				return fmt.Errorf("client %s: dockerfile and build_args can't be used with a prebuilt image", c.Client)
+			}
+			clientTags[c.Client] = clientTags[c.Client].add(c.versionTag())
+			continue
+		}
+
 		// Validate client exists.
 		ic, ok := inv.Clients[c.Client]
 		if !ok {
 			return fmt.Errorf("unknown client %q", c.Client)
 		}
+		if c.Image != "" {
+			if strings.Contains(c.Image, "@") {
+				return fmt.Errorf("client %s: image digests are not supported for layered images", c.Client)
+			}
+			if c.BuildArgs["baseimage"] != "" || c.BuildArgs["tag"] != "" {
+				return fmt.Errorf("client %s: image can't be combined with baseimage or tag build arguments", c.Client)
+			}
+			// The image is passed to the default Dockerfile as its base image. Other
+			// Dockerfiles build the client from source and would ignore it.
+			if c.DockerfileExt != "" {
+				return fmt.Errorf("client %s: image can't be combined with dockerfile", c.Client)
+			}
+		}
 		// Validate DockerfileExt.
 		if c.DockerfileExt != "" {
 			if !slices.Contains(ic.Dockerfiles, c.DockerfileExt) {
@@ -336,7 +417,38 @@ func validateClients(inv *Inventory, list []ClientDesignator) error {
 				slog.Warn(fmt.Sprintf("unknown build arg %q in clients.yaml file", key))
 			}
 		}
-		clientTags[c.Client] = clientTags[c.Client].add(c.BuildArgs["tag"])
+		// Check container overrides.
+		for key := range c.Environment {
+			if !strings.HasPrefix(key, hiveEnvvarPrefix) {
+				return fmt.Errorf("client %s: environment variable %q does not start with %s", c.Client, key, hiveEnvvarPrefix)
+			}
+		}
+		for dest, src := range c.Files {
+			if !path.IsAbs(dest) {
+				return fmt.Errorf("client %s: file destination %q is not absolute", c.Client, dest)
+			}
+			if stat, err := os.Stat(src); err != nil {
+				return fmt.Errorf("client %s: %v", c.Client, err)
+			} else if stat.IsDir() {
+				return fmt.Errorf("client %s: file %s is a directory", c.Client, src)
+			}
+		}
+		clientTags[c.Client] = clientTags[c.Client].add(c.versionTag())
+	}
+
+	// Layer images with the client directory by setting the base image build arguments.
+	for i := range list {
+		c := &list[i]
+		if c.Image == "" || c.IsPrebuilt() {
+			continue
+		}
+		repo, tag := SplitImageRef(c.Image)
+		c.BuildArgs = maps.Clone(c.BuildArgs)
+		if c.BuildArgs == nil {
+			c.BuildArgs = make(map[string]string)
+		}
+		c.BuildArgs["baseimage"] = repo
+		c.BuildArgs["tag"] = tag
 	}
 
 	// Assign nametags.
@@ -349,7 +461,7 @@ func validateClients(inv *Inventory, list []ClientDesignator) error {
 		if c.Nametag == "" {
 			// Try assigning nametag based on "tag" argument.
 			if len(clientTags[c.Client]) == occurrences[c.Client] {
-				c.Nametag = c.BuildArgs["tag"]
+				c.Nametag = c.versionTag()
 			} else {
 				// Fall back to using all build arguments as nametag.
 				c.Nametag = c.buildString()
@@ -94,6 +94,20 @@ func TestClientNaming(t *testing.T) {
 			},
 			names: []string{"c1_git", "c1_local"},
 		},
+		{
+			clients: []ClientDesignator{
+				{Client: "c1", Image: "registry.io/c1:rc1"},
+				{Client: "c1", Image: "registry.io/c1:rc2"},
+			},
+			names: []string{"c1_rc1", "c1_rc2"},
+		},
+		{
+			clients: []ClientDesignator{
+				{Client: "prebuilt", Image: "registry.io/prebuilt:rc1", Prebuilt: true},
+				{Client: "prebuilt", Image: "registry.io/prebuilt:rc2", Prebuilt: true},
+			},
+			names: []string{"prebuilt_rc1", "prebuilt_rc2"},
+		},
 		// Errors:
 		{
 			clients: []ClientDesignator{
@@ -102,6 +116,18 @@ func TestClientNaming(t *testing.T) {
 			},
 			wantErr: fmt.Errorf("duplicate client name \"c1_latest\""),
 		},
+		{
+			clients: []ClientDesignator{{Client: "c1", Image: "registry.io/c1:rc1", BuildArgs: map[string]string{"tag": "x"}}},
+			wantErr: fmt.Errorf("client c1: image can't be combined with baseimage or tag build arguments"),
+		},
+		{
+			clients: []ClientDesignator{{Client: "c1", Image: "registry.io/c1:rc1", DockerfileExt: "git"}},
+			wantErr: fmt.Errorf("client c1: image can't be combined with dockerfile"),
+		},
+		{
+			clients: []ClientDesignator{{Client: "prebuilt", Image: "registry.io/prebuilt", DockerfileExt: "git", Prebuilt: true}},
+			wantErr: fmt.Errorf("client prebuilt: dockerfile and build_args can't be used with a prebuilt image"),
+		},
 	}
 
 	for i := range tests {
@@ -172,6 +198,43 @@ func TestParseClientListYAML(t *testing.T) {
 	}
 }
 
+// This test checks that images of known clients are layered by setting the base image
+// build arguments.
+func TestClientImageLayering(t *testing.T) {
+	var inv Inventory
+	inv.AddClient("c1", nil)
+	list := []ClientDesignator{
+		{Client: "c1", Image: "localhost:5000/org/c1:rc1"},
+		{Client: "prebuilt", Image: "org/prebuilt", Prebuilt: true},
+	}
+	if err := validateClients(&inv, list); err != nil {
+		t.Fatal(err)
+	}
+	wantArgs := map[string]string{"baseimage": "localhost:5000/org/c1", "tag": "rc1"}
+	if !reflect.DeepEqual(list[0].BuildArgs, wantArgs) {
+		t.Errorf("wrong build args of layered image: %v", list[0].BuildArgs)
+	}
+	if list[0].IsPrebuilt() || !list[1].IsPrebuilt() {
+		t.Error("wrong IsPrebuilt result")
+	}
+	if list[1].BuildArgs != nil {
+		t.Errorf("prebuilt image has build args: %v", list[1].BuildArgs)
+	}
+	if meta := inv.clientMeta("prebuilt"); !reflect.DeepEqual(meta.Roles, []string{"eth1"}) {
+		t.Errorf("wrong roles of prebuilt client: %v", meta.Roles)
+	}
+
+	// Unknown clients are rejected unless the image is marked as prebuilt.
+	typo := []ClientDesignator{{Client: "c2", Image: "org/c2"}}
+	if err := validateClients(&inv, typo); err == nil || err.Error() != `unknown client "c2"` {
+		t.Errorf("wrong error for unknown client with image: %v", err)
+	}
+	noImage := []ClientDesignator{{Client: "prebuilt", Prebuilt: true}}
+	if err := validateClients(&inv, noImage); err == nil {
+		t.Error("no error for prebuilt client without image")
+	}
+}
+
 // This test ensures the real hive client definitions can be loaded.
 func TestLoadInventory(t *testing.T) {
 	basedir := filepath.FromSlash("../..")
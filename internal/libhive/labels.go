@@ -0,0 +1,61 @@
+package libhive
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Docker labels of the containers and networks created by hive. They identify the hive
+// process which created a resource, so resources leaked by a killed process can be found
+// and removed by Cleanup.
+const (
+	LabelRunID   = "hive.run"
+	LabelPID     = "hive.pid"
+	LabelHost    = "hive.host"  // hostname of the hive process
+	LabelStart   = "hive.start" // start time of the hive process, see processStartTime
+	LabelCreated = "hive.created"
+	LabelSim     = "hive.simulator"
+	LabelSuite   = "hive.suite"
+	LabelTest    = "hive.test"
+)
+
+// runID identifies the running hive process.
+var runID = newRunID()
+
+// hostname and processStart identify the hive process together with its PID.
+var (
+	hostname, _     = os.Hostname()
+	processStart, _ = processStartTime(os.Getpid())
+)
+
+func newRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RunID returns the ID of the running hive process.
+func RunID() string {
+	return runID
+}
+
+// ResourceLabels returns the labels of a new container or network. The given labels
+// are added to the labels identifying the hive process.
+func ResourceLabels(labels map[string]string) map[string]string {
+	result := map[string]string{
+		LabelRunID:   runID,
+		LabelPID:     strconv.Itoa(os.Getpid()),
+		LabelHost:    hostname,
+		LabelCreated: time.Now().UTC().Format(time.RFC3339),
+	}
+	if processStart != "" {
+		result[LabelStart] = processStart
+	}
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
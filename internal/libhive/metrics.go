@@ -0,0 +1,85 @@
+package libhive
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics of the simulation API. They are served at /metrics of the API and
+// at the --metrics.addr endpoint.
+var (
+	metricTestsRunning = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hive_tests_running",
+		Help: "Number of running tests.",
+	})
+	metricClientsRunning = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hive_clients_running",
+		Help: "Number of running client containers.",
+	}, []string{"client"})
+	metricClientStart = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hive_client_start_seconds",
+		Help:    "Time until a client container was online.",
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"client"})
+	metricStartFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hive_container_start_failures_total",
+		Help: "Number of client containers which failed to start.",
+	}, []string{"client"})
+	metricAPIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hive_api_requests_total",
+		Help: "Number of simulation API requests.",
+	}, []string{"method", "route", "code"})
+	metricAPIDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "hive_api_request_duration_seconds",
+		Help: "Duration of simulation API requests.",
+	}, []string{"method", "route"})
+)
+
+// MetricsHandler serves the metrics of hive.
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// trackClient updates the running clients metric until the container exits.
+func trackClient(name string, info *ContainerInfo) {
+	metricClientsRunning.WithLabelValues(name).Inc()
+	metricClientStart.WithLabelValues(name).Observe(info.StartupTime.Seconds())
+	go func() {
+		info.Wait()
+		metricClientsRunning.WithLabelValues(name).Dec()
+	}()
+}
+
+// apiMetrics is a middleware which records the count and duration of API requests.
+// Requests are labeled by their route template, e.g. /testsuite/{suite}/test.
+func apiMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tmpl, err := cr.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		metricAPIDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		metricAPIRequests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,278 @@
+package libhive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/hive/internal/openapi"
+	"github.com/ethereum/hive/internal/simapi"
+	"github.com/gorilla/mux"
+)
+
+// apiOperation describes a route of the simulation API.
+type apiOperation struct {
+	id       string
+	summary  string
+	request  any  // JSON request body, nil if the request has no body
+	form     bool // request body is multipart/form-data
+	response any  // JSON response body, nil if the response is null
+	text     bool // response is plain text
+}
+
+// apiOperations contains the spec of every route of the simulation API,
+// keyed by method and path template.
+var apiOperations = map[string]apiOperation{
+	"GET /hive": {
+		id: "getHiveInfo", summary: "Returns information about the hive instance.",
+		response: HiveInfo{},
+	},
+	"GET /clients": {
+		id: "getClientTypes", summary: "Returns the available client types.",
+		response: []*ClientDefinition{},
+	},
+	"POST /testlist": {
+		id: "setTestList", summary: "Reports the tests of a simulator in test listing mode.",
+		request: simapi.TestList{},
+	},
+	"POST /testsuite": {
+		id: "startSuite", summary: "Starts a test suite.",
+		request: simapi.TestRequest{}, response: TestSuiteID(0),
+	},
+	"DELETE /testsuite/{suite}": {
+		id: "endSuite", summary: "Ends a test suite.",
+	},
+	"POST /testsuite/{suite}/test": {
+		id: "startTest", summary: "Starts a test case.",
+		request: simapi.TestRequest{}, response: TestID(0),
+	},
+	"POST /testsuite/{suite}/test/{test}": {
+		id: "endTest", summary: "Ends a test case and stops its clients.",
+		request: TestResult{},
+	},
+	"POST /testsuite/{suite}/test/{test}/retry": {
+		id: "retryTest", summary: "Ends a failed attempt of a test case.",
+		request: TestResult{},
+	},
+	"POST /testsuite/{suite}/test/{test}/node": {
+		id: "startClient", summary: "Starts a client container.",
+		form: true, response: simapi.StartNodeResponse{},
+	},
+	"GET /testsuite/{suite}/test/{test}/node/{node}": {
+		id: "getClient", summary: "Returns information about a client.",
+		response: simapi.NodeResponse{},
+	},
+	"DELETE /testsuite/{suite}/test/{test}/node/{node}": {
+		id: "stopClient", summary: "Stops a client container.",
+	},
+	"POST /testsuite/{suite}/test/{test}/node/{node}/exec": {
+		id: "execInClient", summary: "Runs a script in a client container.",
+		request: simapi.ExecRequest{}, response: ExecInfo{},
+	},
+	"POST /testsuite/{suite}/test/{test}/node/{node}/pause": {
+		id: "pauseClient", summary: "Pauses a client container.",
+	},
+	"DELETE /testsuite/{suite}/test/{test}/node/{node}/pause": {
+		id: "unpauseClient", summary: "Unpauses a client container.",
+	},
+	"POST /testsuite/{suite}/test/{test}/node/{node}/disk/readonly": {
+		id: "setClientDiskReadOnly", summary: "Makes the disk of a client read-only.",
+	},
+	"DELETE /testsuite/{suite}/test/{test}/node/{node}/disk/readonly": {
+		id: "setClientDiskWritable", summary: "Makes the disk of a client writable.",
+	},
+	"POST /testsuite/{suite}/network/{network}": {
+		id: "createNetwork", summary: "Creates a network.",
+	},
+	"DELETE /testsuite/{suite}/network/{network}": {
+		id: "removeNetwork", summary: "Removes a network.",
+	},
+	"GET /testsuite/{suite}/network/{network}/{node}": {
+		id: "getContainerIP", summary: "Returns the IP address of a container on a network.",
+		response: "",
+	},
+	"POST /testsuite/{suite}/network/{network}/{node}": {
+		id: "connectContainer", summary: "Connects a container to a network.",
+	},
+	"DELETE /testsuite/{suite}/network/{network}/{node}": {
+		id: "disconnectContainer", summary: "Disconnects a container from a network.",
+	},
+	"GET /metrics": {
+		id: "getMetrics", summary: "Returns metrics in the Prometheus text format.",
+		text: true,
+	},
+	"GET /openapi.json": {
+		id: "getOpenAPISpec", summary: "Returns this document.",
+		response: json.RawMessage(nil),
+	},
+}
+
+// Types of path parameters. Parameters not listed here are strings.
+var apiPathParams = map[string]reflect.Type{
+	"suite": reflect.TypeOf(TestSuiteID(0)),
+	"test":  reflect.TypeOf(TestID(0)),
+}
+
+// newOpenAPISpec creates the OpenAPI document of the simulation API from the
+// routes of router.
+func newOpenAPISpec(router *mux.Router) *openapi.Document {
+	doc := &openapi.Document{
+		OpenAPI: "3.0.3",
+		Info: openapi.Info{
+			Title:       "hive simulation API",
+			Description: "The API used by simulators to run tests and start clients.",
+			Version:     "1.0",
+		},
+		Paths: make(map[string]openapi.PathItem),
+	}
+	gen := openapi.NewGenerator(doc)
+	errorResponse := &openapi.Response{
+		Description: "Error",
+		Content:     jsonContent(gen.Schema(reflect.TypeOf(simapi.Error{}))),
+	}
+
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			def, ok := apiOperations[method+" "+path]
+			if !ok {
+				panic(fmt.Sprintf("simulation API route %s %s has no OpenAPI operation", method, path))
+			}
+			op := &openapi.Operation{
+				OperationID: def.id,
+				Summary:     def.summary,
+				Parameters:  pathParameters(gen, path),
+				Responses:   map[string]*openapi.Response{"default": errorResponse},
+			}
+			switch {
+			case def.form:
+				op.RequestBody = &openapi.RequestBody{
+					Required: true,
+					Content: map[string]*openapi.MediaType{"multipart/form-data": {
+						Schema: &openapi.Schema{
+							Type:                 "object",
+							Properties:           map[string]*openapi.Schema{"config": gen.Schema(reflect.TypeOf(simapi.NodeConfig{}))},
+							Required:             []string{"config"},
+							AdditionalProperties: &openapi.Schema{Type: "string", Format: "binary", Description: "file to be copied into the container"},
+						},
+						Encoding: map[string]*openapi.Encoding{"config": {ContentType: "application/json"}},
+					}},
+				}
+			case def.request != nil:
+				op.RequestBody = &openapi.RequestBody{
+					Required: true,
+					Content:  jsonContent(gen.Schema(reflect.TypeOf(def.request))),
+				}
+			}
+			switch {
+			case def.text:
+				op.Responses["200"] = &openapi.Response{
+					Description: "OK",
+					Content:     map[string]*openapi.MediaType{"text/plain": {Schema: &openapi.Schema{Type: "string"}}},
+				}
+			case def.response != nil:
+				op.Responses["200"] = &openapi.Response{
+					Description: "OK",
+					Content:     jsonContent(gen.Schema(reflect.TypeOf(def.response))),
+				}
+			default:
+				op.Responses["200"] = &openapi.Response{
+					Description: "OK",
+					Content:     jsonContent(&openapi.Schema{Nullable: true, Description: "always null"}),
+				}
+			}
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(openapi.PathItem)
+			}
+			doc.Paths[path][strings.ToLower(method)] = op
+		}
+		return nil
+	})
+	return doc
+}
+
+func jsonContent(schema *openapi.Schema) map[string]*openapi.MediaType {
+	return map[string]*openapi.MediaType{"application/json": {Schema: schema}}
+}
+
+func pathParameters(gen *openapi.Generator, path string) []*openapi.Parameter {
+	var params []*openapi.Parameter
+	for _, part := range strings.Split(path, "/") {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			continue
+		}
+		name := part[1 : len(part)-1]
+		schema := &openapi.Schema{Type: "string"}
+		if t, ok := apiPathParams[name]; ok {
+			schema = gen.Schema(t)
+		}
+		params = append(params, &openapi.Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return params
+}
+
+// validateRequests rejects requests which don't match the OpenAPI spec.
+func (api *simAPI) validateRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, _ := mux.CurrentRoute(r).GetPathTemplate()
+		op := api.spec.Operation(r.Method, path)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := api.spec.ValidatePath(op, mux.Vars(r)); err != nil {
+			serveError(w, err, http.StatusBadRequest)
+			return
+		}
+		if op.RequestBody != nil {
+			if err := api.validateBody(r, op.RequestBody); err != nil {
+				serveError(w, err, http.StatusBadRequest)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validateBody checks the request body. JSON bodies are checked against their schema.
+// Multipart forms are only checked for their content type here, and the form fields
+// are validated by the handler.
+func (api *simAPI) validateBody(r *http.Request, spec *openapi.RequestBody) error {
+	if _, ok := spec.Content["multipart/form-data"]; ok {
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("content-type"))
+		if mt != "multipart/form-data" {
+			return &openapi.ValidationError{Field: "body", Message: "content type must be multipart/form-data"}
+		}
+		return nil
+	}
+	mt, ok := spec.Content["application/json"]
+	if !ok {
+		return nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		if spec.Required {
+			return &openapi.ValidationError{Field: "body", Message: "missing request body"}
+		}
+		return nil
+	}
+	return api.spec.ValidateJSON(mt.Schema, body, "body")
+}
+
+// serveOpenAPISpec serves the OpenAPI document.
+func (api *simAPI) serveOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	serveJSON(w, api.spec)
+}
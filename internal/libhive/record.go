@@ -0,0 +1,387 @@
+package libhive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/hive/internal/simapi"
+	"github.com/gorilla/mux"
+)
+
+// APIRecord is a simulation API request and its response, as stored in a recording.
+// Requests are recorded in the order in which they complete.
+type APIRecord struct {
+	Time        time.Time         `json:"time"`
+	Method      string            `json:"method"`
+	Route       string            `json:"route"`          // route template, e.g. /testsuite/{suite}/test
+	Vars        map[string]string `json:"vars,omitempty"` // values of the route variables
+	Query       string            `json:"query,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Body        json.RawMessage   `json:"body,omitempty"`    // request body, if it is JSON
+	RawBody     []byte            `json:"rawBody,omitempty"` // other request bodies, e.g. multipart forms
+	Status      int               `json:"status"`
+	Response    json.RawMessage   `json:"response,omitempty"`
+}
+
+// apiRecorder writes API requests of a test manager to a JSONL file.
+type apiRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	name string // file name in the log directory
+}
+
+func newAPIRecorder(logdir string, id uint64) (*apiRecorder, error) {
+	name := fmt.Sprintf("%d-simapi-%d.jsonl", time.Now().Unix(), id)
+	file, err := os.Create(filepath.Join(logdir, name))
+	if err != nil {
+		return nil, err
+	}
+	return &apiRecorder{file: file, w: bufio.NewWriter(file), name: name}, nil
+}
+
+func (rec *apiRecorder) write(r *APIRecord) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		slog.Error("can't encode API record", "err", err)
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.file == nil {
+		return // closed
+	}
+	rec.w.Write(line)
+	rec.w.WriteByte('\n')
+	if err := rec.w.Flush(); err != nil {
+		slog.Error("can't write API record", "err", err)
+	}
+}
+
+func (rec *apiRecorder) close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.file == nil {
+		return nil
+	}
+	rec.w.Flush()
+	err := rec.file.Close()
+	rec.file = nil
+	return err
+}
+
+// middleware records the requests handled by next.
+func (rec *apiRecorder) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, _ := mux.CurrentRoute(r).GetPathTemplate()
+		if route == "/metrics" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			serveError(w, err, http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		resp := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(resp, r)
+
+		record := &APIRecord{
+			Time:        time.Now(),
+			Method:      r.Method,
+			Route:       route,
+			Vars:        mux.Vars(r),
+			Query:       r.URL.RawQuery,
+			ContentType: r.Header.Get("content-type"),
+			Status:      resp.status,
+			Response:    asJSON(resp.body.Bytes()),
+		}
+		switch {
+		case len(body) == 0:
+		case json.Valid(body):
+			record.Body = body
+		default:
+			record.RawBody = body
+		}
+		rec.write(record)
+	})
+}
+
+// asJSON returns data if it is valid JSON, and data as a JSON string otherwise.
+func asJSON(data []byte) json.RawMessage {
+	if len(data) == 0 || json.Valid(data) {
+		return data
+	}
+	enc, _ := json.Marshal(string(data))
+	return enc
+}
+
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+// ReadAPIRecording reads a recording file.
+func ReadAPIRecording(file string) ([]APIRecord, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []APIRecord
+	dec := json.NewDecoder(f)
+	for {
+		var r APIRecord
+		if err := dec.Decode(&r); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return records, fmt.Errorf("%s: record %d: %v", file, len(records)+1, err)
+		}
+		records = append(records, r)
+	}
+}
+
+// RecordedClients returns the client definitions used in a recording. The definitions
+// are taken from the response of the /clients request. If the simulator didn't request
+// them, definitions are created from the client names of started clients.
+func RecordedClients(records []APIRecord) []*ClientDefinition {
+	for _, r := range records {
+		if r.Route == "/clients" && r.Status == http.StatusOK {
+			var defs []*ClientDefinition
+			if err := json.Unmarshal(r.Response, &defs); err == nil {
+				return defs
+			}
+		}
+	}
+	var (
+		defs []*ClientDefinition
+		seen = make(map[string]bool)
+	)
+	for _, r := range records {
+		if r.Route != "/testsuite/{suite}/test/{test}/node" {
+			continue
+		}
+		config, err := recordedNodeConfig(&r)
+		if err != nil || seen[config.Client] {
+			continue
+		}
+		seen[config.Client] = true
+		defs = append(defs, &ClientDefinition{Name: config.Client, Image: config.Client})
+	}
+	return defs
+}
+
+func recordedNodeConfig(r *APIRecord) (*simapi.NodeConfig, error) {
+	_, params, err := mime.ParseMediaType(r.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	form, err := multipart.NewReader(bytes.NewReader(r.RawBody), params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		return nil, err
+	}
+	defer form.RemoveAll()
+	var config simapi.NodeConfig
+	if v := form.Value["config"]; len(v) > 0 {
+		err = json.Unmarshal([]byte(v[0]), &config)
+	}
+	return &config, err
+}
+
+// ReplayDiff is a replayed request whose response differs from the recording.
+type ReplayDiff struct {
+	Index    int // index of the record
+	Record   APIRecord
+	Status   int
+	Response []byte
+}
+
+func (d ReplayDiff) String() string {
+	if d.Status != d.Record.Status {
+		return fmt.Sprintf("request %d (%s %s): status %d, recorded %d: %s",
+			d.Index+1, d.Record.Method, d.Record.Route, d.Status, d.Record.Status, bytes.TrimSpace(d.Response))
+	}
+	return fmt.Sprintf("request %d (%s %s): response %s, recorded %s",
+		d.Index+1, d.Record.Method, d.Record.Route, bytes.TrimSpace(d.Response), bytes.TrimSpace(d.Record.Response))
+}
+
+// Replay sends the recorded requests to the simulation API of tm, one after another.
+// The IDs of suites, tests and clients created during the replay are different from
+// the recorded ones, so the routes of later requests are translated to the new IDs.
+// Replay returns the requests whose response status or response differs from the
+// recording. Responses are compared only for deterministic routes, see sameResponse.
+func Replay(tm *TestManager, records []APIRecord) []ReplayDiff {
+	var (
+		api   = tm.API()
+		ids   = make(map[string]map[string]string) // route variable -> recorded ID -> new ID
+		diffs []ReplayDiff
+	)
+	mapID := func(kind, id string) string {
+		if newID, ok := ids[kind][id]; ok {
+			return newID
+		}
+		return id
+	}
+
+	for i, r := range records {
+		path := r.Route
+		for name, value := range r.Vars {
+			path = strings.ReplaceAll(path, "{"+name+"}", url.PathEscape(mapID(name, value)))
+		}
+		if r.Query != "" {
+			path += "?" + r.Query
+		}
+		body := r.RawBody
+		if len(r.Body) > 0 {
+			body = replayBody(r, mapID)
+		}
+		req := httptest.NewRequest(r.Method, path, bytes.NewReader(body))
+		if r.ContentType != "" {
+			req.Header.Set("content-type", r.ContentType)
+		}
+		resp := httptest.NewRecorder()
+		api.ServeHTTP(resp, req)
+
+		if resp.Code != r.Status || !sameResponse(r, resp.Body.Bytes(), mapID) {
+			diffs = append(diffs, ReplayDiff{Index: i, Record: r, Status: resp.Code, Response: resp.Body.Bytes()})
+			continue
+		}
+		if kind, old, ok := createdID(r.Route, r.Method, r.Response); ok {
+			if _, id, ok := createdID(r.Route, r.Method, resp.Body.Bytes()); ok {
+				if ids[kind] == nil {
+					ids[kind] = make(map[string]string)
+				}
+				ids[kind][old] = id
+			}
+		}
+	}
+	return diffs
+}
+
+// nondeterministicRoutes are the requests whose responses depend on the clients or
+// the container backend rather than on the sequence of API calls.
+var nondeterministicRoutes = map[string]bool{
+	"GET /hive":    true,
+	"GET /clients": true,
+	"POST /testsuite/{suite}/test/{test}/node/{node}/exec": true,
+	"GET /testsuite/{suite}/network/{network}/{node}":      true,
+}
+
+// sameResponse reports whether the replayed response of a request matches the
+// recorded one. The status codes must already be equal.
+//
+// Errors are compared by their error code, since messages may contain IDs. Responses
+// which depend on the clients or the container backend (hive info, client versions,
+// exec output, IP addresses) are not compared, and neither are the IDs of created
+// objects, which are remapped instead. Other responses must be equal as JSON, after
+// translating the recorded client IDs.
+func sameResponse(r APIRecord, replayed []byte, mapID func(kind, id string) string) bool {
+	if r.Status != http.StatusOK {
+		var recErr, repErr simapi.Error
+		if json.Unmarshal(r.Response, &recErr) != nil || recErr.Code == "" {
+			return true // not a structured error, can't compare
+		}
+		if err := json.Unmarshal(replayed, &repErr); err != nil {
+			return false
+		}
+		return recErr.Code == repErr.Code && recErr.Field == repErr.Field
+	}
+
+	if _, _, ok := createdID(r.Route, r.Method, r.Response); ok {
+		_, _, ok := createdID(r.Route, r.Method, replayed)
+		return ok
+	}
+	if nondeterministicRoutes[r.Method+" "+r.Route] {
+		return true
+	}
+	if r.Method == http.MethodGet && r.Route == "/testsuite/{suite}/test/{test}/node/{node}" {
+		var recNode, repNode simapi.NodeResponse
+		if err := json.Unmarshal(r.Response, &recNode); err != nil {
+			return true
+		}
+		if err := json.Unmarshal(replayed, &repNode); err != nil {
+			return false
+		}
+		recNode.ID = mapID("node", recNode.ID)
+		return recNode == repNode
+	}
+
+	var recorded, got any
+	if len(r.Response) == 0 || json.Unmarshal(r.Response, &recorded) != nil {
+		return true // response not recorded
+	}
+	if err := json.Unmarshal(replayed, &got); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(recorded, got)
+}
+
+// replayBody translates the parent test ID in test start requests.
+func replayBody(r APIRecord, mapID func(kind, id string) string) []byte {
+	if r.Route != "/testsuite/{suite}/test" {
+		return r.Body
+	}
+	var req simapi.TestRequest
+	if err := json.Unmarshal(r.Body, &req); err != nil || req.Parent == 0 {
+		return r.Body
+	}
+	parent, err := strconv.ParseUint(mapID("test", fmt.Sprint(req.Parent)), 10, 32)
+	if err != nil {
+		return r.Body
+	}
+	req.Parent = uint32(parent)
+	body, _ := json.Marshal(&req)
+	return body
+}
+
+// createdID returns the ID of the object created by a request.
+func createdID(route, method string, response []byte) (kind, id string, ok bool) {
+	if method != http.MethodPost {
+		return "", "", false
+	}
+	switch route {
+	case "/testsuite":
+		var id TestSuiteID
+		err := json.Unmarshal(response, &id)
+		return "suite", fmt.Sprint(id), err == nil
+	case "/testsuite/{suite}/test":
+		var id TestID
+		err := json.Unmarshal(response, &id)
+		return "test", fmt.Sprint(id), err == nil
+	case "/testsuite/{suite}/test/{test}/node":
+		var node simapi.StartNodeResponse
+		err := json.Unmarshal(response, &node)
+		return "node", node.ID, err == nil && node.ID != ""
+	}
+	return "", "", false
+}
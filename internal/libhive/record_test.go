@@ -0,0 +1,125 @@
+package libhive_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/hive/hivesim"
+	"github.com/ethereum/hive/internal/fakes"
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+// This test records a simulation and replays it against a backend which
+// assigns different container IDs.
+func TestRecordReplay(t *testing.T) {
+	defs := []*libhive.ClientDefinition{{Name: "client-1", Image: "client-1"}}
+	env := libhive.SimEnv{LogDir: t.TempDir(), RecordAPI: true}
+	tm := libhive.NewTestManager(env, fakes.NewContainerBackend(nil), defs, libhive.HiveInfo{})
+	srv := httptest.NewServer(tm.API())
+
+	suite := hivesim.Suite{Name: "suite"}
+	suite.Add(hivesim.TestSpec{
+		Name: "parent",
+		Run: func(t *hivesim.T) {
+			t.Run(hivesim.TestSpec{
+				Name: "child",
+				Run: func(t *hivesim.T) {
+					c := t.StartClient("client-1")
+					if err := t.Sim.StopClient(t.SuiteID, t.TestID, c.Container); err != nil {
+						t.Fatal(err)
+					}
+				},
+			})
+		},
+	})
+	if err := hivesim.RunSuite(hivesim.NewAt(srv.URL), suite); err != nil {
+		t.Fatal("RunSuite failed:", err)
+	}
+	srv.Close()
+	tm.Terminate()
+
+	// Find the recording.
+	var recording string
+	for _, s := range tm.Results() {
+		recording = s.APIRecording
+	}
+	if recording == "" {
+		t.Fatal("suite has no API recording")
+	}
+	records, err := libhive.ReadAPIRecording(filepath.Join(env.LogDir, recording))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 {
+		t.Fatal("recording is empty")
+	}
+	if clients := libhive.RecordedClients(records); len(clients) != 1 || clients[0].Name != "client-1" {
+		t.Fatalf("wrong recorded clients: %v", clients)
+	}
+
+	// Replay.
+	var counter atomic.Int64
+	backend := fakes.NewContainerBackend(&fakes.BackendHooks{
+		CreateContainer: func(image string, opt libhive.ContainerOptions) (string, error) {
+			return fmt.Sprintf("replay-%d", counter.Add(1)), nil
+		},
+	})
+	replayEnv := libhive.SimEnv{LogDir: t.TempDir()}
+	replayTM := libhive.NewTestManager(replayEnv, backend, defs, libhive.HiveInfo{})
+	diffs := libhive.Replay(replayTM, records)
+	replayTM.Terminate()
+	for _, d := range diffs {
+		t.Error(d)
+	}
+
+	// Check the replay created the same tests.
+	want, got := testTree(tm), testTree(replayTM)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("wrong tests after replay:\n got %v\nwant %v", got, want)
+	}
+	if counter.Load() != 1 {
+		t.Errorf("replay created %d containers, want 1", counter.Load())
+	}
+
+	// Change the recorded responses. The changed test end response should be
+	// reported, but the client IP is backend-specific and should be ignored.
+	changed := -1
+	for i := range records {
+		r := &records[i]
+		switch {
+		case r.Method == "POST" && r.Route == "/testsuite/{suite}/test/{test}/node":
+			r.Response = json.RawMessage(strings.Replace(string(r.Response), `"ip":"`, `"ip":"10.`, 1))
+		case r.Method == "POST" && r.Route == "/testsuite/{suite}/test/{test}" && changed == -1:
+			r.Response = json.RawMessage(`{"unexpected":true}`)
+			changed = i
+		}
+	}
+	replayTM = libhive.NewTestManager(libhive.SimEnv{LogDir: t.TempDir()}, fakes.NewContainerBackend(nil), defs, libhive.HiveInfo{})
+	diffs = libhive.Replay(replayTM, records)
+	replayTM.Terminate()
+	if len(diffs) != 1 || diffs[0].Index != changed {
+		t.Fatalf("wrong diffs for changed recording, want request %d: %v", changed+1, diffs)
+	}
+}
+
+// testTree returns the tests of all suites as "parent/test clients=N" strings.
+func testTree(tm *libhive.TestManager) []string {
+	var tests []string
+	for _, suite := range tm.Results() {
+		for _, test := range suite.TestCases {
+			parent := ""
+			if p := suite.TestCases[test.Parent]; p != nil {
+				parent = p.Name
+			}
+			tests = append(tests, fmt.Sprintf("%s/%s clients=%d pass=%v", parent, test.Name, len(test.ClientInfo), test.SummaryResult.Pass))
+		}
+	}
+	sort.Strings(tests)
+	return tests
+}
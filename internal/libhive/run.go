@@ -9,10 +9,12 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,21 +29,49 @@ type Runner struct {
 	container ContainerBackend
 	builder   Builder
 
+	// This is the max number of images built concurrently.
+	buildParallelism int
+
+	// This records the outcome of all image builds.
+	builds []ImageBuild
+
 	// This holds the image names of all built simulators.
 	simImages  map[string]string
 	clientDefs []*ClientDefinition
+
+	// This is set when images were loaded by ImportImages.
+	imported *ImageManifest
+
+	// This is notified about finished suites and runs.
+	sink ResultSink
 }
 
 func NewRunner(inv Inventory, b Builder, cb ContainerBackend) *Runner {
 	return &Runner{
-		inv:       inv,
-		builder:   b,
-		container: cb,
+		inv:              inv,
+		builder:          b,
+		container:        cb,
+		buildParallelism: 1,
 	}
 }
 
-// Build builds client and simulator images.
+// SetBuildParallelism sets the max number of images built concurrently.
+func (r *Runner) SetBuildParallelism(n int) {
+	r.buildParallelism = max(n, 1)
+}
+
+// SetResultSink sets the sink which is notified when suites and simulation runs end.
+// The sink is not used in test listing mode.
+func (r *Runner) SetResultSink(sink ResultSink) {
+	r.sink = sink
+}
+
+// Build builds client and simulator images. If images were imported, it selects the
+// imported images instead.
 func (r *Runner) Build(ctx context.Context, clientList []ClientDesignator, simList []string, simBuildArgs map[string]string) error {
+	if r.imported != nil {
+		return r.useImportedImages(clientList, simList)
+	}
 	if err := r.container.Build(ctx, r.builder); err != nil {
 		return err
 	}
@@ -51,52 +81,148 @@ func (r *Runner) Build(ctx context.Context, clientList []ClientDesignator, simLi
 	return r.buildSimulators(ctx, simList, simBuildArgs)
 }
 
+// BuildForTestListing builds simulator images. Clients are made available to simulators
+// by name only, without building their images, since simulators in test listing mode
+// don't start any clients.
+func (r *Runner) BuildForTestListing(ctx context.Context, clientList []ClientDesignator, simList []string, simBuildArgs map[string]string) error {
+	if err := r.container.Build(ctx, r.builder); err != nil {
+		return err
+	}
+	r.clientDefs = make([]*ClientDefinition, 0, len(clientList))
+	for _, client := range clientList {
+		r.clientDefs = append(r.clientDefs, &ClientDefinition{
+			Name: client.Name(),
+			Meta: r.inv.clientMeta(client.Client),
+		})
+	}
+	return r.buildSimulators(ctx, simList, simBuildArgs)
+}
+
 // buildClients builds client images.
 func (r *Runner) buildClients(ctx context.Context, clientList []ClientDesignator) error {
 	if len(clientList) == 0 {
 		return errors.New("client list is empty, cannot simulate")
 	}
 
-	r.clientDefs = make([]*ClientDefinition, 0, len(clientList))
-
-	var anyBuilt bool
 	slog.Info(fmt.Sprintf("building %d clients...", len(clientList)))
-	for _, client := range clientList {
+	var (
+		defs   = make([]*ClientDefinition, len(clientList))
+		builds = make([]ImageBuild, len(clientList))
+	)
+	runConcurrently(r.buildParallelism, len(clientList), func(i int) {
+		client := clientList[i]
+		builds[i] = ImageBuild{Name: client.Name(), Kind: "client", Start: time.Now()}
 		image, err := r.builder.BuildClientImage(ctx, client)
+		builds[i].End = time.Now()
+		builds[i].Image = image
 		if err != nil {
-			continue
+			builds[i].Error = err.Error()
+			return
 		}
-		anyBuilt = true
 		version, err := r.builder.ReadFile(ctx, image, "/version.txt")
 		if err != nil {
 			slog.Warn("can't read version info of "+client.Client, "image", image, "err", err)
 		}
-		r.clientDefs = append(r.clientDefs, &ClientDefinition{
-			Name:    client.Name(),
-			Version: strings.TrimSpace(string(version)),
-			Image:   image,
-			Meta:    r.inv.Clients[client.Client].Meta,
-		})
+		versionString := strings.TrimSpace(string(version))
+		if versionString == "" && client.Image != "" {
+			// Fall back to the image reference for images without version.txt.
+			versionString = client.Image
+		}
+		defs[i] = &ClientDefinition{
+			Name:        client.Name(),
+			Version:     versionString,
+			Image:       image,
+			Meta:        r.inv.clientMeta(client.Client),
+			Environment: client.Environment,
+			Files:       client.Files,
+			designator:  client,
+		}
+	})
+
+	r.builds = append(r.builds, builds...)
+
+	// Collect built clients, keeping the order of the client list.
+	r.clientDefs = make([]*ClientDefinition, 0, len(clientList))
+	for _, def := range defs {
+		if def != nil {
+			r.clientDefs = append(r.clientDefs, def)
+		}
 	}
-	if !anyBuilt {
+	if len(r.clientDefs) == 0 {
 		return errors.New("all clients failed to build")
 	}
 	return nil
 }
 
 // buildSimulators builds simulator images.
+// If any simulator fails to build, the remaining builds still run to completion.
 func (r *Runner) buildSimulators(ctx context.Context, simList []string, buildArgs map[string]string) error {
+	slog.Info(fmt.Sprintf("building %d simulators...", len(simList)))
+	var (
+		errs   = make([]error, len(simList))
+		builds = make([]ImageBuild, len(simList))
+	)
+	runConcurrently(r.buildParallelism, len(simList), func(i int) {
+		builds[i] = ImageBuild{Name: simList[i], Kind: "simulator", Start: time.Now()}
+		builds[i].Image, errs[i] = r.builder.BuildSimulatorImage(ctx, simList[i], buildArgs)
+		builds[i].End = time.Now()
+		if errs[i] != nil {
+			builds[i].Error = errs[i].Error()
+		}
+	})
+	r.builds = append(r.builds, builds...)
+
 	r.simImages = make(map[string]string)
+	for i, sim := range simList {
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("simulator %s: %w", sim, errs[i])
+			continue
+		}
+		r.simImages[sim] = builds[i].Image
+	}
+	return errors.Join(errs...)
+}
 
-	slog.Info(fmt.Sprintf("building %d simulators...", len(simList)))
-	for _, sim := range simList {
-		image, err := r.builder.BuildSimulatorImage(ctx, sim, buildArgs)
-		if err != nil {
-			return err
+// WriteBuildSummary writes the outcome of all image builds to summary.json in the
+// given build log directory. The directory is relative to the results directory.
+func (r *Runner) WriteBuildSummary(logdir, buildLogDir string) error {
+	summary := BuildSummary{Images: make([]ImageBuild, len(r.builds))}
+	dir := filepath.Join(logdir, filepath.FromSlash(buildLogDir))
+	for i, b := range r.builds {
+		// Images which were used without building or pulling them have no log.
+		if b.Image != "" {
+			if _, err := os.Stat(filepath.Join(dir, BuildLogFile(b.Image))); err == nil {
+				b.LogFile = path.Join(buildLogDir, BuildLogFile(b.Image))
+			}
 		}
-		r.simImages[sim] = image
+		summary.Images[i] = b
 	}
-	return nil
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	enc, err := json.MarshalIndent(&summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "summary.json"), enc, 0644)
+}
+
+// runConcurrently calls fn for indexes 0..n-1, running up to limit calls
+// concurrently. It returns when all calls have finished.
+func runConcurrently(limit, n int, fn func(i int)) {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, max(limit, 1))
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
 }
 
 func (r *Runner) Run(ctx context.Context, sim string, env SimEnv, hiveInfo HiveInfo) (SimResult, error) {
@@ -107,6 +233,81 @@ func (r *Runner) Run(ctx context.Context, sim string, env SimEnv, hiveInfo HiveI
 	return r.run(ctx, sim, env, hiveInfo)
 }
 
+// RunSimulators runs the given simulators, up to 'concurrency' of them at the same time.
+// Each simulator gets its own API server and docker networks. The results are returned in
+// the order of simList. If a simulator run fails, the other simulators still run, and the
+// errors are returned together.
+func (r *Runner) RunSimulators(ctx context.Context, simList []string, concurrency int, env SimEnv, hiveInfo HiveInfo) ([]SimResult, error) {
+	if err := createWorkspace(env.LogDir); err != nil {
+		return nil, err
+	}
+	writeInstanceInfo(env.LogDir)
+
+	var (
+		start   = time.Now()
+		results = make([]SimResult, len(simList))
+		errs    = make([]error, len(simList))
+	)
+	runConcurrently(concurrency, len(simList), func(i int) {
+		sim := simList[i]
+		if ctx.Err() != nil {
+			errs[i] = fmt.Errorf("simulator %s: %w", sim, errSimInterrupt)
+			return
+		}
+		results[i], errs[i] = r.run(ctx, sim, env, hiveInfo)
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("simulator %s: %w", sim, errs[i])
+			return
+		}
+		result := results[i]
+		slog.Info(fmt.Sprintf("simulation %s finished", sim), "suites", result.Suites, "tests", result.Tests, "failed", result.TestsFailed)
+	})
+	err := errors.Join(errs...)
+	if r.sink != nil && !env.SimListTests {
+		r.sink.RunEnded(newRunSummary(simList, results, start, err))
+	}
+	return results, err
+}
+
+func newRunSummary(simList []string, results []SimResult, start time.Time, err error) *RunSummary {
+	s := &RunSummary{Event: "run", Simulators: simList, Start: start, End: time.Now()}
+	for _, result := range results {
+		s.Suites += result.Suites
+		s.SuitesFailed += result.SuitesFailed
+		s.Tests += result.Tests
+		s.TestsFailed += result.TestsFailed
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	return s
+}
+
+// Replay sends the requests of an API recording to a new simulation API, using the
+// container backend of the runner. Clients must be built by Build before calling Replay.
+// It returns the requests whose response status or response differs from the recording,
+// as described for the Replay function.
+func (r *Runner) Replay(ctx context.Context, env SimEnv, records []APIRecord, hiveInfo HiveInfo) ([]ReplayDiff, error) {
+	if err := createWorkspace(env.LogDir); err != nil {
+		return nil, err
+	}
+	tm := NewTestManager(env, r.container, r.clientDefs, hiveInfo)
+	defer func() {
+		if err := tm.Terminate(); err != nil {
+			slog.Error("could not terminate test manager", "error", err)
+		}
+	}()
+
+	// The API server isn't used by the replay, but starting it also starts
+	// the proxy which checks whether clients are online.
+	server, err := r.container.ServeAPI(ctx, tm.API())
+	if err != nil {
+		return nil, err
+	}
+	defer shutdownServer(server)
+	return Replay(tm, records), nil
+}
+
 // RunDevMode starts simulator development mode. In this mode, the simulator is not
 // launched and the API server runs on the local network instead of listening for requests
 // on the docker network.
@@ -121,6 +322,7 @@ func (r *Runner) RunDevMode(ctx context.Context, env SimEnv, endpoint string, hi
 		clientDefs = append(clientDefs, def)
 	}
 	tm := NewTestManager(env, r.container, clientDefs, hiveInfo)
+	tm.SetResultSink(r.sink)
 	defer func() {
 		if err := tm.Terminate(); err != nil {
 			slog.Error("could not terminate test manager", "error", err)
@@ -183,6 +385,9 @@ func (r *Runner) run(ctx context.Context, sim string, env SimEnv, hiveInfo HiveI
 
 	// Start the simulation API.
 	tm := NewTestManager(env, r.container, clientDefs, hiveInfo)
+	if r.sink != nil && !env.SimListTests {
+		tm.SetResultSink(r.sink)
+	}
 	defer func() {
 		if err := tm.Terminate(); err != nil {
 			slog.Error("could not terminate test manager", "error", err)
@@ -205,7 +410,13 @@ func (r *Runner) run(ctx context.Context, sim string, env SimEnv, hiveInfo HiveI
 			"HIVE_LOGLEVEL":     strconv.Itoa(env.SimLogLevel),
 			"HIVE_TEST_PATTERN": env.SimTestPattern,
 			"HIVE_RANDOM_SEED":  strconv.Itoa(env.SimRandomSeed),
+			"HIVE_RETRIES":      strconv.Itoa(env.SimRetries),
+			"HIVE_SHARD":        env.SimShard,
 		},
+		Labels: map[string]string{LabelSim: sim},
+	}
+	if env.SimListTests {
+		opts.Env["HIVE_LIST_TESTS"] = "true"
 	}
 	containerID, err := r.container.CreateContainer(ctx, r.simImages[sim], opts)
 	if err != nil {
@@ -215,7 +426,7 @@ func (r *Runner) run(ctx context.Context, sim string, env SimEnv, hiveInfo HiveI
 	// Set the log file, and notify TestManager about the container.
 	logbasename := fmt.Sprintf("%d-simulator-%s.log", time.Now().Unix(), containerID)
 	opts.LogFile = filepath.Join(env.LogDir, logbasename)
-	tm.SetSimContainerInfo(containerID, logbasename)
+	tm.SetSimContainerInfo(sim, containerID, logbasename)
 
 	slog.Debug("starting simulator container")
 	sc, err := r.container.StartContainer(ctx, containerID, opts)
@@ -256,7 +467,7 @@ func (r *Runner) run(ctx context.Context, sim string, env SimEnv, hiveInfo HiveI
 	}
 
 	// Count the results.
-	var result SimResult
+	result := SimResult{TestList: tm.TestList()}
 	for _, suite := range tm.Results() {
 		var suiteFailCounted bool
 		result.Suites++
@@ -1,13 +1,22 @@
 package libhive_test
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ethereum/hive/hivesim"
 	"github.com/ethereum/hive/internal/fakes"
@@ -60,6 +69,268 @@ func TestRunner(t *testing.T) {
 	t.Logf("hive.json content: %s", content)
 }
 
+// This test checks that images are built concurrently, and that a failing build
+// doesn't prevent other images from being built.
+func TestRunnerParallelBuild(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		running     int
+		maxRunning  int
+		builtImages []string
+		logdir      = t.TempDir()
+	)
+	build := func(name string) error {
+		// Write the build log like the docker builder. Building client-3 doesn't
+		// produce a log, like an existing prebuilt image.
+		if name != "client-3" {
+			file := filepath.Join(logdir, "builds", "1", libhive.BuildLogFile(name))
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(file, []byte("build output\n"), 0644); err != nil {
+				return err
+			}
+		}
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		running--
+		if strings.HasSuffix(name, "-2") {
+			return errors.New("build failed")
+		}
+		builtImages = append(builtImages, name)
+		return nil
+	}
+	b := fakes.NewBuilder(&fakes.BuilderHooks{
+		BuildClientImage: func(ctx context.Context, client libhive.ClientDesignator) (string, error) {
+			return client.Client, build(client.Client)
+		},
+		BuildSimulatorImage: func(ctx context.Context, sim string, args map[string]string) (string, error) {
+			return sim, build(sim)
+		},
+	})
+
+	inv := makeTestInventory()
+	inv.AddSimulator("sim-2")
+	inv.AddSimulator("sim-3")
+	runner := libhive.NewRunner(inv, b, fakes.NewContainerBackend(nil))
+	runner.SetBuildParallelism(2)
+
+	clients := []libhive.ClientDesignator{{Client: "client-1"}, {Client: "client-2"}, {Client: "client-3"}}
+	sims := []string{"sim-1", "sim-2", "sim-3"}
+	err := runner.Build(context.Background(), clients, sims, nil)
+	if err == nil || !strings.Contains(err.Error(), "sim-2") {
+		t.Fatalf("wrong error from Build: %v", err)
+	}
+
+	sort.Strings(builtImages)
+	want := []string{"client-1", "client-3", "sim-1", "sim-3"}
+	if !reflect.DeepEqual(builtImages, want) {
+		t.Errorf("wrong images built: %v", builtImages)
+	}
+	if maxRunning != 2 {
+		t.Errorf("max concurrent builds is %d, want 2", maxRunning)
+	}
+
+	// Check the build summary.
+	if err := runner.WriteBuildSummary(logdir, "builds/1"); err != nil {
+		t.Fatal("WriteBuildSummary failed:", err)
+	}
+	content, err := os.ReadFile(filepath.Join(logdir, "builds", "1", "summary.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary libhive.BuildSummary
+	if err := json.Unmarshal(content, &summary); err != nil {
+		t.Fatal("invalid build summary:", err)
+	}
+	var failed []string
+	logs := make(map[string]string)
+	for _, b := range summary.Images {
+		if b.Failed() {
+			failed = append(failed, b.Kind+" "+b.Name+" "+b.LogFile)
+		}
+		logs[b.Name] = b.LogFile
+	}
+	wantFailed := []string{"client client-2 builds/1/client-2.log", "simulator sim-2 builds/1/sim-2.log"}
+	if !reflect.DeepEqual(failed, wantFailed) {
+		t.Errorf("wrong failed builds in summary: %q", failed)
+	}
+	if logs["client-1"] != "builds/1/client-1.log" || logs["client-3"] != "" {
+		t.Errorf("wrong log files in summary: %q", logs)
+	}
+}
+
+// This test checks that simulators can run concurrently, and that their docker
+// networks don't collide.
+func TestRunnerConcurrentSimulators(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		networks   []string
+		running    sync.WaitGroup
+		allRunning = make(chan struct{})
+	)
+	running.Add(2)
+	go func() { running.Wait(); close(allRunning) }()
+
+	cb := fakes.NewContainerBackend(&fakes.BackendHooks{
+		CreateNetwork: func(name string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			networks = append(networks, name)
+			return name, nil
+		},
+		StartContainer: func(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+			if !strings.Contains(image, "/simulator/") {
+				return new(libhive.ContainerInfo), nil
+			}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				running.Done()
+				select {
+				case <-allRunning:
+				case <-time.After(5 * time.Second):
+					t.Error("simulators did not run concurrently")
+				}
+				suite := hivesim.Suite{Name: image}
+				suite.Add(hivesim.TestSpec{
+					Name: "network",
+					Run: func(t *hivesim.T) {
+						if err := t.Sim.CreateNetwork(t.SuiteID, "net"); err != nil {
+							t.Fatal(err)
+						}
+					},
+				})
+				hivesim.RunSuite(hivesim.NewAt(opt.Env["HIVE_SIMULATOR"]), suite)
+			}()
+			return &libhive.ContainerInfo{Wait: func() { <-done }}, nil
+		},
+	})
+
+	inv := makeTestInventory()
+	inv.AddSimulator("sim-2")
+	runner := libhive.NewRunner(inv, fakes.NewBuilder(nil), cb)
+	ctx := context.Background()
+	sims := []string{"sim-1", "sim-2"}
+	if err := runner.Build(ctx, []libhive.ClientDesignator{{Client: "client-1"}}, sims, nil); err != nil {
+		t.Fatal("Build() failed:", err)
+	}
+	env := libhive.SimEnv{LogDir: t.TempDir()}
+	results, err := runner.RunSimulators(ctx, sims, 2, env, libhive.HiveInfo{})
+	if err != nil {
+		t.Fatal("RunSimulators() failed:", err)
+	}
+	for i, result := range results {
+		if result.Tests != 1 || result.TestsFailed != 0 {
+			t.Errorf("wrong result for %s: %+v", sims[i], result)
+		}
+	}
+	if len(networks) != 2 || networks[0] == networks[1] {
+		t.Errorf("wrong networks created: %v", networks)
+	}
+}
+
+// This test checks that exported images can be imported by another runner, which
+// then uses them without building.
+func TestRunnerExportImport(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		inv     = makeTestInventory()
+		clients = []libhive.ClientDesignator{{Client: "client-1"}, {Client: "client-2"}}
+		sims    = []string{"sim-1"}
+		saved   []string
+	)
+	b := fakes.NewBuilder(&fakes.BuilderHooks{
+		ReadFile: func(ctx context.Context, image, file string) ([]byte, error) {
+			return []byte("version of " + image), nil
+		},
+		SaveImages: func(ctx context.Context, images []string, w io.Writer) error {
+			saved = images
+			tw := tar.NewWriter(w)
+			tw.WriteHeader(&tar.Header{Name: "image", Mode: 0644, Size: 10})
+			tw.Write([]byte("image data"))
+			return tw.Close()
+		},
+	})
+	runner := libhive.NewRunner(inv, b, fakes.NewContainerBackend(nil))
+	if err := runner.Build(ctx, clients, sims, nil); err != nil {
+		t.Fatal("Build() failed:", err)
+	}
+	var archive bytes.Buffer
+	if err := runner.ExportImages(ctx, &archive); err != nil {
+		t.Fatal("ExportImages() failed:", err)
+	}
+	wantSaved := []string{"fakebuild/client/client-1:latest", "fakebuild/client/client-2:latest", "fakebuild/simulator/sim-1:latest"}
+	if !reflect.DeepEqual(saved, wantSaved) {
+		t.Errorf("wrong images saved: %v", saved)
+	}
+
+	// Import into a runner which can't build anything.
+	var loaded []byte
+	b2 := fakes.NewBuilder(&fakes.BuilderHooks{
+		BuildClientImage: func(ctx context.Context, client libhive.ClientDesignator) (string, error) {
+			return "", errors.New("client build attempted")
+		},
+		BuildSimulatorImage: func(ctx context.Context, sim string, args map[string]string) (string, error) {
+			return "", errors.New("simulator build attempted")
+		},
+		LoadImages: func(ctx context.Context, r io.Reader) error {
+			tr := tar.NewReader(r)
+			if _, err := tr.Next(); err != nil {
+				return err
+			}
+			loaded, _ = io.ReadAll(tr)
+			_, err := tr.Next()
+			if err != io.EOF {
+				return fmt.Errorf("expected end of archive, got %v", err)
+			}
+			return nil
+		},
+	})
+	var clientDefs []*hivesim.ClientDefinition
+	cb := fakes.NewContainerBackend(&fakes.BackendHooks{
+		StartContainer: func(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+			if image == "fakebuild/simulator/sim-1:latest" {
+				defs, err := hivesim.NewAt(opt.Env["HIVE_SIMULATOR"]).ClientTypes()
+				if err != nil {
+					t.Error("can't get client types:", err)
+				}
+				clientDefs = defs
+			}
+			return new(libhive.ContainerInfo), nil
+		},
+	})
+	runner2 := libhive.NewRunner(inv, b2, cb)
+	if err := runner2.ImportImages(ctx, &archive); err != nil {
+		t.Fatal("ImportImages() failed:", err)
+	}
+	if string(loaded) != "image data" {
+		t.Errorf("wrong image data loaded: %q", loaded)
+	}
+	if err := runner2.Build(ctx, clients[1:], sims, nil); err != nil {
+		t.Fatal("Build() failed after import:", err)
+	}
+	env := libhive.SimEnv{LogDir: t.TempDir(), ClientList: clients[1:]}
+	if _, err := runner2.Run(ctx, "sim-1", env, libhive.HiveInfo{}); err != nil {
+		t.Fatal("Run() failed:", err)
+	}
+	if len(clientDefs) != 1 || clientDefs[0].Name != "client-2" || clientDefs[0].Version != "version of fakebuild/client/client-2:latest" {
+		t.Errorf("wrong client definitions: %+v", clientDefs)
+	}
+
+	// Clients which weren't exported can't be used.
+	if err := runner2.Build(ctx, []libhive.ClientDesignator{{Client: "client-3"}}, sims, nil); err == nil {
+		t.Error("no error for client missing in imported images")
+	}
+}
+
 func makeTestInventory() libhive.Inventory {
 	var inv libhive.Inventory
 	inv.AddClient("client-1", nil)
@@ -85,3 +356,118 @@ func clientDesignatorNames(clients []libhive.ClientDesignator) []string {
 	}
 	return names
 }
+
+// This test checks which resources are removed by Cleanup.
+func TestCleanup(t *testing.T) {
+	var (
+		deadPID = strconv.Itoa(0x7ffffff0)
+		old     = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+		recent  = time.Now().UTC().Format(time.RFC3339)
+	)
+	// labels returns the labels of a resource created on this host by another hive
+	// process, which has the PID of this process unless overridden.
+	labels := func(run, created string, overrides ...string) map[string]string {
+		l := libhive.ResourceLabels(nil)
+		l[libhive.LabelRunID] = run
+		l[libhive.LabelCreated] = created
+		for i := 0; i < len(overrides); i += 2 {
+			l[overrides[i]] = overrides[i+1]
+		}
+		return l
+	}
+	resources := []libhive.Resource{
+		// Created by this process.
+		{Kind: "container", ID: "c1", Labels: libhive.ResourceLabels(nil)},
+		// Process is still running.
+		{Kind: "container", ID: "c2", Labels: labels("r2", old)},
+		// Orphans.
+		{Kind: "network", ID: "n3", Labels: labels("r3", old, libhive.LabelPID, deadPID)},
+		{Kind: "container", ID: "c3", Labels: labels("r3", old, libhive.LabelPID, deadPID)},
+		// Orphan, but too recent for --older-than.
+		{Kind: "container", ID: "c4", Labels: labels("r4", recent, libhive.LabelPID, deadPID)},
+		// Created on another host, so liveness is unknown.
+		{Kind: "container", ID: "c5", Labels: labels("r5", old, libhive.LabelHost, "otherhost")},
+	}
+	if _, ok := resources[0].Labels[libhive.LabelStart]; ok {
+		// The PID of this process was reused by the hive process which created c6.
+		resources = append(resources, libhive.Resource{Kind: "container", ID: "c6", Labels: labels("r6", old, libhive.LabelStart, "1")})
+	}
+	var removed []string
+	cb := fakes.NewContainerBackend(&fakes.BackendHooks{
+		ListResources: func() ([]libhive.Resource, error) {
+			return resources, nil
+		},
+		RemoveResource: func(r libhive.Resource) error {
+			removed = append(removed, r.ID)
+			return nil
+		},
+	})
+
+	n, err := libhive.Cleanup(context.Background(), cb, time.Hour)
+	if err != nil {
+		t.Fatal("cleanup failed:", err)
+	}
+	want := []string{"c3", "c5", "n3"}
+	if len(resources) > 6 {
+		want = []string{"c3", "c5", "c6", "n3"}
+	}
+	if !reflect.DeepEqual(removed, want) || n != len(want) {
+		t.Fatalf("wrong resources removed: %v (n=%d), want %v", removed, n, want)
+	}
+
+	removed = nil
+	if _, err := libhive.Cleanup(context.Background(), cb, 0); err != nil {
+		t.Fatal("cleanup failed:", err)
+	}
+	want = []string{"c3", "c4", "n3"}
+	if len(resources) > 6 {
+		want = []string{"c3", "c4", "c6", "n3"}
+	}
+	if !reflect.DeepEqual(removed, want) {
+		t.Fatalf("wrong resources removed without age limit: %v, want %v", removed, want)
+	}
+}
+
+// This test checks that client containers are labelled with the simulator, suite and test.
+func TestRunnerResourceLabels(t *testing.T) {
+	var clientLabels map[string]string
+	cb := fakes.NewContainerBackend(&fakes.BackendHooks{
+		StartContainer: func(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+			if !strings.Contains(image, "/simulator/") {
+				clientLabels = opt.Labels
+				return new(libhive.ContainerInfo), nil
+			}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				suite := hivesim.Suite{Name: "the-suite"}
+				suite.Add(hivesim.TestSpec{
+					Name: "the-test",
+					Run:  func(t *hivesim.T) { t.StartClient("client-1") },
+				})
+				hivesim.RunSuite(hivesim.NewAt(opt.Env["HIVE_SIMULATOR"]), suite)
+			}()
+			return &libhive.ContainerInfo{Wait: func() { <-done }}, nil
+		},
+	})
+	runner := libhive.NewRunner(makeTestInventory(), fakes.NewBuilder(nil), cb)
+	ctx := context.Background()
+	if err := runner.Build(ctx, []libhive.ClientDesignator{{Client: "client-1"}}, []string{"sim-1"}, nil); err != nil {
+		t.Fatal("Build() failed:", err)
+	}
+	env := libhive.SimEnv{LogDir: t.TempDir()}
+	if _, err := runner.RunSimulators(ctx, []string{"sim-1"}, 1, env, libhive.HiveInfo{}); err != nil {
+		t.Fatal("RunSimulators() failed:", err)
+	}
+
+	want := map[string]string{
+		libhive.LabelSim:   "sim-1",
+		libhive.LabelSuite: "the-suite",
+		libhive.LabelTest:  "the-test",
+	}
+	for k, v := range want {
+		if clientLabels[k] != v {
+			t.Errorf("wrong client label %s: %q, want %q", k, clientLabels[k], v)
+		}
+	}
+}
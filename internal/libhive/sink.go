@@ -0,0 +1,209 @@
+package libhive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResultSink receives notifications about finished suites and runs.
+//
+// SuiteEnded is called while the test manager is locked, so implementations should
+// not block on slow operations.
+type ResultSink interface {
+	SuiteEnded(*SuiteSummary)
+	RunEnded(*RunSummary)
+	// Close waits for pending notifications to be handled.
+	Close() error
+}
+
+// SuiteSummary is the result of a finished test suite.
+type SuiteSummary struct {
+	Event     string            `json:"event"` // always "suite"
+	Simulator string            `json:"simulator,omitempty"`
+	Suite     string            `json:"suite"`
+	File      string            `json:"file,omitempty"` // suite file in the results directory
+	URL       string            `json:"url,omitempty"`  // link to the suite in hiveview
+	Clients   map[string]string `json:"clients"`        // client versions
+	Tests     int               `json:"tests"`
+	Passes    int               `json:"passes"`
+	Fails     int               `json:"fails"`
+	Failing   []string          `json:"failing,omitempty"` // names of failed tests
+}
+
+// RunSummary is the result of a hive run.
+type RunSummary struct {
+	Event        string    `json:"event"` // always "run"
+	Simulators   []string  `json:"simulators"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	URL          string    `json:"url,omitempty"` // link to hiveview
+	Suites       int       `json:"suites"`
+	SuitesFailed int       `json:"suitesFailed"`
+	Tests        int       `json:"tests"`
+	TestsFailed  int       `json:"testsFailed"`
+	Error        string    `json:"error,omitempty"`
+}
+
+func newSuiteSummary(suite *TestSuite) *SuiteSummary {
+	s := &SuiteSummary{
+		Event:     "suite",
+		Simulator: suite.Simulator,
+		Suite:     suite.Name,
+		File:      suite.resultFile,
+		Clients:   make(map[string]string, len(suite.ClientVersions)),
+	}
+	for client, version := range suite.ClientVersions {
+		s.Clients[client] = version
+	}
+	ids := make([]TestID, 0, len(suite.TestCases))
+	for id := range suite.TestCases {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		test := suite.TestCases[id]
+		s.Tests++
+		if test.SummaryResult.Pass {
+			s.Passes++
+		} else {
+			s.Fails++
+			s.Failing = append(s.Failing, test.Name)
+		}
+	}
+	return s
+}
+
+// WebhookConfig configures a WebhookSink.
+type WebhookConfig struct {
+	ViewURL    string        // base URL of hiveview, used for links to results
+	Retries    int           // number of retries of failed deliveries
+	RetryDelay time.Duration // delay of the first retry, doubled for each further retry
+	Timeout    time.Duration // timeout of each request
+}
+
+const (
+	defaultWebhookRetryDelay = 2 * time.Second
+	defaultWebhookTimeout    = 30 * time.Second
+	webhookQueueSize         = 256
+)
+
+// WebhookSink posts suite and run summaries as JSON to a URL. Notifications are
+// delivered in order by a background goroutine.
+type WebhookSink struct {
+	url    string
+	cfg    WebhookConfig
+	client *http.Client
+
+	queue chan any
+	done  chan struct{}
+}
+
+// NewWebhookSink creates a sink which posts to the given URL.
+func NewWebhookSink(webhookURL string, cfg WebhookConfig) *WebhookSink {
+	if cfg.RetryDelay == 0 {
+		cfg.RetryDelay = defaultWebhookRetryDelay
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultWebhookTimeout
+	}
+	s := &WebhookSink{
+		url:    webhookURL,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		queue:  make(chan any, webhookQueueSize),
+		done:   make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// SuiteEnded queues a suite notification.
+func (s *WebhookSink) SuiteEnded(summary *SuiteSummary) {
+	if s.cfg.ViewURL != "" && summary.File != "" {
+		q := url.Values{"suiteid": {summary.File}, "suitename": {summary.Suite}}
+		summary.URL = strings.TrimSuffix(s.cfg.ViewURL, "/") + "/suite.html?" + q.Encode()
+	}
+	// This is called with the test manager locked, so the notification is dropped
+	// instead of waiting for the queue when the webhook can't keep up.
+	select {
+	case s.queue <- summary:
+	default:
+		slog.Error("webhook: queue full, dropping suite notification", "suite", summary.Suite, "file", summary.File)
+	}
+}
+
+// RunEnded queues a run notification. Unlike SuiteEnded, it waits for space in the
+// queue, since it is called at the end of the run without holding any locks.
+func (s *WebhookSink) RunEnded(summary *RunSummary) {
+	if s.cfg.ViewURL != "" {
+		summary.URL = s.cfg.ViewURL
+	}
+	s.queue <- summary
+}
+
+// Close waits for all queued notifications to be delivered. The sink cannot
+// be used after calling Close.
+func (s *WebhookSink) Close() error {
+	close(s.queue)
+	<-s.done
+	return nil
+}
+
+func (s *WebhookSink) loop() {
+	defer close(s.done)
+	for msg := range s.queue {
+		body, err := json.Marshal(msg)
+		if err != nil {
+			slog.Error("webhook: can't encode notification", "err", err)
+			continue
+		}
+		if err := s.deliver(body); err != nil {
+			slog.Error("webhook: delivery failed", "url", s.url, "err", err)
+		}
+	}
+}
+
+// deliver posts body, retrying on network errors and server errors.
+func (s *WebhookSink) deliver(body []byte) error {
+	delay := s.cfg.RetryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.cfg.Retries {
+			return err
+		}
+		slog.Warn("webhook: delivery failed, retrying", "err", err, "delay", delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (s *WebhookSink) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("content-type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+}
@@ -0,0 +1,167 @@
+package libhive_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/hive/hivesim"
+	"github.com/ethereum/hive/internal/fakes"
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+// This test checks that the webhook sink posts suite and run summaries,
+// and retries failed deliveries.
+func TestWebhookSink(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+		bodies   []map[string]any
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if ct := r.Header.Get("content-type"); ct != "application/json" {
+			t.Errorf("wrong content type %q", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var msg map[string]any
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Errorf("invalid JSON %q: %v", body, err)
+		}
+		bodies = append(bodies, msg)
+	}))
+	defer srv.Close()
+
+	cb := fakes.NewContainerBackend(&fakes.BackendHooks{
+		StartContainer: func(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+			if !strings.Contains(image, "/simulator/") {
+				return new(libhive.ContainerInfo), nil
+			}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				suite := hivesim.Suite{Name: "the-suite"}
+				suite.Add(hivesim.TestSpec{
+					Name: "good",
+					Run: func(t *hivesim.T) {
+						t.StartClient("client-1")
+					},
+				})
+				suite.Add(hivesim.TestSpec{
+					Name: "bad",
+					Run:  func(t *hivesim.T) { t.Fatal("boom") },
+				})
+				hivesim.RunSuite(hivesim.NewAt(opt.Env["HIVE_SIMULATOR"]), suite)
+			}()
+			return &libhive.ContainerInfo{Wait: func() { <-done }}, nil
+		},
+	})
+	sink := libhive.NewWebhookSink(srv.URL, libhive.WebhookConfig{
+		ViewURL:    "https://hive.example/",
+		Retries:    2,
+		RetryDelay: time.Millisecond,
+	})
+	runner := libhive.NewRunner(makeTestInventory(), fakes.NewBuilder(nil), cb)
+	runner.SetResultSink(sink)
+	ctx := context.Background()
+	if err := runner.Build(ctx, []libhive.ClientDesignator{{Client: "client-1"}}, []string{"sim-1"}, nil); err != nil {
+		t.Fatal("Build() failed:", err)
+	}
+	env := libhive.SimEnv{LogDir: t.TempDir()}
+	if _, err := runner.RunSimulators(ctx, []string{"sim-1"}, 1, env, libhive.HiveInfo{}); err != nil {
+		t.Fatal("RunSimulators() failed:", err)
+	}
+	sink.Close()
+
+	if requests != 3 {
+		t.Fatalf("got %d requests, want 3", requests)
+	}
+	suite, run := bodies[0], bodies[1]
+	if suite["event"] != "suite" || suite["suite"] != "the-suite" || suite["simulator"] != "sim-1" {
+		t.Errorf("wrong suite notification: %v", suite)
+	}
+	if suite["tests"] != 2.0 || suite["passes"] != 1.0 || suite["fails"] != 1.0 {
+		t.Errorf("wrong counts in suite notification: %v", suite)
+	}
+	if !reflect.DeepEqual(suite["failing"], []any{"bad"}) {
+		t.Errorf("wrong failing tests: %v", suite["failing"])
+	}
+	if _, ok := suite["clients"].(map[string]any)["client-1"]; !ok {
+		t.Errorf("client-1 missing in clients: %v", suite["clients"])
+	}
+	url, _ := suite["url"].(string)
+	if !strings.HasPrefix(url, "https://hive.example/suite.html?suiteid=") || !strings.Contains(url, "suitename=the-suite") {
+		t.Errorf("wrong suite URL %q", url)
+	}
+	if run["event"] != "run" || run["tests"] != 2.0 || run["testsFailed"] != 1.0 {
+		t.Errorf("wrong run notification: %v", run)
+	}
+	if !reflect.DeepEqual(run["simulators"], []any{"sim-1"}) {
+		t.Errorf("wrong simulators in run notification: %v", run["simulators"])
+	}
+}
+
+// This test checks that client errors are not retried.
+func TestWebhookSinkNoRetry(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	sink := libhive.NewWebhookSink(srv.URL, libhive.WebhookConfig{Retries: 3, RetryDelay: time.Millisecond})
+	sink.RunEnded(&libhive.RunSummary{Event: "run"})
+	sink.Close()
+	if requests != 1 {
+		t.Fatalf("got %d requests, want 1", requests)
+	}
+}
+
+// This test checks that suite notifications are dropped instead of blocking
+// when the webhook is too slow to drain the queue.
+func TestWebhookSinkQueueFull(t *testing.T) {
+	var (
+		requests atomic.Int32
+		release  = make(chan struct{})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+	}))
+	defer srv.Close()
+
+	sink := libhive.NewWebhookSink(srv.URL, libhive.WebhookConfig{})
+	const sent = 1000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < sent; i++ {
+			sink.SuiteEnded(&libhive.SuiteSummary{Event: "suite", Suite: "suite"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SuiteEnded blocked on full queue")
+	}
+	close(release)
+	sink.Close()
+	if n := requests.Load(); n >= sent {
+		t.Fatalf("got %d requests, want some notifications dropped", n)
+	}
+}
@@ -1,6 +1,7 @@
 package libhive
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/json"
 	"errors"
@@ -10,13 +11,17 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/ethereum/hive/internal/simapi"
 )
 
 var (
 	ErrNoSuchNode               = errors.New("no such node")
 	ErrNoSuchTestSuite          = errors.New("no such test suite")
 	ErrNoSuchTestCase           = errors.New("no such test case")
+	ErrNoSuchParentTest         = errors.New("parent test is not running in the suite")
 	ErrMissingClientType        = errors.New("missing client type")
 	ErrNoAvailableClients       = errors.New("no available clients")
 	ErrTestSuiteRunning         = errors.New("test suite still has running tests")
@@ -24,6 +29,8 @@ var (
 	ErrNoSummaryResult          = errors.New("test case must be ended with a summary result")
 	ErrDBUpdateFailed           = errors.New("could not update results set")
 	ErrTestSuiteLimited         = errors.New("testsuite test count is limited")
+	ErrNoDiskPath               = errors.New("client was started without disk path")
+	ErrRetryPassedTest          = errors.New("can't retry a passing test")
 )
 
 // SimEnv contains the simulation parameters.
@@ -34,9 +41,15 @@ type SimEnv struct {
 	SimLogLevel    int
 	SimParallelism int
 	SimRandomSeed  int
+	SimRetries     int
+	SimShard       string
+	SimListTests   bool // run the simulator in test listing mode
 	SimTestPattern string
 	SimBuildArgs   []string
 
+	// If set, simulation API requests are recorded to a file in LogDir.
+	RecordAPI bool
+
 	// This is the time limit for the simulation run.
 	// There is no default limit.
 	SimDurationLimit time.Duration
@@ -56,6 +69,9 @@ type SimResult struct {
 	SuitesFailed int
 	Tests        int
 	TestsFailed  int
+
+	// TestList is the list of tests reported by the simulator in test listing mode.
+	TestList *simapi.TestList
 }
 
 // HiveInfo contains information about the hive instance running the simulation.
@@ -66,8 +82,12 @@ type HiveInfo struct {
 	Date       string             `json:"date"`
 }
 
+// testManagerCounter is used to assign TestManager IDs.
+var testManagerCounter atomic.Uint64
+
 // TestManager collects test results during a simulation run.
 type TestManager struct {
+	id         uint64 // distinguishes concurrent simulation runs
 	config     SimEnv
 	backend    ContainerBackend
 	clientDefs []*ClientDefinition
@@ -75,6 +95,9 @@ type TestManager struct {
 
 	simContainerID string
 	simLogFile     string
+	simName        string
+	sink           ResultSink   // notified when suites end, may be nil
+	recorder       *apiRecorder // set when API requests are recorded
 
 	// all networks started by a specific test suite, where key
 	// is network name and value is network ID
@@ -88,13 +111,15 @@ type TestManager struct {
 	testSuiteCounter  uint32
 	testCaseCounter   uint32
 	results           map[TestSuiteID]*TestSuite
+	testList          *simapi.TestList
 }
 
 func NewTestManager(config SimEnv, b ContainerBackend, clients []*ClientDefinition, hiveInfo HiveInfo) *TestManager {
 	if hiveInfo.Commit == "" && hiveInfo.Date == "" {
 		hiveInfo.Commit, hiveInfo.Date = hiveVersion()
 	}
-	return &TestManager{
+	manager := &TestManager{
+		id:                testManagerCounter.Add(1),
 		clientDefs:        clients,
 		config:            config,
 		backend:           b,
@@ -104,15 +129,38 @@ func NewTestManager(config SimEnv, b ContainerBackend, clients []*ClientDefiniti
 		results:           make(map[TestSuiteID]*TestSuite),
 		networks:          make(map[TestSuiteID]map[string]string),
 	}
+	if config.RecordAPI && config.LogDir != "" {
+		rec, err := newAPIRecorder(config.LogDir, manager.id)
+		if err != nil {
+			slog.Error("can't create API recording", "err", err)
+		} else {
+			manager.recorder = rec
+		}
+	}
+	return manager
 }
 
 // SetSimContainerInfo makes the manager aware of the simulation container.
 // This must be called after creating the simulation container, but before starting it.
-func (manager *TestManager) SetSimContainerInfo(id, logFile string) {
+func (manager *TestManager) SetSimContainerInfo(sim, id, logFile string) {
+	manager.simName = sim
 	manager.simContainerID = id
 	manager.simLogFile = logFile
 }
 
+// SetResultSink sets the sink which is notified when suites end.
+func (manager *TestManager) SetResultSink(sink ResultSink) {
+	manager.sink = sink
+}
+
+// recordingFile returns the name of the API recording file, if any.
+func (manager *TestManager) recordingFile() string {
+	if manager.recorder == nil {
+		return ""
+	}
+	return manager.recorder.name
+}
+
 // Results returns the results for all suites that have already ended.
 func (manager *TestManager) Results() map[TestSuiteID]*TestSuite {
 	manager.testSuiteMutex.RLock()
@@ -126,6 +174,23 @@ func (manager *TestManager) Results() map[TestSuiteID]*TestSuite {
 	return r
 }
 
+// SetTestList stores the test list reported by the simulator.
+func (manager *TestManager) SetTestList(list *simapi.TestList) {
+	manager.testSuiteMutex.Lock()
+	defer manager.testSuiteMutex.Unlock()
+
+	manager.testList = list
+}
+
+// TestList returns the test list reported by the simulator, or nil if the simulator
+// did not report one.
+func (manager *TestManager) TestList() *simapi.TestList {
+	manager.testSuiteMutex.RLock()
+	defer manager.testSuiteMutex.RUnlock()
+
+	return manager.testList
+}
+
 // API returns the simulation API handler.
 func (manager *TestManager) API() http.Handler {
 	return newSimulationAPI(manager.backend, manager.config, manager, manager.hiveInfo)
@@ -158,6 +223,9 @@ func (manager *TestManager) Terminate() error {
 	}
 	manager.testSuiteMutex.Lock()
 	defer manager.testSuiteMutex.Unlock()
+	if manager.recorder != nil {
+		defer manager.recorder.close()
+	}
 
 	for suiteID, suite := range manager.runningTestSuites {
 		for testID := range suite.TestCases {
@@ -200,11 +268,15 @@ func (manager *TestManager) CreateNetwork(testSuite TestSuiteID, name string) er
 		return ErrNoSuchTestSuite
 	}
 
+	// The labels must be computed before taking networkMutex, because
+	// EndTestSuite acquires the locks in the opposite order.
+	labels := manager.resourceLabels(testSuite, 0)
+
 	// add network to network map
 	manager.networkMutex.Lock()
 	defer manager.networkMutex.Unlock()
 
-	id, err := manager.backend.CreateNetwork(getUniqueName(testSuite, name))
+	id, err := manager.backend.CreateNetwork(manager.uniqueNetworkName(testSuite, name), labels)
 	if err != nil {
 		return err
 	}
@@ -216,9 +288,32 @@ func (manager *TestManager) CreateNetwork(testSuite TestSuiteID, name string) er
 	return nil
 }
 
-// getUniqueName returns a unique network name to prevent network collisions
-func getUniqueName(testSuite TestSuiteID, name string) string {
-	return fmt.Sprintf("hive_%d_%d_%s", os.Getpid(), testSuite, name)
+// resourceLabels returns the docker labels of a client container or network. The test
+// label is omitted when testID is zero.
+func (manager *TestManager) resourceLabels(testSuite TestSuiteID, testID TestID) map[string]string {
+	labels := make(map[string]string)
+	if manager.simName != "" {
+		labels[LabelSim] = manager.simName
+	}
+	manager.testSuiteMutex.RLock()
+	if suite, ok := manager.runningTestSuites[testSuite]; ok {
+		labels[LabelSuite] = suite.Name
+	}
+	manager.testSuiteMutex.RUnlock()
+	if testID != 0 {
+		manager.testCaseMutex.RLock()
+		if test, ok := manager.runningTestCases[testID]; ok {
+			labels[LabelTest] = test.Name
+		}
+		manager.testCaseMutex.RUnlock()
+	}
+	return labels
+}
+
+// uniqueNetworkName returns a unique network name to prevent network collisions.
+// The name includes the ID of the TestManager, because simulators may run concurrently.
+func (manager *TestManager) uniqueNetworkName(testSuite TestSuiteID, name string) string {
+	return fmt.Sprintf("hive_%d_%d_%d_%s", os.Getpid(), manager.id, testSuite, name)
 }
 
 // RemoveNetwork removes a docker network by the given network name.
@@ -308,7 +403,11 @@ func (manager *TestManager) ConnectContainer(testSuite TestSuiteID, networkName,
 	if !exists {
 		return ErrNetworkNotFound
 	}
-	return manager.backend.ConnectContainer(containerID, networkID)
+	if err := manager.backend.ConnectContainer(containerID, networkID); err != nil {
+		return err
+	}
+	manager.recordNetworkEvent(containerID, ClientEventConnect, networkName)
+	return nil
 }
 
 // NetworkExists reports whether a network exists in the current test context.
@@ -337,7 +436,11 @@ func (manager *TestManager) DisconnectContainer(testSuite TestSuiteID, networkNa
 	if !exists {
 		return ErrNetworkNotFound
 	}
-	return manager.backend.DisconnectContainer(containerID, networkID)
+	if err := manager.backend.DisconnectContainer(containerID, networkID); err != nil {
+		return err
+	}
+	manager.recordNetworkEvent(containerID, ClientEventDisconnect, networkName)
+	return nil
 }
 
 // EndTestSuite ends the test suite by writing the test suite results to the supplied
@@ -365,10 +468,11 @@ func (manager *TestManager) doEndSuite(testSuite TestSuiteID) error {
 	}
 	// Write the result.
 	if manager.config.LogDir != "" {
-		err := writeSuiteFile(suite, manager.config.LogDir)
+		file, err := writeSuiteFile(suite, manager.config.LogDir)
 		if err != nil {
 			return err
 		}
+		suite.resultFile = file
 	}
 	// remove the test suite's left-over docker networks.
 	if errs := manager.PruneNetworks(testSuite); len(errs) > 0 {
@@ -379,11 +483,15 @@ func (manager *TestManager) doEndSuite(testSuite TestSuiteID) error {
 	// Move the suite to results.
 	delete(manager.runningTestSuites, testSuite)
 	manager.results[testSuite] = suite
+	if manager.sink != nil {
+		manager.sink.SuiteEnded(newSuiteSummary(suite))
+	}
 	return nil
 }
 
-// StartTestSuite starts a test suite and returns the context id
-func (manager *TestManager) StartTestSuite(name string, description string) (TestSuiteID, error) {
+// StartTestSuite starts a test suite and returns the context id.
+// The random seed is recorded in the result, so the run can be reproduced.
+func (manager *TestManager) StartTestSuite(name string, description string, randomSeed int64) (TestSuiteID, error) {
 	manager.testSuiteMutex.Lock()
 	defer manager.testSuiteMutex.Unlock()
 
@@ -413,16 +521,20 @@ func (manager *TestManager) StartTestSuite(name string, description string) (Tes
 		Description:     description,
 		ClientVersions:  make(map[string]string),
 		TestCases:       make(map[TestID]*TestCase),
+		RandomSeed:      randomSeed,
+		Simulator:       manager.simName,
 		SimulatorLog:    manager.simLogFile,
 		TestDetailsLog:  testLogPath,
+		APIRecording:    manager.recordingFile(),
 		testDetailsFile: testLogFile,
 	}
 	manager.testSuiteCounter++
 	return newSuiteID, nil
 }
 
-// StartTest starts a new test case, returning the testcase id as a context identifier
-func (manager *TestManager) StartTest(testSuiteID TestSuiteID, name string, description string) (TestID, error) {
+// StartTest starts a new test case, returning the testcase id as a context identifier.
+// If parent is non-zero, the test is recorded as a subtest of the given test.
+func (manager *TestManager) StartTest(testSuiteID TestSuiteID, name string, description string, parent TestID) (TestID, error) {
 	manager.testCaseMutex.Lock()
 	defer manager.testCaseMutex.Unlock()
 
@@ -431,6 +543,13 @@ func (manager *TestManager) StartTest(testSuiteID TestSuiteID, name string, desc
 	if !ok {
 		return 0, ErrNoSuchTestSuite
 	}
+	if parent != 0 {
+		_, inSuite := testSuite.TestCases[parent]
+		_, running := manager.runningTestCases[parent]
+		if !inSuite || !running {
+			return 0, ErrNoSuchParentTest
+		}
+	}
 	// increment the testcasecounter
 	manager.testCaseCounter++
 	var newCaseID = TestID(manager.testCaseCounter)
@@ -438,12 +557,14 @@ func (manager *TestManager) StartTest(testSuiteID TestSuiteID, name string, desc
 	newTestCase := &TestCase{
 		Name:        name,
 		Description: description,
+		Parent:      parent,
 		Start:       time.Now(),
 	}
 	// add the test case to the test suite
 	testSuite.TestCases[newCaseID] = newTestCase
 	// and to the general map of id:testcases
 	manager.runningTestCases[newCaseID] = newTestCase
+	metricTestsRunning.Inc()
 
 	return newCaseID, nil
 }
@@ -474,9 +595,93 @@ func (manager *TestManager) EndTest(suiteID TestSuiteID, testID TestID, result *
 		result.Details = ""
 		result.LogOffsets = offsets
 	}
+	testCase.Metrics = result.Metrics
+	result.Metrics = nil
+	if result.Pass && len(testCase.Attempts) > 0 {
+		result.Flaky = true
+	}
 	testCase.SummaryResult = *result
+	manager.stopTestClients(testCase)
 
-	// Stop running clients.
+	// Delete from running, if it's still there.
+	delete(manager.runningTestCases, testID)
+	metricTestsRunning.Dec()
+	return nil
+}
+
+// RetryTest ends a failed attempt of a running test case. The result and clients of the
+// attempt are kept in the test case, and the test continues with a new attempt.
+func (manager *TestManager) RetryTest(suiteID TestSuiteID, testID TestID, result *TestResult) error {
+	manager.testCaseMutex.Lock()
+	defer manager.testCaseMutex.Unlock()
+
+	testSuite, ok := manager.runningTestSuites[suiteID]
+	if !ok {
+		return ErrNoSuchTestCase
+	}
+	testCase, ok := manager.runningTestCases[testID]
+	if !ok {
+		return ErrNoSuchTestCase
+	}
+	if result == nil {
+		return ErrNoSummaryResult
+	}
+	if result.Pass {
+		return ErrRetryPassedTest
+	}
+
+	if result.Details != "" && testSuite.testDetailsFile != nil {
+		offsets := manager.writeTestDetails(testSuite, testCase, result.Details)
+		result.Details = ""
+		result.LogOffsets = offsets
+	}
+	result.Metrics = nil
+	manager.stopTestClients(testCase)
+	testCase.Attempts = append(testCase.Attempts, TestAttempt{
+		Start:         testCase.Start,
+		End:           time.Now(),
+		SummaryResult: *result,
+		ClientInfo:    testCase.ClientInfo,
+		Events:        testCase.Events,
+		Subtests:      takeSubtests(testSuite, testID, manager.runningTestCases),
+	})
+
+	// Reset the test case for the next attempt.
+	testCase.Start = time.Now()
+	testCase.ClientInfo = nil
+	testCase.Events = nil
+	return nil
+}
+
+// takeSubtests removes the finished subtests of a test, including nested ones, from the
+// suite and returns them. This keeps the subtests of a failed attempt from showing up
+// next to those of the following attempts.
+func takeSubtests(suite *TestSuite, testID TestID, running map[TestID]*TestCase) map[TestID]*TestCase {
+	var subtests map[TestID]*TestCase
+	parents := map[TestID]bool{testID: true}
+	for found := true; found; {
+		found = false
+		for id, test := range suite.TestCases {
+			if !parents[test.Parent] || parents[id] {
+				continue
+			}
+			parents[id] = true
+			found = true
+			if _, ok := running[id]; ok {
+				continue
+			}
+			if subtests == nil {
+				subtests = make(map[TestID]*TestCase)
+			}
+			subtests[id] = test
+			delete(suite.TestCases, id)
+		}
+	}
+	return subtests
+}
+
+// stopTestClients stops the running clients of a test case.
+func (manager *TestManager) stopTestClients(testCase *TestCase) {
 	for _, v := range testCase.ClientInfo {
 		if v.wait != nil {
 			manager.backend.DeleteContainer(v.ID)
@@ -484,10 +689,6 @@ func (manager *TestManager) EndTest(suiteID TestSuiteID, testID TestID, result *
 			v.wait = nil
 		}
 	}
-
-	// Delete from running, if it's still there.
-	delete(manager.runningTestCases, testID)
-	return nil
 }
 
 func (manager *TestManager) writeTestDetails(suite *TestSuite, testCase *TestCase, text string) *TestLogOffsets {
@@ -551,6 +752,7 @@ func (manager *TestManager) StopNode(testID TestID, nodeID string) error {
 		}
 		nodeInfo.wait()
 		nodeInfo.wait = nil
+		addClientEvent(testCase, nodeID, ClientEventStop, "")
 	}
 	return nil
 }
@@ -572,6 +774,7 @@ func (manager *TestManager) PauseNode(testID TestID, nodeID string) error {
 	if err := manager.backend.PauseContainer(nodeInfo.ID); err != nil {
 		return fmt.Errorf("unable to pause client: %v", err)
 	}
+	addClientEvent(testCase, nodeID, ClientEventPause, "")
 	return nil
 }
 
@@ -592,14 +795,68 @@ func (manager *TestManager) UnpauseNode(testID TestID, nodeID string) error {
 	if err := manager.backend.UnpauseContainer(nodeInfo.ID); err != nil {
 		return fmt.Errorf("unable to unpause client: %v", err)
 	}
+	addClientEvent(testCase, nodeID, ClientEventUnpause, "")
+	return nil
+}
+
+// SetNodeDiskReadOnly remounts the fault injection disk of a client container.
+func (manager *TestManager) SetNodeDiskReadOnly(ctx context.Context, testID TestID, nodeID string, readOnly bool) error {
+	manager.testCaseMutex.Lock()
+	defer manager.testCaseMutex.Unlock()
+
+	testCase, ok := manager.runningTestCases[testID]
+	if !ok {
+		return ErrNoSuchNode
+	}
+	nodeInfo, ok := testCase.ClientInfo[nodeID]
+	if !ok {
+		return ErrNoSuchNode
+	}
+	if nodeInfo.diskPath == "" {
+		return ErrNoDiskPath
+	}
+	if err := manager.backend.SetDiskReadOnly(ctx, nodeInfo.ID, nodeInfo.diskPath, readOnly); err != nil {
+		return fmt.Errorf("unable to remount client disk: %v", err)
+	}
+	if readOnly {
+		addClientEvent(testCase, nodeID, ClientEventDiskReadOnly, "")
+	} else {
+		addClientEvent(testCase, nodeID, ClientEventDiskWritable, "")
+	}
 	return nil
 }
 
+// addClientEvent records a client event in a test case.
+// The caller must hold testCaseMutex.
+func addClientEvent(testCase *TestCase, nodeID, eventType, network string) {
+	testCase.Events = append(testCase.Events, ClientEvent{
+		Time:    time.Now(),
+		Client:  nodeID,
+		Type:    eventType,
+		Network: network,
+	})
+}
+
+// recordNetworkEvent adds a network event to the running test which the container
+// belongs to. Events of containers which aren't clients are not recorded.
+func (manager *TestManager) recordNetworkEvent(containerID, eventType, network string) {
+	manager.testCaseMutex.Lock()
+	defer manager.testCaseMutex.Unlock()
+
+	for _, testCase := range manager.runningTestCases {
+		if _, ok := testCase.ClientInfo[containerID]; ok {
+			addClientEvent(testCase, containerID, eventType, network)
+			return
+		}
+	}
+}
+
 // writeSuiteFile writes the simulation result to the log directory.
-func writeSuiteFile(s *TestSuite, logdir string) error {
+// It returns the name of the suite file.
+func writeSuiteFile(s *TestSuite, logdir string) (string, error) {
 	suiteData, err := json.Marshal(s)
 	if err != nil {
-		return err
+		return "", err
 	}
 	// Randomize the name, but make it so that it's ordered by date - makes cleanups easier
 	b := make([]byte, 16)
@@ -607,5 +864,5 @@ func writeSuiteFile(s *TestSuite, logdir string) error {
 	suiteFileName := fmt.Sprintf("%v-%x.json", time.Now().Unix(), b)
 	suiteFile := filepath.Join(logdir, suiteFileName)
 	// Write it.
-	return os.WriteFile(suiteFile, suiteData, 0644)
+	return suiteFileName, os.WriteFile(suiteFile, suiteData, 0644)
 }
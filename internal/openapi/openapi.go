@@ -0,0 +1,155 @@
+// Package openapi implements a subset of OpenAPI 3.0: documents, schema generation
+// from Go types and validation of JSON values against schemas.
+package openapi
+
+import (
+	"sort"
+	"strings"
+)
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info is the metadata of the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations of a path, keyed by lower-case HTTP method.
+type PathItem map[string]*Operation
+
+// Operation is a single API operation.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request.
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes a response.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a request or response body.
+type MediaType struct {
+	Schema   *Schema              `json:"schema"`
+	Encoding map[string]*Encoding `json:"encoding,omitempty"`
+}
+
+// Encoding describes a property of a multipart body.
+type Encoding struct {
+	ContentType string `json:"contentType"`
+}
+
+// Components holds the named schemas.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is a JSON schema.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+// Resolve follows a schema reference.
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+	}
+	return s
+}
+
+// Operation returns the operation of a path template, e.g. "/testsuite/{suite}".
+func (d *Document) Operation(method, template string) *Operation {
+	return d.Paths[template][strings.ToLower(method)]
+}
+
+// Find returns the operation which handles a request path, along with its path
+// template and the values of the path parameters. When multiple templates match,
+// the one with the most literal segments wins.
+func (d *Document) Find(method, path string) (op *Operation, template string, vars map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	bestLiterals := -1
+	for _, tmpl := range d.sortedPaths() {
+		o := d.Operation(method, tmpl)
+		if o == nil {
+			continue
+		}
+		v, literals, ok := matchPath(tmpl, segments)
+		if ok && literals > bestLiterals {
+			op, template, vars, bestLiterals = o, tmpl, v, literals
+		}
+	}
+	return op, template, vars
+}
+
+func (d *Document) sortedPaths() []string {
+	paths := make([]string, 0, len(d.Paths))
+	for p := range d.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func matchPath(template string, segments []string) (vars map[string]string, literals int, ok bool) {
+	parts := strings.Split(strings.Trim(template, "/"), "/")
+	if len(parts) != len(segments) {
+		return nil, 0, false
+	}
+	vars = make(map[string]string)
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if segments[i] == "" {
+				return nil, 0, false
+			}
+			vars[part[1:len(part)-1]] = segments[i]
+			continue
+		}
+		if part != segments[i] {
+			return nil, 0, false
+		}
+		literals++
+	}
+	return vars, literals, true
+}
+
+// Ref returns a reference to a component schema.
+func Ref(name string) *Schema {
+	return &Schema{Ref: refPrefix + name}
+}
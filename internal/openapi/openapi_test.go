@@ -0,0 +1,121 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testInner struct {
+	Value uint32 `json:"value"`
+}
+
+type testEmbedded struct {
+	Embedded string `json:"embedded"`
+}
+
+type testObject struct {
+	testEmbedded
+	Name     string            `json:"name" openapi:"required"`
+	Count    int               `json:"count,omitempty"`
+	Ratio    float64           `json:"ratio"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels"`
+	Inner    *testInner        `json:"inner"`
+	List     []testInner       `json:"list"`
+	Time     time.Time         `json:"time"`
+	Raw      json.RawMessage   `json:"raw"`
+	Data     []byte            `json:"data"`
+	Ignored  string            `json:"-"`
+	NoTag    bool
+	internal int
+}
+
+func TestGenerator(t *testing.T) {
+	doc := new(Document)
+	gen := NewGenerator(doc)
+	if s := gen.Schema(reflect.TypeOf(testObject{})); s.Ref != "#/components/schemas/testObject" {
+		t.Fatalf("wrong ref %q", s.Ref)
+	}
+
+	enc, _ := json.Marshal(doc.Components.Schemas)
+	want := `{"testInner":{"type":"object","properties":{"value":{"type":"integer","minimum":0}}},` +
+		`"testObject":{"type":"object","properties":{` +
+		`"NoTag":{"type":"boolean"},` +
+		`"count":{"type":"integer","format":"int64"},` +
+		`"data":{"type":"string","format":"byte","nullable":true},` +
+		`"embedded":{"type":"string"},` +
+		`"inner":{"$ref":"#/components/schemas/testInner"},` +
+		`"labels":{"type":"object","nullable":true,"additionalProperties":{"type":"string"}},` +
+		`"list":{"type":"array","nullable":true,"items":{"$ref":"#/components/schemas/testInner"}},` +
+		`"name":{"type":"string"},` +
+		`"ratio":{"type":"number","format":"double"},` +
+		`"raw":{},` +
+		`"tags":{"type":"array","nullable":true,"items":{"type":"string"}},` +
+		`"time":{"type":"string","format":"date-time"}},` +
+		`"required":["name"]}}`
+	if string(enc) != want {
+		t.Fatalf("wrong schemas:\n got %s\nwant %s", enc, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	doc := new(Document)
+	schema := NewGenerator(doc).Schema(reflect.TypeOf(testObject{}))
+
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`{"name":"x"}`, ""},
+		{`{"name":"x","count":1,"tags":null,"labels":{"a":"b"},"list":[{"value":1}],"raw":[1,"a"],"unknown":1}`, ""},
+		{`{}`, "body.name: missing required property"},
+		{`{"name":1}`, "body.name: expected string, got number"},
+		{`{"name":"x","count":1.5}`, "body.count: expected integer, got number"},
+		{`{"name":"x","list":[{"value":-1}]}`, "body.list[0].value: must be >= 0"},
+		{`{"name":"x","labels":{"a":1}}`, "body.labels.a: expected string, got number"},
+		{`{"name":"x","inner":null}`, "body.inner: must not be null"},
+		{`{"name":"x","ratio":null}`, "body.ratio: must not be null"},
+		{`[]`, "body: expected object, got array"},
+		{`{"name":"x"} {}`, "body: invalid JSON: unexpected data after value"},
+	}
+	for _, test := range tests {
+		err := doc.ValidateJSON(schema, []byte(test.input), "body")
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("input %s: got error %v, want %q", test.input, err, test.err)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	doc := &Document{Paths: map[string]PathItem{
+		"/suite/{suite}":          {"post": {OperationID: "a"}},
+		"/suite/{suite}/{node}":   {"post": {OperationID: "b"}},
+		"/suite/{suite}/test":     {"post": {OperationID: "c"}, "get": {OperationID: "d"}},
+		"/suite/{suite}/test/x/y": {"post": {OperationID: "e"}},
+	}}
+	tests := []struct {
+		method, path string
+		op           string
+		vars         map[string]string
+	}{
+		{"POST", "/suite/1", "a", map[string]string{"suite": "1"}},
+		{"POST", "/suite/1/test", "c", map[string]string{"suite": "1"}},
+		{"GET", "/suite/1/test", "d", map[string]string{"suite": "1"}},
+		{"POST", "/suite/1/n", "b", map[string]string{"suite": "1", "node": "n"}},
+		{"GET", "/suite/1/n", "", nil},
+		{"POST", "/suite//test", "", nil},
+		{"POST", "/other", "", nil},
+	}
+	for _, test := range tests {
+		op, _, vars := doc.Find(test.method, test.path)
+		var id string
+		if op != nil {
+			id = op.OperationID
+		}
+		if id != test.op || (op != nil && !reflect.DeepEqual(vars, test.vars)) {
+			t.Errorf("%s %s: got %q %v, want %q %v", test.method, test.path, id, vars, test.op, test.vars)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	timeType       = reflect.TypeOf(time.Time{})
+	zero           = 0.0
+)
+
+// Generator creates schemas for Go types, following the rules of encoding/json.
+// Named struct types are added to the component schemas of the document and
+// referenced by name.
+//
+// Struct fields are required if they have the tag `openapi:"required"`. Slices, maps
+// and pointers are nullable.
+type Generator struct {
+	doc   *Document
+	types map[string]reflect.Type
+}
+
+// NewGenerator creates a generator which adds schemas to doc.
+func NewGenerator(doc *Document) *Generator {
+	if doc.Components.Schemas == nil {
+		doc.Components.Schemas = make(map[string]*Schema)
+	}
+	return &Generator{doc: doc, types: make(map[string]reflect.Type)}
+}
+
+// Schema returns the schema of a type.
+func (g *Generator) Schema(t reflect.Type) *Schema {
+	switch {
+	case t == rawMessageType:
+		return &Schema{}
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Pointer:
+		s := g.Schema(t.Elem())
+		if s.Ref != "" {
+			// OpenAPI 3.0 ignores siblings of $ref, so null can't be allowed here.
+			return s
+		}
+		s.Nullable = true
+		return s
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: t.Kind() == reflect.Slice}
+		}
+		return &Schema{Type: "array", Items: g.Schema(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.Schema(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return g.ref(t)
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %v", t))
+}
+
+// ref adds a named struct type to the components.
+func (g *Generator) ref(t reflect.Type) *Schema {
+	name := t.Name()
+	if prev, ok := g.types[name]; ok && prev != t {
+		name = strings.ReplaceAll(t.String(), ".", "_")
+	}
+	if _, ok := g.types[name]; !ok {
+		g.types[name] = t
+		g.doc.Components.Schemas[name] = nil // placeholder for recursive types
+		g.doc.Components.Schemas[name] = g.structSchema(t)
+	}
+	return &Schema{Ref: refPrefix + name}
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *Generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.Schema(f.Type)
+		if f.Tag.Get("openapi") == "required" {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationError is returned when a value doesn't match its schema.
+type ValidationError struct {
+	Field   string // location of the invalid value, e.g. "body.name" or "path.suite"
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidatePath checks the path parameters of a request.
+func (d *Document) ValidatePath(op *Operation, vars map[string]string) error {
+	for _, p := range op.Parameters {
+		if p.In != "path" {
+			continue
+		}
+		field := "path." + p.Name
+		v, ok := vars[p.Name]
+		if !ok || v == "" {
+			return &ValidationError{field, "missing parameter"}
+		}
+		var value any = v
+		if s := d.Resolve(p.Schema); s != nil && (s.Type == "integer" || s.Type == "number") {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return &ValidationError{field, fmt.Sprintf("expected %s, got %q", s.Type, v)}
+			}
+			value = json.Number(v)
+		}
+		if err := d.Validate(p.Schema, value, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateJSON checks that data is a JSON value matching schema.
+func (d *Document) ValidateJSON(schema *Schema, data []byte, field string) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return &ValidationError{field, "invalid JSON: " + err.Error()}
+	}
+	if dec.More() {
+		return &ValidationError{field, "invalid JSON: unexpected data after value"}
+	}
+	return d.Validate(schema, value, field)
+}
+
+// Validate checks a decoded JSON value against a schema. Numbers must be
+// represented as json.Number.
+func (d *Document) Validate(schema *Schema, value any, field string) error {
+	s := d.Resolve(schema)
+	if s == nil {
+		return nil
+	}
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return &ValidationError{field, "must not be null"}
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(field, s.Type, value)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return typeError(field, s.Type, value)
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return typeError(field, s.Type, value)
+		}
+		if s.Type == "integer" && strings.ContainsAny(n.String(), ".eE") {
+			return typeError(field, s.Type, value)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return typeError(field, s.Type, value)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return &ValidationError{field, fmt.Sprintf("must be >= %v", *s.Minimum)}
+		}
+	case "array":
+		list, ok := value.([]any)
+		if !ok {
+			return typeError(field, s.Type, value)
+		}
+		for i, elem := range list {
+			if err := d.Validate(s.Items, elem, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return typeError(field, s.Type, value)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return &ValidationError{field + "." + name, "missing required property"}
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			// Unknown properties are allowed for compatibility with newer clients.
+			ps := s.Properties[k]
+			if ps == nil {
+				ps = s.AdditionalProperties
+			}
+			if err := d.Validate(ps, obj[k], field+"."+k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func typeError(field, want string, value any) error {
+	return &ValidationError{field, fmt.Sprintf("expected %s, got %s", want, jsonType(value))}
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "null"
+	}
+}
@@ -1,37 +1,123 @@
 // Package simapi contains definitions of JSON objects used in the simulation API.
 package simapi
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type TestRequest struct {
-	Name        string `json:"name"`
+	Name        string `json:"name" openapi:"required"`
 	DisplayName string `json:"display_name"`
 	Location    string `json:"location"`
 	Category    string `json:"category"`
 	Description string `json:"description"`
+
+	// RandomSeed is the seed used by the simulator. This is only set for suites.
+	RandomSeed int64 `json:"randomSeed,omitempty"`
+
+	// Parent is the ID of the test that started this test. This is only set for
+	// subtests, and the parent test must belong to the same suite.
+	Parent uint32 `json:"parent,omitempty"`
 }
 
 // NodeConfig contains the launch parameters for a client container.
 type NodeConfig struct {
-	Client      string            `json:"client"`
+	Client      string            `json:"client" openapi:"required"`
 	Networks    []string          `json:"networks"`
 	Environment map[string]string `json:"environment"`
+
+	// If DiskPath is set, the directory is mounted on a tmpfs of at most DiskQuota
+	// bytes. The mount can be made read-only while the client is running, but files
+	// which the client already has open for writing remain writable.
+	DiskPath  string `json:"diskPath,omitempty"`
+	DiskQuota int64  `json:"diskQuota,omitempty"`
 }
 
 // StartNodeResponse is returned by the client startup endpoint.
 type StartNodeResponse struct {
-	ID string `json:"id"` // Container ID.
-	IP string `json:"ip"` // IP address in bridge network
+	ID string `json:"id" openapi:"required"` // Container ID.
+	IP string `json:"ip" openapi:"required"` // IP address in bridge network
 }
 
 // NodeResponse is the description of a running client as returned by the API.
 type NodeResponse struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID   string `json:"id" openapi:"required"`
+	Name string `json:"name" openapi:"required"`
 }
 
 type ExecRequest struct {
-	Command []string `json:"command"`
+	Command []string `json:"command" openapi:"required"`
 }
 
+// Error is the response of failed API requests.
 type Error struct {
-	Error string `json:"error"`
+	Error string    `json:"error" openapi:"required"`
+	Code  ErrorCode `json:"code" openapi:"required"`
+
+	// Field is the part of the request which failed validation, for example
+	// "body.name" or "path.suite". It is only set for ErrValidation.
+	Field string `json:"field,omitempty"`
+}
+
+// ErrorCode identifies the kind of error in an Error response.
+type ErrorCode string
+
+const (
+	ErrBadRequest       ErrorCode = "bad_request"        // invalid request
+	ErrValidation       ErrorCode = "validation_failed"  // request doesn't match the API spec
+	ErrNotFound         ErrorCode = "not_found"          // unknown endpoint or object
+	ErrMethodNotAllowed ErrorCode = "method_not_allowed" // unsupported method of an endpoint
+	ErrUnknownSuite     ErrorCode = "unknown_suite"      // suite doesn't exist or has ended
+	ErrUnknownTest      ErrorCode = "unknown_test"       // test doesn't exist or has ended
+	ErrUnknownNode      ErrorCode = "unknown_node"       // client container doesn't exist
+	ErrUnknownClient    ErrorCode = "unknown_client"     // client type doesn't exist
+	ErrUnknownNetwork   ErrorCode = "unknown_network"    // network doesn't exist
+	ErrClientStart      ErrorCode = "client_start_failed"
+	ErrInternal         ErrorCode = "internal_error"
+)
+
+// ParseShard parses a shard specifier of the form "i/n", which selects the i-th of n
+// shards of the tests. It is used for the --sim.shard flag and the HIVE_SHARD variable.
+// Shard indexes start at one.
+func ParseShard(s string) (index, count int, err error) {
+	is, ns, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid shard %q, expected i/n", s)
+	}
+	if index, err = strconv.Atoi(is); err != nil {
+		return 0, 0, fmt.Errorf("invalid shard index %q", is)
+	}
+	if count, err = strconv.Atoi(ns); err != nil {
+		return 0, 0, fmt.Errorf("invalid shard count %q", ns)
+	}
+	if count < 1 || index < 1 || index > count {
+		return 0, 0, fmt.Errorf("invalid shard %q, index must be between 1 and %d", s, count)
+	}
+	return index, count, nil
+}
+
+// TestList is sent by simulators running in test listing mode. It contains all suites
+// and tests of the simulator.
+type TestList struct {
+	Suites []ListedSuite `json:"suites"`
+}
+
+type ListedSuite struct {
+	Name        string       `json:"name" openapi:"required"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Location    string       `json:"location,omitempty"`
+	Tests       []ListedTest `json:"tests"`
+}
+
+type ListedTest struct {
+	Name        string `json:"name" openapi:"required"`
+	DisplayName string `json:"displayName,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Matched is true when the test is selected by the test pattern (--sim.limit).
+	Matched bool `json:"matched"`
 }
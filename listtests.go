@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ethereum/hive/internal/libhive"
+	"github.com/ethereum/hive/internal/simapi"
+)
+
+// simTestList is the JSON output of --list-tests.
+type simTestList struct {
+	Simulator string               `json:"simulator"`
+	Suites    []simapi.ListedSuite `json:"suites"`
+}
+
+// printTestLists writes the tests reported by simulators in test listing mode.
+func printTestLists(w io.Writer, simList []string, results []libhive.SimResult, asJSON bool) error {
+	lists := make([]simTestList, len(simList))
+	for i, sim := range simList {
+		lists[i].Simulator = sim
+		if results[i].TestList == nil {
+			return fmt.Errorf("simulator %s did not report a test list (is it built with a recent hivesim?)", sim)
+		}
+		lists[i].Suites = results[i].TestList.Suites
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(lists)
+	}
+
+	for _, list := range lists {
+		var total, matched int
+		fmt.Fprintf(w, "simulator %s\n", list.Simulator)
+		for _, suite := range list.Suites {
+			fmt.Fprintf(w, "  suite %s%s\n", suite.Name, summaryLine(suite.Description))
+			for _, test := range suite.Tests {
+				mark := " "
+				if test.Matched {
+					mark = "*"
+					matched++
+				}
+				total++
+				category := ""
+				if test.Category != "" {
+					category = " [" + test.Category + "]"
+				}
+				fmt.Fprintf(w, "  %s   %s%s%s\n", mark, test.Name, category, summaryLine(test.Description))
+			}
+		}
+		fmt.Fprintf(w, "%d of %d tests selected (marked with *)\n\n", matched, total)
+	}
+	return nil
+}
+
+// summaryLine returns the first line of a description, formatted for the test list.
+func summaryLine(desc string) string {
+	desc = strings.TrimSpace(desc)
+	if desc == "" {
+		return ""
+	}
+	line, _, _ := strings.Cut(desc, "\n")
+	return " - " + strings.TrimSpace(line)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/ethereum/hive/internal/fakes"
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+// replayWithFakes replays an API recording against the fake container backend.
+// The clients of the recording are made available without building them.
+func replayWithFakes(env libhive.SimEnv, records []libhive.APIRecord, hiveInfo libhive.HiveInfo) []libhive.ReplayDiff {
+	backend := fakes.NewContainerBackend(nil)
+	tm := libhive.NewTestManager(env, backend, libhive.RecordedClients(records), hiveInfo)
+	defer tm.Terminate()
+	return libhive.Replay(tm, records)
+}
+
+// reportReplay prints the differences found by a replay.
+func reportReplay(records []libhive.APIRecord, diffs []libhive.ReplayDiff) {
+	for _, d := range diffs {
+		slog.Error("replay: " + d.String())
+	}
+	slog.Info(fmt.Sprintf("replayed %d requests, %d differ from the recording", len(records), len(diffs)))
+	if len(diffs) > 0 {
+		fatal(fmt.Errorf("replay: %d responses differ from the recording", len(diffs)))
+	}
+}